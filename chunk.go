@@ -0,0 +1,141 @@
+package tmx
+
+import "image"
+
+// Chunk is a rectangular section of the tile data of a layer in an infinite
+// map. Its position and size are in tiles.
+type Chunk struct {
+	X              int             `xml:"x,attr"`
+	Y              int             `xml:"y,attr"`
+	Width          int             `xml:"width,attr"`
+	Height         int             `xml:"height,attr"`
+	TileGlobalRefs []TileGlobalRef `xml:"tile"`
+
+	// Raw Data loaded from XML. Not intended to be used directly; use
+	// Layer.Chunks to retrieve chunks with their tiles decoded.
+	RawBytes []byte `xml:",innerxml"`
+}
+
+// EditorSettings holds settings of a map which are used by Tiled, rather than
+// describing the contents of the map.
+type EditorSettings struct {
+	// ChunkSize is the size of the chunks Tiled splits the tile data of an
+	// infinite map into, if other than 16 by 16 tiles
+	ChunkSize *ChunkSize `xml:"chunksize" json:"chunksize"`
+	// Export is the file the map was last exported to, if any
+	Export *Export `xml:"export" json:"export"`
+}
+
+// ChunkSize is the size of the chunks of an infinite map, in tiles.
+type ChunkSize struct {
+	Width  int `xml:"width,attr" json:"width"`
+	Height int `xml:"height,attr" json:"height"`
+}
+
+// Export is the target file and format Tiled last exported a map to.
+type Export struct {
+	Target string `xml:"target,attr" json:"target"`
+	Format string `xml:"format,attr" json:"format"`
+}
+
+// ChunkSize returns the size of the chunks of the map, in tiles, as written
+// by Tiled in its EditorSettings, or 16 by 16, Tiled's default.
+func (m *Map) ChunkSize() (w, h int) {
+	w, h = 16, 16
+	if s := m.EditorSettings; s != nil && s.ChunkSize != nil {
+		if s.ChunkSize.Width > 0 {
+			w = s.ChunkSize.Width
+		}
+		if s.ChunkSize.Height > 0 {
+			h = s.ChunkSize.Height
+		}
+	}
+
+	return w, h
+}
+
+// Infinite returns true if the map is infinite, in which case the tile data
+// of its layers is split into chunks; see Layer.Chunks.
+func (m *Map) Infinite() bool {
+	return m.RawInfinite
+}
+
+// Chunks returns the chunks of the layer with their TileGlobalRefs decoded,
+// using the encoding and compression of the layer's data. Returns nil if the
+// layer's data is not split into chunks, as in maps which are not infinite.
+// Data split into chunks cannot be read as a whole, so TileGlobalRefs and the
+// methods built upon it return ErrChunkedData for such a layer.
+//
+// A chunk without a width or height is taken to be of the ChunkSize of the
+// map the layer was decoded with.
+func (l *Layer) Chunks() ([]Chunk, error) {
+	data, err := l.data()
+	if err != nil {
+		return nil, err
+	}
+	if len(data.Chunks) == 0 {
+		return nil, nil
+	}
+
+	chunks := make([]Chunk, len(data.Chunks))
+	for i, c := range data.Chunks {
+		if len(c.TileGlobalRefs) == 0 {
+			d := Data{
+				Encoding:    data.Encoding,
+				Compression: data.Compression,
+				lenient:     data.lenient,
+				RawBytes:    c.RawBytes,
+			}
+
+			gids, err := d.decodeGlobalIDs(nil, c.Width*c.Height)
+			if err != nil {
+				return nil, err
+			}
+
+			c.TileGlobalRefs = make([]TileGlobalRef, len(gids))
+			for j, gid := range gids {
+				c.TileGlobalRefs[j].GlobalID = gid
+			}
+		}
+
+		if c.Width == 0 {
+			c.Width = l.chunkWidth
+		}
+		if c.Height == 0 {
+			c.Height = l.chunkHeight
+		}
+
+		chunks[i] = c
+	}
+
+	return chunks, nil
+}
+
+// Bounds returns the bounds of the tile data of the layer, in tiles. For a
+// layer split into chunks, as in infinite maps, this is the union of its
+// chunks, which may lie at negative coordinates; otherwise it runs from 0, 0
+// to the Width and Height of the layer.
+func (l *Layer) Bounds() (image.Rectangle, error) {
+	data, err := l.data()
+	if err != nil {
+		return image.Rectangle{}, err
+	}
+	if len(data.Chunks) == 0 {
+		return image.Rect(0, 0, l.Width, l.Height), nil
+	}
+
+	var r image.Rectangle
+	for _, c := range data.Chunks {
+		w, h := c.Width, c.Height
+		if w == 0 {
+			w = l.chunkWidth
+		}
+		if h == 0 {
+			h = l.chunkHeight
+		}
+
+		r = r.Union(image.Rect(c.X, c.Y, c.X+w, c.Y+h))
+	}
+
+	return r, nil
+}
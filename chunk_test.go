@@ -0,0 +1,149 @@
+package tmx
+
+import (
+	"bytes"
+	"image"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestChunks(t *testing.T) {
+	m := decodeFixture(t, "infinite.tmx")
+
+	if !m.Infinite() {
+		t.Error("expected map to be infinite")
+	}
+	if decodeFixture(t, "test.tmx").Infinite() {
+		t.Error("expected map not to be infinite")
+	}
+
+	for _, name := range []string{"zlib", "csv", "xml"} {
+		l := m.LayerWithName(name)
+
+		chunks, err := l.Chunks()
+		if err != nil {
+			t.Fatalf("%v: %v", name, err)
+		}
+		if len(chunks) != 2 {
+			t.Fatalf("%v: expected 2 chunks, got %v", name, len(chunks))
+		}
+
+		c := chunks[0]
+		if c.X != -2 || c.Y != 0 || c.Width != 2 || c.Height != 2 {
+			t.Errorf("%v: unexpected chunk bounds %+v", name, c)
+		}
+
+		for i, exp := range [][]GlobalID{{1, 2, 3, 4}, {0, 5, 6, 0}} {
+			var gids []GlobalID
+			for _, tr := range chunks[i].TileGlobalRefs {
+				gids = append(gids, tr.GlobalID)
+			}
+			if !reflect.DeepEqual(gids, exp) {
+				t.Errorf("%v chunk %v: expected %v, got %v", name, i, exp, gids)
+			}
+		}
+
+		if _, err := l.TileGlobalRefs(); err != ErrChunkedData {
+			t.Errorf("%v: expected ErrChunkedData, got %v", name, err)
+		}
+	}
+
+	if chunks, err := decodeFixture(t, "test.tmx").Layers[0].Chunks(); err != nil || chunks != nil {
+		t.Errorf("expected no chunks for a finite map, got %v (%v)", chunks, err)
+	}
+}
+
+func TestEncodeChunks(t *testing.T) {
+	m := decodeFixture(t, "infinite.tmx")
+
+	var buf bytes.Buffer
+	if err := Encode(&buf, m); err != nil {
+		t.Fatal(err)
+	}
+
+	rm, err := Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !rm.Infinite() {
+		t.Error("expected map to remain infinite")
+	}
+
+	for i := range m.Layers {
+		want, err := m.Layers[i].Chunks()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := rm.Layers[i].Chunks()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for j := range want {
+			want[j].RawBytes, got[j].RawBytes = nil, nil
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%v: expected chunks %+v, got %+v", m.Layers[i].Name, want, got)
+		}
+	}
+}
+
+func TestEditorSettings(t *testing.T) {
+	m := decodeFixture(t, "extra.tmx")
+	if s := m.EditorSettings; s == nil || s.Export == nil || *s.Export != (Export{"extra.json", "json"}) {
+		t.Errorf("unexpected editor settings %+v", s)
+	}
+	if w, h := m.ChunkSize(); w != 16 || h != 16 {
+		t.Errorf("expected default chunk size 16x16, got %vx%v", w, h)
+	}
+
+	m, err := Decode(strings.NewReader(`<map infinite="1">
+ <editorsettings>
+  <chunksize width="2" height="1"/>
+ </editorsettings>
+ <layer name="ground">
+  <data encoding="csv">
+   <chunk x="0" y="0">1,2</chunk>
+  </data>
+ </layer>
+</map>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if w, h := m.ChunkSize(); w != 2 || h != 1 {
+		t.Errorf("expected chunk size 2x1, got %vx%v", w, h)
+	}
+
+	chunks, err := m.Layers[0].Chunks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := chunks[0]; c.Width != 2 || c.Height != 1 || len(c.TileGlobalRefs) != 2 {
+		t.Errorf("expected chunk of the map's chunk size, got %+v", c)
+	}
+
+	if s := roundTrip(t, m).EditorSettings; !reflect.DeepEqual(s, m.EditorSettings) {
+		t.Errorf("expected editor settings to survive encoding, got %+v", s)
+	}
+}
+
+func TestLayerBounds(t *testing.T) {
+	m := decodeFixture(t, "infinite.tmx")
+	for _, l := range m.Layers {
+		b, err := l.Bounds()
+		if err != nil {
+			t.Fatalf("%v: %v", l.Name, err)
+		}
+		if e := image.Rect(-2, 0, 2, 2); b != e {
+			t.Errorf("%v: expected bounds %v, got %v", l.Name, e, b)
+		}
+	}
+
+	l := decodeFixture(t, "test.tmx").LayerWithName("walls")
+	if b, err := l.Bounds(); err != nil || b != image.Rect(0, 0, 48, 30) {
+		t.Errorf("expected bounds of the layer, got %v (%v)", b, err)
+	}
+}
@@ -0,0 +1,120 @@
+package tmx
+
+import (
+	"image/color"
+	"strings"
+)
+
+// parseColor parses a hex color as written by Tiled, with or without a leading
+// `#`, in any of the forms `#RGB`, `#ARGB`, `#RRGGBB`, or `#AARRGGBB`. The
+// short forms are expanded by doubling each digit, and colors without an alpha
+// component are opaque. As color.RGBA is alpha-premultiplied, the color
+// channels are scaled by the alpha, so that `#80ff0000` is {0x80, 0, 0, 0x80}.
+// Returns ErrPropertyFailedConversion if the string is malformed.
+func parseColor(s string) (color.RGBA, error) {
+	s = strings.TrimPrefix(s, "#")
+
+	var n []uint8
+	for i := 0; i < len(s); i++ {
+		v, ok := hexDigit(s[i])
+		if !ok {
+			return color.RGBA{}, ErrPropertyFailedConversion
+		}
+		n = append(n, v)
+	}
+
+	var argb [4]uint8
+	switch len(n) {
+	case 3, 4:
+		if len(n) == 3 {
+			n = append([]uint8{0xf}, n...)
+		}
+		for i := range argb {
+			argb[i] = n[i]<<4 | n[i]
+		}
+	case 6, 8:
+		if len(n) == 6 {
+			n = append([]uint8{0xf, 0xf}, n...)
+		}
+		for i := range argb {
+			argb[i] = n[2*i]<<4 | n[2*i+1]
+		}
+	default:
+		return color.RGBA{}, ErrPropertyFailedConversion
+	}
+
+	// Tiled writes the channels without premultiplying them
+	c := color.NRGBA{R: argb[1], G: argb[2], B: argb[3], A: argb[0]}
+
+	return color.RGBAModel.Convert(c).(color.RGBA), nil
+}
+
+func hexDigit(c byte) (uint8, bool) {
+	switch {
+	case c >= '0' && c <= '9':
+		return c - '0', true
+	case c >= 'a' && c <= 'f':
+		return c - 'a' + 10, true
+	case c >= 'A' && c <= 'F':
+		return c - 'A' + 10, true
+	}
+
+	return 0, false
+}
+
+// optionalColor parses an optional color attribute; ok is false if it is empty
+// or malformed.
+func optionalColor(s string) (c color.RGBA, ok bool) {
+	if s == "" {
+		return c, false
+	}
+
+	c, err := parseColor(s)
+
+	return c, err == nil
+}
+
+// BackgroundColorRGBA returns the parsed background color of the map; ok is
+// false if no valid background color is set.
+func (m *Map) BackgroundColorRGBA() (color.RGBA, bool) {
+	return optionalColor(m.BackgroundColor)
+}
+
+// ColorRGBA returns the parsed color used to draw the objects of the group;
+// ok is false if no valid color is set.
+func (og *ObjectGroup) ColorRGBA() (color.RGBA, bool) {
+	return optionalColor(og.Color)
+}
+
+// TintColorRGBA returns the parsed color the layer is tinted with; ok is false
+// if no valid tint color is set.
+func (l *Layer) TintColorRGBA() (color.RGBA, bool) {
+	return optionalColor(l.TintColor)
+}
+
+// TintColorRGBA returns the parsed color the group is tinted with; ok is false
+// if no valid tint color is set.
+func (og *ObjectGroup) TintColorRGBA() (color.RGBA, bool) {
+	return optionalColor(og.TintColor)
+}
+
+// TintColorRGBA returns the parsed color the image layer is tinted with; ok is
+// false if no valid tint color is set.
+func (il *ImageLayer) TintColorRGBA() (color.RGBA, bool) {
+	return optionalColor(il.TintColor)
+}
+
+// TintColorRGBA returns the parsed color the group is tinted with, which also
+// applies to all of its children; ok is false if no valid tint color is set.
+func (g *Group) TintColorRGBA() (color.RGBA, bool) {
+	return optionalColor(g.TintColor)
+}
+
+// Color returns the value of a property of type `color` as a color.RGBA, its
+// channels premultiplied by its alpha. Colors without alpha, as `#RRGGBB`, are
+// opaque; an empty value, which Tiled writes for a color that is unset, is the
+// zero color.
+func (pl Properties) Color(name string) (v color.RGBA, err error) {
+	err = pl.Get(name, &v)
+	return
+}
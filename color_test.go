@@ -0,0 +1,125 @@
+package tmx
+
+import (
+	"image/color"
+	"testing"
+)
+
+func TestParseColor(t *testing.T) {
+	for _, c := range []struct {
+		in  string
+		exp color.RGBA
+		err error
+	}{
+		{"#f80", color.RGBA{0xff, 0x88, 0x00, 0xff}, nil},
+		{"#ff8800", color.RGBA{0xff, 0x88, 0x00, 0xff}, nil},
+		{"#80ff8800", color.RGBA{0x80, 0x44, 0x00, 0x80}, nil},
+		{"#8f80", color.RGBA{0x88, 0x48, 0x00, 0x88}, nil},
+		{"FF8800", color.RGBA{0xff, 0x88, 0x00, 0xff}, nil},
+		{"#", color.RGBA{}, ErrPropertyFailedConversion},
+		{"#ff88", color.RGBA{0xff, 0x88, 0x88, 0xff}, nil},
+		{"#ff880", color.RGBA{}, ErrPropertyFailedConversion},
+		{"#gg8800", color.RGBA{}, ErrPropertyFailedConversion},
+		{"#ff8800ff00", color.RGBA{}, ErrPropertyFailedConversion},
+	} {
+		v, err := parseColor(c.in)
+		if err != c.err {
+			t.Errorf("%v: expected error %v, got %v", c.in, c.err, err)
+		}
+		if v != c.exp {
+			t.Errorf("%v: expected %v, got %v", c.in, c.exp, v)
+		}
+		if v.R > v.A || v.G > v.A || v.B > v.A {
+			t.Errorf("%v: expected an alpha-premultiplied color, got %v", c.in, v)
+		}
+	}
+}
+
+func TestBackgroundColorRGBA(t *testing.T) {
+	if _, ok := (&Map{}).BackgroundColorRGBA(); ok {
+		t.Error("expected no background color")
+	}
+
+	c, ok := (&Map{BackgroundColor: "#f80"}).BackgroundColorRGBA()
+	if e := (color.RGBA{0xff, 0x88, 0x00, 0xff}); !ok || c != e {
+		t.Errorf("expected %v, got %v (%v)", e, c, ok)
+	}
+}
+
+func TestObjectGroupColorRGBA(t *testing.T) {
+	for _, c := range []struct {
+		in  string
+		exp color.RGBA
+		ok  bool
+	}{
+		{"", color.RGBA{}, false},
+		{"#a0a0a4", color.RGBA{0xa0, 0xa0, 0xa4, 0xff}, true},
+		{"#0f0", color.RGBA{0x00, 0xff, 0x00, 0xff}, true},
+		{"#800f", color.RGBA{0x00, 0x00, 0x88, 0x88}, true},
+		{"#7fff0000", color.RGBA{0x7f, 0x00, 0x00, 0x7f}, true},
+		{"#nope", color.RGBA{}, false},
+	} {
+		v, ok := (&ObjectGroup{Color: c.in}).ColorRGBA()
+		if ok != c.ok || v != c.exp {
+			t.Errorf("%v: expected %v (%v), got %v (%v)", c.in, c.exp, c.ok, v, ok)
+		}
+	}
+}
+
+func TestPropertiesColor(t *testing.T) {
+	pl := Properties{
+		{Name: "tint", Type: "color", Value: "#80ff0000"},
+		{Name: "opaque", Type: "color", Value: "#00ff00"},
+		{Name: "unset", Type: "color", Value: ""},
+		{Name: "broken", Type: "color", Value: "#zz0000"},
+		{Name: "text", Value: "#ff0000"},
+	}
+
+	tests := []struct {
+		name     string
+		expected color.RGBA
+		err      error
+	}{
+		{"tint", color.RGBA{R: 0x80, A: 0x80}, nil},
+		{"opaque", color.RGBA{G: 0xff, A: 0xff}, nil},
+		{"unset", color.RGBA{}, nil},
+		{"broken", color.RGBA{}, ErrPropertyFailedConversion},
+		{"text", color.RGBA{}, ErrPropertyWrongType},
+		{"missing", color.RGBA{}, ErrPropertyNotFound},
+	}
+
+	for _, test := range tests {
+		c, err := pl.Color(test.name)
+		if err != test.err {
+			t.Errorf("%v: expected error %v, got %v", test.name, test.err, err)
+		}
+		if c != test.expected {
+			t.Errorf("%v: expected %v, got %v", test.name, test.expected, c)
+		}
+	}
+}
+
+func TestTintColorRGBA(t *testing.T) {
+	m := decodeFixture(t, "parallax.tmx")
+	far := &m.Groups[0]
+
+	for _, c := range []struct {
+		name string
+		l    interface {
+			TintColorRGBA() (color.RGBA, bool)
+		}
+		exp color.RGBA
+		ok  bool
+	}{
+		{"sky", &m.ImageLayers[0], color.RGBA{0x80, 0x40, 0xa0, 0xff}, true},
+		{"far", far, color.RGBA{0x80, 0x00, 0x00, 0x80}, true},
+		{"hills", &far.Layers[0], color.RGBA{}, false},
+		{"clouds", &far.ObjectGroups[0], color.RGBA{}, false},
+		{"bad", &Layer{TintColor: "#nope"}, color.RGBA{}, false},
+	} {
+		v, ok := c.l.TintColorRGBA()
+		if ok != c.ok || v != c.exp {
+			t.Errorf("%v: expected %v (%v), got %v (%v)", c.name, c.exp, c.ok, v, ok)
+		}
+	}
+}
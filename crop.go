@@ -0,0 +1,152 @@
+package tmx
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// Crop returns a new Map holding the w by h tiles of m whose top-left tile is
+// at x, y. Tile layers are trimmed to the region, objects whose origin lies
+// within it are kept, and objects and image layers are moved so that their
+// positions are relative to the region; groups are cropped likewise, and the
+// tilesets are copied from m. The original map is not modified. Returns
+// ErrOutOfBounds if the region does not fit within the map.
+func (m *Map) Crop(x, y, w, h int) (*Map, error) {
+	if w <= 0 || h <= 0 || x < 0 || y < 0 || x+w > m.Width || y+h > m.Height {
+		return nil, ErrOutOfBounds
+	}
+
+	c := *m
+	c.frozen = false
+	c.Width, c.Height = w, h
+	c.TileSets = append([]TileSet(nil), m.TileSets...)
+	c.RawExtra = append([]Tag(nil), m.RawExtra...)
+
+	var err error
+	c.Layers, c.ObjectGroups, c.ImageLayers, c.Groups, err = m.cropElements(
+		m.Layers, m.ObjectGroups, m.ImageLayers, m.Groups, x, y, w, h,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &c, nil
+}
+
+// cropElements crops copies of the given layers, object groups, image layers,
+// and groups to the region, recursing into groups
+func (m *Map) cropElements(
+	ls []Layer, ogs []ObjectGroup, ils []ImageLayer, gs []Group,
+	x, y, w, h int,
+) ([]Layer, []ObjectGroup, []ImageLayer, []Group, error) {
+	px, py := float64(x*m.TileWidth), float64(y*m.TileHeight)
+	pw, ph := float64(w*m.TileWidth), float64(h*m.TileHeight)
+
+	cls := make([]Layer, len(ls))
+	for i := range ls {
+		if err := ls[i].cropInto(&cls[i], x, y, w, h); err != nil {
+			return nil, nil, nil, nil, err
+		}
+	}
+
+	cogs := make([]ObjectGroup, len(ogs))
+	for i := range ogs {
+		og := &cogs[i]
+		*og = ogs[i]
+		og.frozen = false
+		og.Objects = nil
+
+		for _, o := range ogs[i].Objects {
+			if o.X >= px && o.Y >= py && o.X < px+pw && o.Y < py+ph {
+				o.X -= px
+				o.Y -= py
+				og.Objects = append(og.Objects, o)
+			}
+		}
+	}
+
+	cils := make([]ImageLayer, len(ils))
+	for i := range ils {
+		il := &cils[i]
+		*il = ils[i]
+		il.OffsetX -= px
+		il.OffsetY -= py
+	}
+
+	cgs := make([]Group, len(gs))
+	for i := range gs {
+		g := &cgs[i]
+		*g = gs[i]
+
+		var err error
+		g.Layers, g.ObjectGroups, g.ImageLayers, g.Groups, err = m.cropElements(
+			g.Layers, g.ObjectGroups, g.ImageLayers, g.Groups, x, y, w, h,
+		)
+		if err != nil {
+			return nil, nil, nil, nil, err
+		}
+	}
+
+	return cls, cogs, cils, cgs, nil
+}
+
+// cropInto fills dst with the w by h tiles of the layer starting at x, y
+func (l *Layer) cropInto(dst *Layer, x, y, w, h int) error {
+	trs, err := l.TileGlobalRefs()
+	if err != nil {
+		return err
+	}
+
+	if x+w > l.Width || y+h > l.Height || len(trs) != l.Width*l.Height {
+		return fmt.Errorf(
+			"layer %v of %vx%v tiles cannot be cropped to %vx%v at %v,%v",
+			l.Name, l.Width, l.Height, w, h, x, y,
+		)
+	}
+
+	cropped := make([]TileGlobalRef, 0, w*h)
+	for ry := y; ry < y+h; ry++ {
+		cropped = append(cropped, trs[x+ry*l.Width:x+w+ry*l.Width]...)
+	}
+
+	*dst = *l
+	dst.Width, dst.Height = w, h
+	dst.RawData = Data{TileGlobalRefs: cropped}
+	dst.tileGlobalRefs = atomic.Value{}
+	dst.tileDefs = atomic.Value{}
+	dst.frozen = false
+
+	return nil
+}
+
+// Regions slices the map into sub-maps of regionW by regionH tiles using
+// Crop, in row-major order starting from the top-left. Regions along the right
+// and bottom edges are smaller if the map size is not a multiple of the
+// region size.
+func (m *Map) Regions(regionW, regionH int) ([]*Map, error) {
+	if regionW <= 0 || regionH <= 0 {
+		return nil, fmt.Errorf("invalid region size %vx%v", regionW, regionH)
+	}
+
+	var regions []*Map
+	for y := 0; y < m.Height; y += regionH {
+		for x := 0; x < m.Width; x += regionW {
+			w, h := regionW, regionH
+			if x+w > m.Width {
+				w = m.Width - x
+			}
+			if y+h > m.Height {
+				h = m.Height - y
+			}
+
+			r, err := m.Crop(x, y, w, h)
+			if err != nil {
+				return nil, err
+			}
+
+			regions = append(regions, r)
+		}
+	}
+
+	return regions, nil
+}
@@ -0,0 +1,113 @@
+package tmx
+
+import "testing"
+
+func TestCrop(t *testing.T) {
+	m := decodeFixture(t, "collision.tmx")
+
+	c, err := m.Crop(1, 1, 3, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if c.Width != 3 || c.Height != 2 {
+		t.Errorf("expected a 3x2 map, got %vx%v", c.Width, c.Height)
+	}
+
+	walls := c.LayerWithName("walls")
+	exp := []GlobalID{0, 3, 1, 0, 0, 4}
+	for i, e := range exp {
+		if gid, err := walls.GlobalIDAt(i%3, i/3); err != nil || gid != e {
+			t.Errorf("idx(%v): expected %v, got %v (%v)", i, e, gid, err)
+		}
+	}
+
+	tds, err := walls.TileDefs(c.TileSets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id := tds[1].ID; id != 2 {
+		t.Errorf("expected tile id 2, got %v", id)
+	}
+
+	if gid, _ := m.TileGID("walls", 1, 1); gid != 0 {
+		t.Error("expected the original map to be untouched")
+	}
+
+	for _, r := range [][4]int{{-1, 0, 1, 1}, {0, 0, 5, 1}, {3, 2, 1, 2}, {0, 0, 0, 1}} {
+		if _, err := m.Crop(r[0], r[1], r[2], r[3]); err != ErrOutOfBounds {
+			t.Errorf("%v: expected ErrOutOfBounds, got %v", r, err)
+		}
+	}
+}
+
+func TestCropObjects(t *testing.T) {
+	m := decodeFixture(t, "test.tmx")
+
+	// the `players` group holds a single object at 80,432
+	c, err := m.Crop(4, 26, 10, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	players := c.ObjectGroupWithName("players")
+	if l := len(players.Objects); l != 1 {
+		t.Fatalf("expected 1 player, got %v", l)
+	}
+	if o := players.Objects[0]; o.X != 16 || o.Y != 16 {
+		t.Errorf("expected player at (16,16), got (%v,%v)", o.X, o.Y)
+	}
+
+	if l := len(c.ObjectGroupWithName("enemies").Objects); l != 0 {
+		t.Errorf("expected no enemies, got %v", l)
+	}
+	if o := m.ObjectGroupWithName("players").Objects[0]; o.X != 80 {
+		t.Error("expected the original map objects to be untouched")
+	}
+}
+
+func TestRegions(t *testing.T) {
+	m := decodeFixture(t, "test.tmx")
+
+	regions, err := m.Regions(20, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 48x30 tiles splits into 3 columns (20, 20, 8) and 2 rows (20, 10)
+	if l := len(regions); l != 6 {
+		t.Fatalf("expected 6 regions, got %v", l)
+	}
+
+	sizes := [][2]int{{20, 20}, {20, 20}, {8, 20}, {20, 10}, {20, 10}, {8, 10}}
+	objects := 0
+	for i, r := range regions {
+		if r.Width != sizes[i][0] || r.Height != sizes[i][1] {
+			t.Errorf("region(%v): expected %v, got %vx%v", i, sizes[i], r.Width, r.Height)
+		}
+
+		trs, err := r.LayerWithName("walls").TileGlobalRefs()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if l := len(trs); l != r.Width*r.Height {
+			t.Errorf("region(%v): expected %v tiles, got %v", i, r.Width*r.Height, l)
+		}
+
+		for _, og := range r.ObjectGroups {
+			objects += len(og.Objects)
+		}
+	}
+
+	total := 0
+	for _, og := range m.ObjectGroups {
+		total += len(og.Objects)
+	}
+	if objects != total {
+		t.Errorf("expected the regions to hold all %v objects, got %v", total, objects)
+	}
+
+	if _, err := m.Regions(0, 10); err == nil {
+		t.Error("expected an error for an empty region size")
+	}
+}
@@ -0,0 +1,496 @@
+package tmx
+
+import (
+	"encoding/xml"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+// Encode writes the map to w as TMX XML. The tile data of each layer is
+// encoded with the encoding and compression it was decoded with, so that any
+// changes made to the tiles are kept; zstd data is written in the zstd format,
+// but stored without compression. Layers, object groups, image layers,
+// and groups are written in order of their Z, as Tiled draws them. TileSets
+// with a Source are written as a reference to that source only. The elements
+// of the map's RawExtra are written after its layers, wherever they were read
+// from, and comments directly within the map, which are not decoded, are lost.
+//
+// Tile data in an encoding added with RegisterEncoding cannot be encoded;
+// such a layer is written as it was read, unless its tiles have since been
+// decoded, in which case ErrUnsupportedEncoding is returned.
+func Encode(w io.Writer, m *Map) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	e := xml.NewEncoder(w)
+	e.Indent("", " ")
+	if err := e.Encode(m); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(w, "\n")
+
+	return err
+}
+
+// MarshalXML encodes a Map as a TMX map element; see Encode.
+func (m *Map) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	start.Name = xml.Name{Local: "map"}
+	start.Attr = []xml.Attr{attr("version", m.Version)}
+	if m.Class != "" {
+		start.Attr = append(start.Attr, attr("class", m.Class))
+	}
+	start.Attr = append(start.Attr,
+		attr("orientation", m.Orientation),
+		attr("renderorder", m.RenderOrder),
+		attr("width", strconv.Itoa(m.Width)),
+		attr("height", strconv.Itoa(m.Height)),
+		attr("tilewidth", strconv.Itoa(m.TileWidth)),
+		attr("tileheight", strconv.Itoa(m.TileHeight)),
+	)
+	if m.HexSideLength != 0 {
+		start.Attr = append(start.Attr, attr("hexsidelength", strconv.Itoa(m.HexSideLength)))
+	}
+	if m.StaggerAxis != 0 {
+		start.Attr = append(start.Attr, attr("staggeraxis", string(m.StaggerAxis)))
+	}
+	if m.StaggerIndex != "" {
+		start.Attr = append(start.Attr, attr("staggerindex", m.StaggerIndex))
+	}
+	if m.BackgroundColor != "" {
+		start.Attr = append(start.Attr, attr("backgroundcolor", m.BackgroundColor))
+	}
+	if m.NextObjectID != 0 {
+		start.Attr = append(start.Attr, attr("nextobjectid", strconv.Itoa(int(m.NextObjectID))))
+	}
+	if m.NextLayerID != 0 {
+		start.Attr = append(start.Attr, attr("nextlayerid", strconv.Itoa(m.NextLayerID)))
+	}
+	if m.ParallaxOriginX != 0 {
+		start.Attr = append(start.Attr, attr("parallaxoriginx", formatFloat(m.ParallaxOriginX)))
+	}
+	if m.ParallaxOriginY != 0 {
+		start.Attr = append(start.Attr, attr("parallaxoriginy", formatFloat(m.ParallaxOriginY)))
+	}
+	if m.RawInfinite {
+		start.Attr = append(start.Attr, attr("infinite", "1"))
+	}
+
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+
+	if m.EditorSettings != nil {
+		if err := e.EncodeElement(m.EditorSettings, element("editorsettings")); err != nil {
+			return err
+		}
+	}
+
+	if err := encodeProperties(e, m.Properties); err != nil {
+		return err
+	}
+
+	for i := range m.TileSets {
+		if err := e.EncodeElement(&m.TileSets[i], element("tileset")); err != nil {
+			return err
+		}
+	}
+
+	if err := encodeElements(e, m.Layers, m.ObjectGroups, m.ImageLayers, m.Groups); err != nil {
+		return err
+	}
+
+	for i := range m.RawExtra {
+		if err := e.Encode(&m.RawExtra[i]); err != nil {
+			return err
+		}
+	}
+
+	return e.EncodeToken(start.End())
+}
+
+// MarshalXML encodes a Group as a TMX group element, with its children in
+// order of their Z.
+func (g *Group) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	start.Attr = nil
+	if g.ID != 0 {
+		start.Attr = append(start.Attr, attr("id", strconv.Itoa(g.ID)))
+	}
+	start.Attr = append(start.Attr, attr("name", g.Name))
+	if g.OffsetX != 0 {
+		start.Attr = append(start.Attr, attr("offsetx", formatFloat(g.OffsetX)))
+	}
+	if g.OffsetY != 0 {
+		start.Attr = append(start.Attr, attr("offsety", formatFloat(g.OffsetY)))
+	}
+	start.Attr = append(start.Attr,
+		attr("parallaxx", strconv.FormatFloat(float64(g.ParallaxX), 'g', -1, 32)),
+		attr("parallaxy", strconv.FormatFloat(float64(g.ParallaxY), 'g', -1, 32)),
+		attr("opacity", strconv.FormatFloat(float64(g.Opacity), 'g', -1, 32)),
+		attr("visible", formatFlag(g.Visible)),
+	)
+	if g.TintColor != "" {
+		start.Attr = append(start.Attr, attr("tintcolor", g.TintColor))
+	}
+
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+
+	if err := encodeProperties(e, g.Properties); err != nil {
+		return err
+	}
+
+	if err := encodeElements(e, g.Layers, g.ObjectGroups, g.ImageLayers, g.Groups); err != nil {
+		return err
+	}
+
+	return e.EncodeToken(start.End())
+}
+
+// MarshalXML encodes a TileSet; one with a Source is encoded as a reference to
+// that source, without its contents.
+func (ts *TileSet) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	if ts.Source != "" {
+		start.Attr = []xml.Attr{
+			attr("firstgid", strconv.FormatUint(uint64(ts.FirstGlobalID), 10)),
+			attr("source", ts.Source),
+		}
+
+		if err := e.EncodeToken(start); err != nil {
+			return err
+		}
+
+		return e.EncodeToken(start.End())
+	}
+
+	type tileSet TileSet
+
+	return e.EncodeElement((*tileSet)(ts), start)
+}
+
+// MarshalXML encodes a Layer, encoding its current tiles with the encoding and
+// compression of its RawData.
+func (l *Layer) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	type layer Layer
+
+	data, err := l.encodedData()
+	if err != nil {
+		return err
+	}
+
+	c := layer(*l)
+	c.RawData = data
+
+	// Tiled reads the visibility as 1 or 0, and ignores true and false
+	return e.EncodeElement(struct {
+		*layer
+		Visible string `xml:"visible,attr"`
+	}{&c, formatFlag(l.Visible)}, start)
+}
+
+// encodedData returns the Data of the layer with its tiles encoded afresh
+func (l *Layer) encodedData() (Data, error) {
+	if reflect.ValueOf(l.RawData).IsZero() {
+		return Data{}, nil
+	}
+
+	d := Data{Encoding: l.RawData.Encoding, Compression: l.RawData.Compression}
+
+	switch d.Encoding {
+	case "", "csv", "base64":
+	default:
+		if _, ok := lookupEncoding(d.Encoding); !ok || l.cachedTileGlobalRefs() != nil {
+			return Data{}, ErrUnsupportedEncoding
+		}
+
+		// the chunks are written as part of the raw data
+		d = l.RawData
+		d.Chunks = nil
+
+		return d, nil
+	}
+
+	chunks, err := l.Chunks()
+	if err != nil {
+		return Data{}, err
+	}
+	if chunks != nil {
+		for i := range chunks {
+			c := &chunks[i]
+			if c.TileGlobalRefs, c.RawBytes, err = d.encodePayload(c.TileGlobalRefs, c.Width); err != nil {
+				return Data{}, err
+			}
+		}
+		d.Chunks = chunks
+
+		return d, nil
+	}
+
+	trs, err := l.TileGlobalRefs()
+	if err != nil {
+		return Data{}, err
+	}
+
+	d.TileGlobalRefs, d.RawBytes, err = d.encodePayload(trs, l.Width)
+
+	return d, err
+}
+
+// encodePayload encodes the tiles in the encoding and compression of the
+// Data, in rows of the given width. Tiles without an encoding are returned as
+// they are, to be written as XML elements; otherwise, the encoded payload is
+// returned.
+func (d *Data) encodePayload(trs []TileGlobalRef, width int) ([]TileGlobalRef, []byte, error) {
+	if d.Encoding == "" {
+		return trs, nil, nil
+	}
+
+	gids := make([]GlobalID, len(trs))
+	for i := range trs {
+		gids[i] = trs[i].GlobalID
+	}
+
+	var payload []byte
+	if d.Encoding == "csv" {
+		payload = encodeCSVLayerData(gids, width)
+	} else {
+		var err error
+		if payload, err = encodeB64LayerData(gids, d.Compression); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return nil, append(append([]byte{'\n'}, payload...), '\n'), nil
+}
+
+// Encode encodes the tiles as the payload of a <data> element, in the given
+// encoding, "csv" or "base64", and compression, one of "gzip", "zlib", "zstd",
+// or "" for none; csv data cannot be compressed. This is the inverse of Bytes;
+// the Data itself is left as it is. As with Encode, zstd data is stored without
+// compression.
+//
+// This may be used to convert the tile data of a layer, which has no effect on
+// any tiles already decoded from it:
+//
+//	trs, err := l.TileGlobalRefs()
+//	// ...
+//	payload, err := l.RawData.Encode(trs, "base64", "zlib")
+//	// ...
+//	l.RawData = tmx.Data{Encoding: "base64", Compression: "zlib", RawBytes: payload}
+func (d *Data) Encode(refs []TileGlobalRef, encoding, compression string) ([]byte, error) {
+	gids := make([]GlobalID, len(refs))
+	for i := range refs {
+		gids[i] = refs[i].GlobalID
+	}
+
+	var payload []byte
+	switch encoding {
+	case "csv":
+		if compression != "" {
+			return nil, ErrUnsupportedCompression
+		}
+		payload = encodeCSVLayerData(gids, 0)
+	case "base64":
+		var err error
+		if payload, err = encodeB64LayerData(gids, compression); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, ErrUnsupportedEncoding
+	}
+
+	return payload, nil
+}
+
+// MarshalXML encodes Data, omitting it entirely when empty
+func (d Data) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	if reflect.ValueOf(d).IsZero() {
+		return nil
+	}
+
+	type data Data
+
+	return e.EncodeElement(data(d), start)
+}
+
+// MarshalXML encodes an Image, omitting it entirely when empty
+func (i Image) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	if reflect.ValueOf(i).IsZero() {
+		return nil
+	}
+
+	type image Image
+
+	return e.EncodeElement(image(i), start)
+}
+
+// MarshalXML encodes a TileOffset, omitting it entirely when empty
+func (to TileOffset) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	if to == (TileOffset{}) {
+		return nil
+	}
+
+	type tileOffset TileOffset
+
+	return e.EncodeElement(tileOffset(to), start)
+}
+
+// MarshalXML encodes a Frame, writing RawTileID when it is set, to keep any
+// flip bits, and TileID otherwise, as for frames built in code. If TileID has
+// been changed since the frame was decoded, it is written with the flip bits
+// of RawTileID.
+func (f Frame) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	if GlobalID(f.RawTileID).BareID() != uint32(f.TileID) {
+		f.RawTileID = uint32(GlobalID(f.TileID) | GlobalID(f.RawTileID)&TileFlipped)
+	}
+
+	type frame Frame
+
+	return e.EncodeElement(frame(f), start)
+}
+
+// MarshalXML encodes Transformations as Tiled does, writing each as 1 or 0
+func (t *Transformations) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	start.Attr = []xml.Attr{
+		attr("hflip", formatFlag(t.HFlip)),
+		attr("vflip", formatFlag(t.VFlip)),
+		attr("rotate", formatFlag(t.Rotate)),
+		attr("preferuntransformed", formatFlag(t.PreferUntransformed)),
+	}
+
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+
+	return e.EncodeToken(start.End())
+}
+
+// MarshalXML encodes an ObjectGroup, omitting it entirely when it is the zero
+// value, as is the collision group of a Tile without one.
+func (og ObjectGroup) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	if reflect.ValueOf(og).IsZero() {
+		return nil
+	}
+
+	type objectGroup ObjectGroup
+
+	return e.EncodeElement(struct {
+		*objectGroup
+		Visible string `xml:"visible,attr"`
+	}{(*objectGroup)(&og), formatFlag(og.Visible)}, start)
+}
+
+// MarshalXML encodes an Object, writing its visibility as Tiled does
+func (o *Object) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	type object Object
+
+	return e.EncodeElement(struct {
+		*object
+		Visible string `xml:"visible,attr"`
+	}{(*object)(o), formatFlag(o.Visible)}, start)
+}
+
+// MarshalXML encodes an ImageLayer, writing its visibility and repetition as
+// Tiled does
+func (il *ImageLayer) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	type imageLayer ImageLayer
+
+	c := struct {
+		*imageLayer
+		Visible string `xml:"visible,attr"`
+		RepeatX string `xml:"repeatx,attr,omitempty"`
+		RepeatY string `xml:"repeaty,attr,omitempty"`
+	}{imageLayer: (*imageLayer)(il), Visible: formatFlag(il.Visible)}
+	if il.RepeatX {
+		c.RepeatX = formatFlag(il.RepeatX)
+	}
+	if il.RepeatY {
+		c.RepeatY = formatFlag(il.RepeatY)
+	}
+
+	return e.EncodeElement(c, start)
+}
+
+// encodeElements writes the given layers, object groups, image layers, and
+// groups in order of their Z; those with equal Z are written in the order
+// given.
+func encodeElements(e *xml.Encoder, ls []Layer, ogs []ObjectGroup, ils []ImageLayer, gs []Group) error {
+	type layerElement struct {
+		z    int
+		name string
+		v    interface{}
+	}
+
+	var elements []layerElement
+	for i := range ls {
+		elements = append(elements, layerElement{ls[i].Z, "layer", &ls[i]})
+	}
+	for i := range ogs {
+		elements = append(elements, layerElement{ogs[i].Z, "objectgroup", &ogs[i]})
+	}
+	for i := range ils {
+		elements = append(elements, layerElement{ils[i].Z, "imagelayer", &ils[i]})
+	}
+	for i := range gs {
+		elements = append(elements, layerElement{gs[i].Z, "group", &gs[i]})
+	}
+
+	sort.SliceStable(elements, func(i, j int) bool {
+		return elements[i].z < elements[j].z
+	})
+
+	for _, el := range elements {
+		if err := e.EncodeElement(el.v, element(el.name)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// propertyList is the contents of a properties element
+type propertyList struct {
+	Properties Properties `xml:"property"`
+}
+
+func encodeProperties(e *xml.Encoder, pl Properties) error {
+	if len(pl) == 0 {
+		return nil
+	}
+
+	return e.EncodeElement(propertyList{pl}, element("properties"))
+}
+
+// MarshalXML encodes a Property, omitting its Properties when it has none, as
+// Tiled reads any properties within a property as the members of a class.
+func (p *Property) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	type property Property
+
+	// a nil pointer is omitted, where an empty slice would still be written
+	// as an empty properties element
+	var members *propertyList
+	if len(p.Properties) > 0 {
+		members = &propertyList{p.Properties}
+	}
+
+	return e.EncodeElement(struct {
+		*property
+		Properties *propertyList `xml:"properties"`
+	}{(*property)(p), members}, start)
+}
+
+func element(name string) xml.StartElement {
+	return xml.StartElement{Name: xml.Name{Local: name}}
+}
+
+func attr(name, value string) xml.Attr {
+	return xml.Attr{Name: xml.Name{Local: name}, Value: value}
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
@@ -0,0 +1,249 @@
+package tmx
+
+import (
+	"bytes"
+	"reflect"
+	"sync/atomic"
+	"testing"
+)
+
+// roundTrip encodes the map, then decodes the result
+func roundTrip(t *testing.T, m *Map) *Map {
+	var buf bytes.Buffer
+	if err := Encode(&buf, m); err != nil {
+		t.Fatal(err)
+	}
+
+	rm, err := Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return rm
+}
+
+// decodedState decodes the tiles of every layer, then clears everything left
+// from decoding that is not expected to survive encoding: the raw tile data,
+// which is compressed afresh, and the positions of elements in the document.
+func decodedState(t *testing.T, m *Map) *Map {
+	m.walk(func(e interface{}) {
+		switch e := e.(type) {
+		case *Layer:
+			trs, err := e.TileGlobalRefs()
+			if err != nil {
+				t.Fatal(err)
+			}
+			e.RawData = Data{Encoding: e.RawData.Encoding, Compression: e.RawData.Compression, TileGlobalRefs: trs}
+			e.tileGlobalRefs = atomic.Value{}
+			e.offset = 0
+		case *ObjectGroup:
+			e.offset = 0
+		case *ImageLayer:
+			e.offset = 0
+		case *Group:
+			e.offset = 0
+		}
+	})
+
+	return m
+}
+
+func TestEncodeRoundTrip(t *testing.T) {
+	for _, name := range []string{"test.tmx", "encodings.tmx", "groups.tmx", "extra.tmx", "external.tmx", "zstd.tmx", "parallax.tmx", "objects.tmx", "rotation.tmx", "class.tmx"} {
+		m := decodeFixture(t, name)
+		rm := roundTrip(t, m)
+
+		if !reflect.DeepEqual(decodedState(t, rm), decodedState(t, m)) {
+			t.Errorf("%v: expected map to survive encoding, got %+v", name, rm)
+		}
+	}
+}
+
+func TestEncodeModified(t *testing.T) {
+	m := decodeFixture(t, "encodings.tmx")
+
+	for i := range m.Layers {
+		if _, err := m.Layers[i].ReplaceGlobalID(1, 7); err != nil {
+			t.Fatal(err)
+		}
+	}
+	m.Layers[0].Visible = false
+
+	rm := roundTrip(t, m)
+
+	if rm.Layers[0].Visible {
+		t.Error("expected layer visibility to be kept")
+	}
+
+	for i := range rm.Layers {
+		gid, err := rm.Layers[i].GlobalIDAt(0, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if gid != 7 {
+			t.Errorf("%v: expected replaced tile to be encoded, got %v", rm.Layers[i].Name, gid)
+		}
+		if rm.Layers[i].RawData.Compression != m.Layers[i].RawData.Compression {
+			t.Errorf("%v: expected compression to be kept", rm.Layers[i].Name)
+		}
+	}
+}
+
+func TestEncodeVisibility(t *testing.T) {
+	m := decodeFixture(t, "test.tmx")
+	m.ObjectGroups[0].Objects[0].Visible = false
+	m.ImageLayers = []ImageLayer{{Name: "sky", Opacity: 1, Visible: false, Z: 3}}
+	m.Groups = []Group{{Name: "hidden", Opacity: 1, Visible: false, Z: 4}}
+
+	var buf bytes.Buffer
+	if err := Encode(&buf, m); err != nil {
+		t.Fatal(err)
+	}
+
+	// Tiled reads visibility as an integer, ignoring true and false
+	for _, e := range []string{
+		`<layer name="walls" width="48" height="30" opacity="1" parallaxx="1" parallaxy="1" visible="1">`,
+		`<layer name="non-solid" width="48" height="30" opacity="1" parallaxx="1" parallaxy="1" visible="0">`,
+		`<objectgroup name="obstacles" opacity="1" parallaxx="1" parallaxy="1" visible="1">`,
+		`<object id="74" type="ground" x="176" y="400" visible="0">`,
+		`<imagelayer name="sky" parallaxx="0" parallaxy="0" opacity="1" visible="0">`,
+		`<group name="hidden" parallaxx="0" parallaxy="0" opacity="1" visible="0">`,
+	} {
+		if !bytes.Contains(buf.Bytes(), []byte(e)) {
+			t.Errorf("expected %s in encoded map", e)
+		}
+	}
+	if bytes.Contains(buf.Bytes(), []byte(`visible="true"`)) || bytes.Contains(buf.Bytes(), []byte(`visible="false"`)) {
+		t.Error("expected visibility to be written as 1 or 0")
+	}
+
+	rm := roundTrip(t, m)
+	if rm.Layers[1].Visible || rm.ObjectGroups[0].Objects[0].Visible || rm.ImageLayers[0].Visible || rm.Groups[0].Visible {
+		t.Error("expected hidden elements to be decoded as hidden")
+	}
+	if !rm.Layers[0].Visible || !rm.ObjectGroups[0].Visible {
+		t.Error("expected visible elements to be decoded as visible")
+	}
+}
+
+func TestEncodeProperties(t *testing.T) {
+	m := &Map{Properties: Properties{
+		{Name: "speed", Type: "int", Value: "3"},
+		{Name: "spawn", Type: "class", PropertyType: "point", Properties: Properties{
+			{Name: "x", Type: "int", Value: "4"},
+		}},
+	}}
+
+	var buf bytes.Buffer
+	if err := Encode(&buf, m); err != nil {
+		t.Fatal(err)
+	}
+
+	// Tiled reads any properties within a property as the members of a class
+	for _, e := range []string{
+		`<property name="speed" type="int" value="3"></property>`,
+		`<property name="spawn" type="class" value="" propertytype="point">
+   <properties>
+    <property name="x" type="int" value="4"></property>
+   </properties>
+  </property>`,
+	} {
+		if !bytes.Contains(buf.Bytes(), []byte(e)) {
+			t.Errorf("expected %s in encoded map, got %s", e, buf.Bytes())
+		}
+	}
+}
+
+func TestEncodeExternalTileSet(t *testing.T) {
+	m, err := DecodeFile("fixtures/external.tmx")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := Encode(&buf, m); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Contains(buf.Bytes(), []byte(`<tileset firstgid="5" source="animated.tsx"></tileset>`)) {
+		t.Errorf("expected external tileset to be written as a reference, got %s", buf.Bytes())
+	}
+}
+
+func TestEncodeFrames(t *testing.T) {
+	m := &Map{TileSets: []TileSet{{
+		FirstGlobalID: 1,
+		Name:          "animated",
+		TileCount:     8,
+		Tiles: []Tile{{TileID: 0, Animation: []Frame{
+			{TileID: 5, DurationMsec: 100},
+			{TileID: 6, DurationMsec: 200, RawTileID: uint32(6 | TileFlippedVertically)},
+			{TileID: 7, DurationMsec: 300, RawTileID: uint32(2 | TileFlippedHorizontally)},
+		}}},
+	}}}
+
+	rm := roundTrip(t, m)
+
+	frames := rm.TileSets[0].Tiles[0].Animation
+	if l := len(frames); l != 3 {
+		t.Fatalf("expected 3 frames, got %v", l)
+	}
+	for i, e := range []struct {
+		id       TileID
+		duration int
+		h, v     bool
+	}{
+		{5, 100, false, false},
+		{6, 200, false, true},
+		{7, 300, true, false},
+	} {
+		f := frames[i]
+		if h, v, _ := f.Flips(); f.TileID != e.id || f.DurationMsec != e.duration || h != e.h || v != e.v {
+			t.Errorf("frame %v: expected %+v, got %+v", i, e, f)
+		}
+	}
+}
+
+func TestDataEncode(t *testing.T) {
+	trs := []TileGlobalRef{{1}, {0}, {7 | TileFlippedHorizontally}, {1 << 20}}
+
+	for _, c := range []struct {
+		encoding, compression string
+	}{
+		{"csv", ""},
+		{"base64", ""},
+		{"base64", "gzip"},
+		{"base64", "zlib"},
+		{"base64", "zstd"},
+	} {
+		d := Data{Encoding: "csv", RawBytes: []byte("1,2,3,4")}
+		payload, err := d.Encode(trs, c.encoding, c.compression)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if d.Encoding != "csv" || d.Compression != "" || string(d.RawBytes) != "1,2,3,4" {
+			t.Errorf("%v %v: expected data to be left as it is, got %+v", c.encoding, c.compression, d)
+		}
+
+		l := Layer{Width: 2, Height: 2, RawData: Data{Encoding: c.encoding, Compression: c.compression, RawBytes: payload}}
+		decoded, err := l.TileGlobalRefs()
+		if err != nil {
+			t.Fatalf("%v %v: %v", c.encoding, c.compression, err)
+		}
+		if !reflect.DeepEqual(decoded, trs) {
+			t.Errorf("%v %v: expected %v, got %v", c.encoding, c.compression, trs, decoded)
+		}
+	}
+
+	var d Data
+	if _, err := d.Encode(trs, "csv", "gzip"); err != ErrUnsupportedCompression {
+		t.Errorf("expected %v, got %v", ErrUnsupportedCompression, err)
+	}
+	if _, err := d.Encode(trs, "base64", "lz4"); err != ErrUnsupportedCompression {
+		t.Errorf("expected %v, got %v", ErrUnsupportedCompression, err)
+	}
+	if _, err := d.Encode(trs, "xml", ""); err != ErrUnsupportedEncoding {
+		t.Errorf("expected %v, got %v", ErrUnsupportedEncoding, err)
+	}
+}
@@ -0,0 +1,22 @@
+<?xml version="1.0" encoding="UTF-8"?>
+<tileset version="1.0" tiledversion="1.0.2" name="animated" tilewidth="16" tileheight="16" tilecount="8" columns="4">
+ <properties>
+  <property name="speed" type="float" value="1.5"/>
+ </properties>
+ <image source="animated.png" width="64" height="32"/>
+ <tile id="0">
+  <properties>
+   <property name="water" type="bool" value="true"/>
+  </properties>
+  <animation>
+   <frame tileid="0" duration="100"/>
+   <frame tileid="1" duration="200"/>
+   <frame tileid="2" duration="300"/>
+  </animation>
+ </tile>
+ <tile id="4">
+  <objectgroup draworder="index">
+   <object id="1" x="0" y="8" width="16" height="8"/>
+  </objectgroup>
+ </tile>
+</tileset>
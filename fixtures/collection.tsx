@@ -0,0 +1,16 @@
+<?xml version="1.0" encoding="UTF-8"?>
+<tileset version="1.10" tiledversion="1.10.2" name="decorations" tilewidth="64" tileheight="48" tilecount="3" columns="0">
+ <grid orientation="orthogonal" width="1" height="1"/>
+ <tile id="0">
+  <image width="32" height="48" source="tree.png"/>
+ </tile>
+ <tile id="1">
+  <image width="64" height="32" source="rock.png"/>
+ </tile>
+ <tile id="3">
+  <properties>
+   <property name="bloom" type="bool" value="true"/>
+  </properties>
+  <image width="16" height="16" source="flower.png"/>
+ </tile>
+</tileset>
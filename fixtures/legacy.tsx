@@ -0,0 +1,4 @@
+<?xml version="1.0" encoding="UTF-8"?>
+<tileset name="legacy" tilewidth="16" tileheight="16" spacing="2" margin="1">
+ <image source="legacy.png" width="55" height="37"/>
+</tileset>
@@ -0,0 +1,15 @@
+<?xml version="1.0" encoding="UTF-8"?>
+<tileset version="1.0" tiledversion="1.0.2" name="nested" tilewidth="16" tileheight="16" tilecount="4" columns="2">
+ <image source="nested.png" width="32" height="32"/>
+ <tile id="1">
+  <properties>
+   <property name="spike" type="bool" value="true"/>
+  </properties>
+ </tile>
+ <tile id="3">
+  <objectgroup draworder="index">
+   <object id="1" x="0" y="0" width="16" height="4"/>
+   <object id="2" gid="2147483650" x="0" y="16" width="16" height="16"/>
+  </objectgroup>
+ </tile>
+</tileset>
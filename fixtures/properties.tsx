@@ -0,0 +1,25 @@
+<?xml version="1.0" encoding="UTF-8"?>
+<tileset version="1.0" tiledversion="1.0.2" name="props" tilewidth="16" tileheight="16" tilecount="4" columns="2">
+ <properties>
+  <property name="layer" type="int" value="3"/>
+  <property name="solid" type="bool" value="true"/>
+ </properties>
+ <image source="props.png" width="32" height="32"/>
+ <tile id="2">
+  <properties>
+   <property name="damage" type="int" value="-7"/>
+   <property name="lava" type="bool" value="true"/>
+   <property name="facing" type="string" propertytype="Direction" value="north"/>
+   <property name="stats" type="class" propertytype="Stats">
+    <properties>
+     <property name="hp" type="int" value="10"/>
+     <property name="resist" type="class" propertytype="Resistances">
+      <properties>
+       <property name="fire" type="float" value="0.5"/>
+      </properties>
+     </property>
+    </properties>
+   </property>
+  </properties>
+ </tile>
+</tileset>
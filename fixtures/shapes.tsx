@@ -0,0 +1,22 @@
+<?xml version="1.0" encoding="UTF-8"?>
+<tileset version="1.10" tiledversion="1.10.2" name="shapes" tilewidth="16" tileheight="16" tilecount="4" columns="2">
+ <image source="shapes.png" width="32" height="32"/>
+ <tile id="0">
+  <objectgroup draworder="index" id="2">
+   <object id="1" name="roof" x="2" y="4">
+    <polygon points="0,0 12,0 6,-4"/>
+   </object>
+   <object id="2" name="boulder" x="1" y="6" width="6" height="6">
+    <ellipse/>
+   </object>
+   <object id="3" name="anchor" x="8" y="8">
+    <point/>
+   </object>
+   <object id="4" name="ledge" x="0" y="12">
+    <polyline points="0,0 16,0"/>
+   </object>
+   <object id="5" name="floor" x="0" y="14" width="16" height="2"/>
+  </objectgroup>
+ </tile>
+ <tile id="1"/>
+</tileset>
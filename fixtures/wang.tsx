@@ -0,0 +1,22 @@
+<?xml version="1.0" encoding="UTF-8"?>
+<tileset version="1.10" tiledversion="1.10.1" name="wang" tilewidth="16" tileheight="16" tilecount="4" columns="2">
+ <transformations hflip="1" vflip="0" rotate="1" preferuntransformed="1"/>
+ <image source="wang.png" width="32" height="32"/>
+ <tile id="1" probability="0.25">
+  <properties>
+   <property name="surface" value="dirt"/>
+  </properties>
+ </tile>
+ <wangsets>
+  <wangset name="paths" type="corner" tile="0">
+   <properties>
+    <property name="auto" type="bool" value="true"/>
+   </properties>
+   <wangcolor name="grass" color="#00ff00" tile="0" probability="0.5"/>
+   <wangcolor name="dirt" color="#804000" tile="-1"/>
+   <wangtile tileid="0" wangid="0,1,0,1,0,1,0,1"/>
+   <wangtile tileid="1" wangid="0,2,0,2,0,2,0,2"/>
+   <wangtile tileid="2" wangid="0,1,0,2,0,2,0,1"/>
+  </wangset>
+ </wangsets>
+</tileset>
@@ -0,0 +1,37 @@
+package tmx
+
+import "errors"
+
+var errFrozen = errors.New("tmx: cannot modify a frozen map")
+
+// Freeze decodes the tile data and TileDefs of every layer of the map,
+// including those within groups, then marks the map as frozen and returns it.
+// Once frozen, all lazy decoding is complete, so the map may be read from any
+// number of goroutines without locking; in exchange, the helpers which modify
+// a map, its layers, or its object groups panic when called.
+//
+// A layer whose data fails to decode is left as it was, and will return the
+// same error when read; such a layer is not safe for concurrent reads. Fields
+// may still be assigned directly, which Freeze cannot prevent.
+func (m *Map) Freeze() *Map {
+	m.walk(func(e interface{}) {
+		switch e := e.(type) {
+		case *Layer:
+			if _, err := e.TileGlobalRefs(); err == nil {
+				e.mapTileDefs(m)
+			}
+			e.frozen = true
+		case *ObjectGroup:
+			e.frozen = true
+		}
+	})
+
+	m.frozen = true
+
+	return m
+}
+
+// Frozen returns true if the map has been frozen with Freeze
+func (m *Map) Frozen() bool {
+	return m.frozen
+}
@@ -0,0 +1,71 @@
+package tmx
+
+import (
+	"sync"
+	"testing"
+)
+
+func expectPanic(t *testing.T, name string, fn func()) {
+	defer func() {
+		if r := recover(); r != errFrozen {
+			t.Errorf("%v: expected to panic with %v, got %v", name, errFrozen, r)
+		}
+	}()
+
+	fn()
+}
+
+func TestFreeze(t *testing.T) {
+	m := decodeFixture(t, "test.tmx").Freeze()
+
+	if !m.Frozen() {
+		t.Error("expected map to be frozen")
+	}
+
+	walls := m.LayerWithName("walls")
+	if walls.cachedTileDefs() == nil {
+		t.Error("expected tile defs to be decoded when frozen")
+	}
+
+	expectPanic(t, "ReplaceTile", func() { walls.ReplaceTile(128, 1) })
+	expectPanic(t, "ReplaceGlobalID", func() { walls.ReplaceGlobalID(128, 1) })
+	expectPanic(t, "Translate", func() { m.ObjectGroups[0].Translate(1, 1) })
+
+	// copies are not frozen
+	moved := m.ObjectGroups[0].TranslatedCopy(1, 1)
+	moved.Translate(1, 1)
+
+	c, err := m.Crop(0, 0, 2, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Frozen() {
+		t.Error("expected a cropped map not to be frozen")
+	}
+	if _, err := c.LayerWithName("walls").ReplaceTile(143, 1); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestFreezeConcurrentReads(t *testing.T) {
+	m := decodeFixture(t, "test.tmx").Freeze()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for j := range m.Layers {
+				l := &m.Layers[j]
+				if _, err := l.TileDefs(m.TileSets); err != nil {
+					t.Error(err)
+				}
+				if _, err := l.GlobalIDAt(1, 1); err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
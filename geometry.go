@@ -0,0 +1,342 @@
+package tmx
+
+import (
+	"image"
+	"math"
+)
+
+// ellipseSegments is the number of edges used to approximate an ellipse
+const ellipseSegments = 32
+
+type vec struct {
+	x, y float64
+}
+
+func (a vec) sub(b vec) vec       { return vec{a.x - b.x, a.y - b.y} }
+func (a vec) dot(b vec) float64   { return a.x*b.x + a.y*b.y }
+func (a vec) cross(b vec) float64 { return a.x*b.y - a.y*b.x }
+
+// shape is the outline of an object in map pixel coordinates; closed shapes
+// enclose an area, while open shapes are polylines or single points.
+type shape struct {
+	pts    []vec
+	closed bool
+}
+
+// edges calls fn for every edge of the shape; a single point is treated as an
+// edge of zero length.
+func (s shape) edges(fn func(a, b vec) bool) bool {
+	n := len(s.pts)
+	if n == 1 {
+		return fn(s.pts[0], s.pts[0])
+	}
+
+	for i := 0; i+1 < n; i++ {
+		if fn(s.pts[i], s.pts[i+1]) {
+			return true
+		}
+	}
+
+	if s.closed && n > 2 {
+		return fn(s.pts[n-1], s.pts[0])
+	}
+
+	return false
+}
+
+// outline returns the shape of the object in map coordinates, rotated
+// clockwise about its origin by its Rotation. Ellipses are approximated by a
+// polygon.
+func (o *Object) outline() (s shape, err error) {
+	origin := vec{o.X, o.Y}
+
+	switch {
+	case len(o.Polygons) > 0 || len(o.Polylines) > 0:
+		p := o.Polylines
+		if s.closed = len(o.Polygons) > 0; s.closed {
+			p = o.Polygons
+		}
+
+		pts, err := p[0].PointsF()
+		if err != nil {
+			return s, err
+		}
+
+		for _, pt := range pts {
+			s.pts = append(s.pts, vec{pt.X, pt.Y})
+		}
+	case o.Point():
+		s.pts = []vec{{0, 0}}
+	case o.Ellipse():
+		s.closed = true
+		rx, ry := o.Width/2, o.Height/2
+		for i := 0; i < ellipseSegments; i++ {
+			a := 2 * math.Pi * float64(i) / ellipseSegments
+			s.pts = append(s.pts, vec{rx + rx*math.Cos(a), ry + ry*math.Sin(a)})
+		}
+	default:
+		// tile objects are aligned to their bottom-left corner
+		y := 0.0
+		if o.GlobalID != 0 {
+			y = -o.Height
+		}
+
+		s.closed = true
+		s.pts = rectCorners(o.Width, o.Height, y)
+	}
+
+	sin, cos := math.Sincos(o.Rotation * math.Pi / 180)
+	for i, p := range s.pts {
+		s.pts[i] = vec{
+			origin.x + p.x*cos - p.y*sin,
+			origin.y + p.x*sin + p.y*cos,
+		}
+	}
+
+	return s, nil
+}
+
+// rectCorners returns the corners of a w by h rectangle whose top edge is at
+// y, relative to the origin it is rotated about, in clockwise order.
+func rectCorners(w, h, y float64) []vec {
+	return []vec{{0, y}, {w, y}, {w, y + h}, {0, y + h}}
+}
+
+// bounds is an axis-aligned bounding box in map pixel coordinates
+type bounds struct {
+	minX, minY, maxX, maxY float64
+	set                    bool
+}
+
+func (b *bounds) add(x, y float64) {
+	if !b.set {
+		b.minX, b.minY, b.maxX, b.maxY = x, y, x, y
+		b.set = true
+		return
+	}
+
+	b.minX = math.Min(b.minX, x)
+	b.minY = math.Min(b.minY, y)
+	b.maxX = math.Max(b.maxX, x)
+	b.maxY = math.Max(b.maxY, y)
+}
+
+func (b *bounds) union(o bounds) {
+	if o.set {
+		b.add(o.minX, o.minY)
+		b.add(o.maxX, o.maxY)
+	}
+}
+
+func (b bounds) rect() image.Rectangle {
+	if !b.set {
+		return image.Rectangle{}
+	}
+
+	// allow for error introduced by rotation before rounding outward
+	const epsilon = 1e-9
+
+	return image.Rect(
+		int(math.Floor(b.minX+epsilon)),
+		int(math.Floor(b.minY+epsilon)),
+		int(math.Ceil(b.maxX-epsilon)),
+		int(math.Ceil(b.maxY-epsilon)),
+	)
+}
+
+func (o *Object) bounds() (b bounds, err error) {
+	s, err := o.outline()
+	if err != nil {
+		return b, err
+	}
+
+	for _, p := range s.pts {
+		b.add(p.x, p.y)
+	}
+
+	return b, nil
+}
+
+// BoundingBox returns the axis-aligned bounding box of the object in map
+// pixel coordinates, covering its polygon or polyline, or its extent for
+// rectangles, ellipses, text and tile objects, after rotation. Point objects
+// yield an empty rectangle at their position. Fractional coordinates are
+// rounded outward.
+func (o *Object) BoundingBox() (image.Rectangle, error) {
+	b, err := o.bounds()
+	if err != nil {
+		return image.Rectangle{}, err
+	}
+
+	return b.rect(), nil
+}
+
+// ObjectsBounds returns the union of the bounding boxes of every object in
+// every ObjectGroup of the map, including those within groups, or an empty
+// rectangle if there are none. Group and object group offsets are not applied.
+func (m *Map) ObjectsBounds() (image.Rectangle, error) {
+	var b bounds
+	var err error
+	m.walk(func(e interface{}) {
+		og, ok := e.(*ObjectGroup)
+		if !ok || err != nil {
+			return
+		}
+
+		for j := range og.Objects {
+			var ob bounds
+			if ob, err = og.Objects[j].bounds(); err != nil {
+				return
+			}
+
+			b.union(ob)
+		}
+	})
+	if err != nil {
+		return image.Rectangle{}, err
+	}
+
+	return b.rect(), nil
+}
+
+// Intersects returns true if the shapes of the two objects overlap or touch,
+// in map coordinates and accounting for rotation. Rectangles, tile objects,
+// polygons, polylines, points, and ellipses (approximated by a polygon) are
+// all supported; text objects are treated as their rectangle. Convex shapes
+// are tested using the separating axis theorem, while concave polygons and
+// polylines are tested edge by edge. Objects whose points fail to parse never
+// intersect.
+func (o *Object) Intersects(other *Object) bool {
+	a, err := o.outline()
+	if err != nil {
+		return false
+	}
+	b, err := other.outline()
+	if err != nil {
+		return false
+	}
+
+	var ab, bb bounds
+	for _, p := range a.pts {
+		ab.add(p.x, p.y)
+	}
+	for _, p := range b.pts {
+		bb.add(p.x, p.y)
+	}
+	if ab.maxX < bb.minX || bb.maxX < ab.minX || ab.maxY < bb.minY || bb.maxY < ab.minY {
+		return false
+	}
+
+	if a.convex() && b.convex() {
+		return !separated(a, b) && !separated(b, a)
+	}
+
+	if a.edges(func(a1, a2 vec) bool {
+		return b.edges(func(b1, b2 vec) bool {
+			return segmentsIntersect(a1, a2, b1, b2)
+		})
+	}) {
+		return true
+	}
+
+	return a.contains(b.pts[0]) || b.contains(a.pts[0])
+}
+
+// convex returns true if the shape is a closed, convex polygon
+func (s shape) convex() bool {
+	n := len(s.pts)
+	if !s.closed || n < 3 {
+		return false
+	}
+
+	sign := 0.0
+	for i := range s.pts {
+		c := s.pts[(i+1)%n].sub(s.pts[i]).cross(s.pts[(i+2)%n].sub(s.pts[(i+1)%n]))
+		if c == 0 {
+			continue
+		}
+		if sign != 0 && (c > 0) != (sign > 0) {
+			return false
+		}
+		sign = c
+	}
+
+	return true
+}
+
+// separated returns true if an edge normal of a separates the two shapes
+func separated(a, b shape) bool {
+	return a.edges(func(p1, p2 vec) bool {
+		d := p2.sub(p1)
+		axis := vec{-d.y, d.x}
+
+		amin, amax := project(a.pts, axis)
+		bmin, bmax := project(b.pts, axis)
+
+		return amax < bmin || bmax < amin
+	})
+}
+
+func project(pts []vec, axis vec) (min, max float64) {
+	min, max = math.Inf(1), math.Inf(-1)
+	for _, p := range pts {
+		d := p.dot(axis)
+		min, max = math.Min(min, d), math.Max(max, d)
+	}
+
+	return min, max
+}
+
+// contains returns true if p lies within the closed shape, using the even-odd
+// rule; open shapes contain nothing.
+func (s shape) contains(p vec) bool {
+	if !s.closed || len(s.pts) < 3 {
+		return false
+	}
+
+	in := false
+	for i, j := 0, len(s.pts)-1; i < len(s.pts); j, i = i, i+1 {
+		a, b := s.pts[i], s.pts[j]
+		if (a.y > p.y) != (b.y > p.y) && p.x < (b.x-a.x)*(p.y-a.y)/(b.y-a.y)+a.x {
+			in = !in
+		}
+	}
+
+	return in
+}
+
+// segmentsIntersect returns true if the segments a1-a2 and b1-b2 share any
+// point, including when either has zero length.
+func segmentsIntersect(a1, a2, b1, b2 vec) bool {
+	d1 := orientation(b1, b2, a1)
+	d2 := orientation(b1, b2, a2)
+	d3 := orientation(a1, a2, b1)
+	d4 := orientation(a1, a2, b2)
+
+	if d1*d2 < 0 && d3*d4 < 0 {
+		return true
+	}
+
+	return (d1 == 0 && onSegment(b1, b2, a1)) ||
+		(d2 == 0 && onSegment(b1, b2, a2)) ||
+		(d3 == 0 && onSegment(a1, a2, b1)) ||
+		(d4 == 0 && onSegment(a1, a2, b2))
+}
+
+func orientation(a, b, p vec) float64 {
+	c := b.sub(a).cross(p.sub(a))
+	switch {
+	case c > 0:
+		return 1
+	case c < 0:
+		return -1
+	}
+
+	return 0
+}
+
+// onSegment returns true if p, known to be collinear with a-b, lies on it
+func onSegment(a, b, p vec) bool {
+	return math.Min(a.x, b.x) <= p.x && p.x <= math.Max(a.x, b.x) &&
+		math.Min(a.y, b.y) <= p.y && p.y <= math.Max(a.y, b.y)
+}
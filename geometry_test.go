@@ -0,0 +1,229 @@
+package tmx
+
+import (
+	"encoding/xml"
+	"image"
+	"testing"
+)
+
+func TestObjectBoundingBox(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		o    Object
+		exp  image.Rectangle
+	}{
+		{
+			"rectangle",
+			Object{X: 10, Y: 20, Width: 30, Height: 40},
+			image.Rect(10, 20, 40, 60),
+		},
+		{
+			"fractional",
+			Object{X: 10.5, Y: 20.25, Width: 1, Height: 1},
+			image.Rect(10, 20, 12, 22),
+		},
+		{
+			"ellipse",
+			Object{X: 1, Y: 2, Width: 3, Height: 4, RawExtra: []Tag{{XMLName: xml.Name{Local: "ellipse"}}}},
+			image.Rect(1, 2, 4, 6),
+		},
+		{
+			"tile",
+			Object{X: 16, Y: 32, Width: 16, Height: 16, GlobalID: 1},
+			image.Rect(16, 16, 32, 32),
+		},
+		{
+			"polygon",
+			Object{X: 100, Y: 100, Polygons: []Poly{{"0,0 10,-5 -3,8"}}},
+			image.Rect(97, 95, 110, 108),
+		},
+		{
+			"point",
+			Object{X: 5, Y: 6},
+			image.Rect(5, 6, 5, 6),
+		},
+	} {
+		r, err := c.o.BoundingBox()
+		if err != nil {
+			t.Errorf("%v: unexpected error %v", c.name, err)
+		}
+		if r != c.exp {
+			t.Errorf("%v: expected %v, got %v", c.name, c.exp, r)
+		}
+	}
+}
+
+func TestMapObjectsBounds(t *testing.T) {
+	m := decodeFixture(t, "test.tmx")
+
+	r, err := m.ObjectsBounds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := image.Rect(48, 0, 720, 480); r != e {
+		t.Errorf("expected %v, got %v", e, r)
+	}
+
+	r, err = (&Map{}).ObjectsBounds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.Empty() {
+		t.Errorf("expected an empty rectangle, got %v", r)
+	}
+}
+
+func TestObjectIntersects(t *testing.T) {
+	var (
+		ellipse = []Tag{{XMLName: xml.Name{Local: "ellipse"}}}
+		point   = []Tag{{XMLName: xml.Name{Local: "point"}}}
+	)
+
+	for _, c := range []struct {
+		name string
+		a, b Object
+		exp  bool
+	}{
+		{
+			"overlapping rectangles",
+			Object{X: 0, Y: 0, Width: 10, Height: 10},
+			Object{X: 5, Y: 5, Width: 10, Height: 10},
+			true,
+		},
+		{
+			"touching rectangles",
+			Object{X: 0, Y: 0, Width: 10, Height: 10},
+			Object{X: 10, Y: 0, Width: 10, Height: 10},
+			true,
+		},
+		{
+			"separate rectangles",
+			Object{X: 0, Y: 0, Width: 10, Height: 10},
+			Object{X: 11, Y: 0, Width: 10, Height: 10},
+			false,
+		},
+		{
+			"rotated rectangle clear of the corner",
+			// a 10x10 square rotated 45 degrees about 0,0 spans x -7.07 to 7.07
+			Object{X: 0, Y: 0, Width: 10, Height: 10, Rotation: 45},
+			Object{X: 5, Y: 0, Width: 5, Height: 2},
+			false,
+		},
+		{
+			"rotated rectangle",
+			Object{X: 0, Y: 0, Width: 10, Height: 10, Rotation: 45},
+			Object{X: -1, Y: 6, Width: 2, Height: 2},
+			true,
+		},
+		{
+			"ellipse clear of a rectangle in its bounding box",
+			Object{X: 0, Y: 0, Width: 10, Height: 10, RawExtra: ellipse},
+			Object{X: 8.6, Y: 8.6, Width: 2, Height: 2},
+			false,
+		},
+		{
+			"ellipse and rectangle",
+			Object{X: 0, Y: 0, Width: 10, Height: 10, RawExtra: ellipse},
+			Object{X: 7, Y: 7, Width: 2, Height: 2},
+			true,
+		},
+		{
+			"rectangle in the notch of a concave polygon",
+			Object{X: 0, Y: 0, Polygons: []Poly{{"0,0 10,0 10,2 2,2 2,10 0,10"}}},
+			Object{X: 4, Y: 4, Width: 4, Height: 4},
+			false,
+		},
+		{
+			"rectangle in the arm of a concave polygon",
+			Object{X: 0, Y: 0, Polygons: []Poly{{"0,0 10,0 10,2 2,2 2,10 0,10"}}},
+			Object{X: 0.5, Y: 5, Width: 1, Height: 1},
+			true,
+		},
+		{
+			"point inside a polygon",
+			Object{X: 3, Y: 3, RawExtra: point},
+			Object{X: 0, Y: 0, Polygons: []Poly{{"0,0 10,0 0,10"}}},
+			true,
+		},
+		{
+			"point outside a polygon",
+			Object{X: 6, Y: 6, RawExtra: point},
+			Object{X: 0, Y: 0, Polygons: []Poly{{"0,0 10,0 0,10"}}},
+			false,
+		},
+		{
+			"polyline crossing a rectangle",
+			Object{X: 0, Y: 5, Polylines: []Poly{{"0,0 20,0"}}},
+			Object{X: 5, Y: 0, Width: 2, Height: 10},
+			true,
+		},
+		{
+			"polyline inside a rectangle",
+			Object{X: 1, Y: 1, Polylines: []Poly{{"0,0 2,2"}}},
+			Object{X: 0, Y: 0, Width: 10, Height: 10},
+			true,
+		},
+		{
+			"rectangle inside a polyline",
+			Object{X: 0, Y: 0, Polylines: []Poly{{"0,0 10,0 10,10 0,10"}}},
+			Object{X: 4, Y: 4, Width: 2, Height: 2},
+			false,
+		},
+		{
+			"equal points",
+			Object{X: 3, Y: 3, RawExtra: point},
+			Object{X: 3, Y: 3, RawExtra: point},
+			true,
+		},
+		{
+			"tile objects aligned bottom-left",
+			Object{X: 0, Y: 16, Width: 16, Height: 16, GlobalID: 1},
+			Object{X: 4, Y: 0, Width: 2, Height: 2},
+			true,
+		},
+	} {
+		if v := c.a.Intersects(&c.b); v != c.exp {
+			t.Errorf("%v: expected %v, got %v", c.name, c.exp, v)
+		}
+		if v := c.b.Intersects(&c.a); v != c.exp {
+			t.Errorf("%v (reversed): expected %v, got %v", c.name, c.exp, v)
+		}
+	}
+}
+
+func TestObjectBoundingBoxRotated(t *testing.T) {
+	o := Object{X: 10, Y: 10, Width: 10, Height: 20, Rotation: 90}
+
+	r, err := o.BoundingBox()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := image.Rect(-10, 10, 10, 20); r != e {
+		t.Errorf("expected %v, got %v", e, r)
+	}
+}
+
+func TestObjectFractionalRotation(t *testing.T) {
+	rotated := decodeFixture(t, "rotation.tmx").ObjectGroupWithName("rotated")
+
+	for _, c := range []struct {
+		name     string
+		rotation float64
+	}{
+		{"half", 45.5},
+		{"slight", 12.7},
+		{"back", -90},
+	} {
+		if o := rotated.Objects.WithName(c.name); o.Rotation != c.rotation {
+			t.Errorf("%v: expected rotation %v, got %v", c.name, c.rotation, o.Rotation)
+		}
+	}
+
+	r, err := rotated.Objects.WithName("back").BoundingBox()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := image.Rect(32, 48, 48, 64); r != e {
+		t.Errorf("expected %v, got %v", e, r)
+	}
+}
@@ -0,0 +1,83 @@
+package tmx
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+)
+
+// jsonGrid is a minimal JSON representation of a layer's tiles
+type jsonGrid struct {
+	Width  int        `json:"width"`
+	Height int        `json:"height"`
+	Data   [][]uint32 `json:"data"`
+}
+
+// MarshalGridJSON encodes the tiles of the layer as a minimal JSON document of
+// the form `{"width":2,"height":1,"data":[[1,2]]}`, where data holds a row for
+// each line of tiles, top to bottom, of GlobalIDs including their flip flags.
+// This is a lightweight format for tools which only deal with tile IDs; it is
+// not the Tiled JSON map format.
+func (l *Layer) MarshalGridJSON() ([]byte, error) {
+	trs, err := l.TileGlobalRefs()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(trs) != l.Width*l.Height {
+		return nil, fmt.Errorf(
+			"expected %v tiles in layer %v, got %v",
+			l.Width*l.Height, l.Name, len(trs),
+		)
+	}
+
+	g := jsonGrid{
+		Width:  l.Width,
+		Height: l.Height,
+		Data:   make([][]uint32, l.Height),
+	}
+	for y := range g.Data {
+		g.Data[y] = make([]uint32, l.Width)
+		for x := range g.Data[y] {
+			g.Data[y][x] = uint32(trs[x+y*l.Width].GlobalID)
+		}
+	}
+
+	return json.Marshal(g)
+}
+
+// UnmarshalGridJSON replaces the size and tiles of the layer with those of a
+// document produced by MarshalGridJSON. The tiles are stored as XML tile data
+// on the layer's RawData, and any cached decoded data is discarded.
+func (l *Layer) UnmarshalGridJSON(b []byte) error {
+	if l.frozen {
+		panic(errFrozen)
+	}
+
+	var g jsonGrid
+	if err := json.Unmarshal(b, &g); err != nil {
+		return err
+	}
+
+	if len(g.Data) != g.Height {
+		return fmt.Errorf("expected %v rows of tiles, got %v", g.Height, len(g.Data))
+	}
+
+	trs := make([]TileGlobalRef, 0, g.Width*g.Height)
+	for y, row := range g.Data {
+		if len(row) != g.Width {
+			return fmt.Errorf("expected %v tiles in row %v, got %v", g.Width, y, len(row))
+		}
+
+		for _, gid := range row {
+			trs = append(trs, TileGlobalRef{GlobalID(gid)})
+		}
+	}
+
+	l.Width, l.Height = g.Width, g.Height
+	l.RawData = Data{TileGlobalRefs: trs}
+	l.tileGlobalRefs = atomic.Value{}
+	l.tileDefs = atomic.Value{}
+
+	return nil
+}
@@ -0,0 +1,53 @@
+package tmx
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGridJSON(t *testing.T) {
+	m := decodeFixture(t, "collision.tmx")
+
+	b, err := m.LayerWithName("walls").MarshalGridJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := `{"width":4,"height":3,"data":[[1,1,1,1],[2,0,3,1],[1,0,0,4]]}`
+	if string(b) != exp {
+		t.Errorf("expected %v, got %v", exp, string(b))
+	}
+
+	var l Layer
+	if err := l.UnmarshalGridJSON(b); err != nil {
+		t.Fatal(err)
+	}
+
+	orig, _ := m.LayerWithName("walls").TileGlobalRefs()
+	trs, err := l.TileGlobalRefs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.Width != 4 || l.Height != 3 || !reflect.DeepEqual(trs, orig) {
+		t.Errorf("expected round-tripped tiles to match, got %vx%v %v", l.Width, l.Height, trs)
+	}
+
+	flipped := `{"width":1,"height":1,"data":[[2147483649]]}`
+	if err := l.UnmarshalGridJSON([]byte(flipped)); err != nil {
+		t.Fatal(err)
+	}
+	if gid, _ := l.GlobalIDAt(0, 0); !gid.IsFlippedHorizontally() || gid.BareID() != 1 {
+		t.Errorf("expected a flipped tile, got %v", gid)
+	}
+
+	for _, bad := range []string{
+		`{"width":2,"height":1,"data":[[1]]}`,
+		`{"width":1,"height":2,"data":[[1]]}`,
+		`{"width":1,"height":1,"data":[[-1]]}`,
+		`[]`,
+	} {
+		if err := l.UnmarshalGridJSON([]byte(bad)); err == nil {
+			t.Errorf("%v: expected an error", bad)
+		}
+	}
+}
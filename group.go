@@ -0,0 +1,221 @@
+package tmx
+
+import (
+	"encoding/xml"
+	"sort"
+)
+
+// Group is a group layer, which holds other layers, including further groups.
+// The offset, opacity, and visibility of a group apply to all of its children
+// in addition to their own.
+type Group struct {
+	ID           int           `xml:"id,attr,omitempty"`
+	Name         string        `xml:"name,attr"`
+	OffsetX      float64       `xml:"offsetx,attr"`
+	OffsetY      float64       `xml:"offsety,attr"`
+	ParallaxX    float32       `xml:"parallaxx,attr"`
+	ParallaxY    float32       `xml:"parallaxy,attr"`
+	TintColor    string        `xml:"tintcolor,attr,omitempty"`
+	Opacity      float32       `xml:"opacity,attr"`
+	Visible      bool          `xml:"visible,attr"`
+	Properties   Properties    `xml:"properties>property"`
+	Layers       []Layer       `xml:"layer"`
+	ObjectGroups []ObjectGroup `xml:"objectgroup"`
+	ImageLayers  []ImageLayer  `xml:"imagelayer"`
+	Groups       []Group       `xml:"group"`
+
+	// Z is the position of the group in the draw order of the whole map
+	Z int `xml:"-"`
+
+	// position of the element in the decoded document
+	offset int64
+}
+
+// UnmarshalXML decodes a Group, defaulting Opacity to 1 and Visible to true
+// when the attributes are absent, as Tiled does.
+func (g *Group) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type group Group
+	g.Opacity = 1
+	g.Visible = true
+	g.ParallaxX, g.ParallaxY = 1, 1
+	g.offset = d.InputOffset()
+
+	return decodeError(d, start, d.DecodeElement((*group)(g), &start))
+}
+
+// LayerOffset returns the offset of the group, in pixels
+func (g *Group) LayerOffset() (x, y float64) {
+	return g.OffsetX, g.OffsetY
+}
+
+// LayerParallax returns the parallax scrolling factors of the group
+func (g *Group) LayerParallax() (x, y float64) {
+	return float64(g.ParallaxX), float64(g.ParallaxY)
+}
+
+// UnmarshalXML decodes a Map, then numbers every layer, object group, image
+// layer, and group by its position in the document, as the Z of each. Tiled
+// draws layers in document order, which is otherwise lost when they are split
+// into separate slices. Tile layers also take note of the map's RenderOrder
+// and ChunkSize, and their tile data is parsed.
+func (m *Map) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	return m.decodeXML(d, start, Options{})
+}
+
+// decodeXML decodes a Map as UnmarshalXML does, with the given Options
+func (m *Map) decodeXML(d *xml.Decoder, start xml.StartElement, opts Options) error {
+	// the stagger axis is written as a letter, which cannot be decoded into
+	// a rune by encoding/xml
+	attrs := start.Attr[:0:0]
+	for _, a := range start.Attr {
+		if a.Name.Local == "staggeraxis" {
+			if a.Value != "" {
+				m.StaggerAxis = rune(a.Value[0])
+			}
+			continue
+		}
+		attrs = append(attrs, a)
+	}
+	start.Attr = attrs
+
+	type tmxMap Map
+	if err := d.DecodeElement((*tmxMap)(m), &start); err != nil {
+		return decodeError(d, start, err)
+	}
+
+	type element struct {
+		offset int64
+		z      *int
+	}
+
+	var (
+		elements []element
+		err      error
+	)
+	m.walk(func(e interface{}) {
+		switch e := e.(type) {
+		case *Layer:
+			e.renderOrder = m.RenderOrder
+			e.chunkWidth, e.chunkHeight = m.ChunkSize()
+			if lerr := e.applyOptions(opts); err == nil {
+				err = lerr
+			}
+			elements = append(elements, element{e.offset, &e.Z})
+		case *ObjectGroup:
+			elements = append(elements, element{e.offset, &e.Z})
+		case *ImageLayer:
+			elements = append(elements, element{e.offset, &e.Z})
+		case *Group:
+			elements = append(elements, element{e.offset, &e.Z})
+		}
+	})
+
+	sort.Slice(elements, func(i, j int) bool {
+		return elements[i].offset < elements[j].offset
+	})
+	for i, e := range elements {
+		*e.z = i
+	}
+
+	return err
+}
+
+// WalkLayers calls fn with each tile Layer of the map in draw order, including
+// those nested within groups. The offsets, opacity, and visibility of any
+// enclosing groups are not applied to the layers.
+func (m *Map) WalkLayers(fn func(l *Layer)) {
+	var layers []*Layer
+	m.walk(func(e interface{}) {
+		if l, ok := e.(*Layer); ok {
+			layers = append(layers, l)
+		}
+	})
+
+	sort.SliceStable(layers, func(i, j int) bool {
+		return layers[i].Z < layers[j].Z
+	})
+	for _, l := range layers {
+		fn(l)
+	}
+}
+
+// OrderedLayers returns every Layer, ObjectGroup, and ImageLayer of the map,
+// including those nested within groups, in draw order, which is ascending Z.
+// As with WalkLayers, the groups themselves are left out, and their offsets,
+// opacity, and visibility are not applied to the layers.
+func (m *Map) OrderedLayers() []LayerElement {
+	var layers []LayerElement
+	m.walk(func(e interface{}) {
+		if l, ok := e.(LayerElement); ok {
+			layers = append(layers, l)
+		}
+	})
+
+	sort.SliceStable(layers, func(i, j int) bool {
+		return layers[i].z() < layers[j].z()
+	})
+
+	return layers
+}
+
+// AllObjects returns the objects of every ObjectGroup of the map, including
+// those nested within groups. The object groups are taken in draw order, and
+// the objects of each in the order they appear in the group.
+func (m *Map) AllObjects() []Object {
+	return m.objects(func(o *Object) bool { return true })
+}
+
+// ObjectsWithType returns the objects of the map with the given type, or
+// class as Tiled now calls it, in the same order as AllObjects.
+func (m *Map) ObjectsWithType(class string) []Object {
+	return m.objects(func(o *Object) bool { return o.Type == class })
+}
+
+// objects returns the objects of the map accepted by keep, in draw order
+func (m *Map) objects(keep func(o *Object) bool) []Object {
+	var ogs []*ObjectGroup
+	m.walk(func(e interface{}) {
+		if og, ok := e.(*ObjectGroup); ok {
+			ogs = append(ogs, og)
+		}
+	})
+
+	sort.SliceStable(ogs, func(i, j int) bool {
+		return ogs[i].Z < ogs[j].Z
+	})
+
+	var objects []Object
+	for _, og := range ogs {
+		for i := range og.Objects {
+			if keep(&og.Objects[i]) {
+				objects = append(objects, og.Objects[i])
+			}
+		}
+	}
+
+	return objects
+}
+
+// walk calls fn with a pointer to every Layer, ObjectGroup, ImageLayer, and
+// Group of the map, including those nested within groups, in no particular
+// order.
+func (m *Map) walk(fn func(e interface{})) {
+	walkElements(m.Layers, m.ObjectGroups, m.ImageLayers, m.Groups, fn)
+}
+
+func walkElements(ls []Layer, ogs []ObjectGroup, ils []ImageLayer, gs []Group, fn func(e interface{})) {
+	for i := range ls {
+		fn(&ls[i])
+	}
+	for i := range ogs {
+		fn(&ogs[i])
+	}
+	for i := range ils {
+		fn(&ils[i])
+	}
+	for i := range gs {
+		g := &gs[i]
+		fn(g)
+		walkElements(g.Layers, g.ObjectGroups, g.ImageLayers, g.Groups, fn)
+	}
+}
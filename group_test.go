@@ -0,0 +1,220 @@
+package tmx
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGroups(t *testing.T) {
+	m := decodeFixture(t, "groups.tmx")
+
+	if l := len(m.Groups); l != 1 {
+		t.Fatalf("expected 1 group, got %v", l)
+	}
+
+	world := &m.Groups[0]
+	if world.Name != "world" || world.OffsetX != 8 || world.OffsetY != 4 || world.Opacity != 0.5 {
+		t.Errorf("unexpected group attributes %+v", world)
+	}
+	if p := world.Properties.WithName("zone"); p == nil || p.Value != "forest" {
+		t.Errorf("expected group property `zone` to be forest, got %+v", p)
+	}
+	if len(world.Layers) != 1 || len(world.ObjectGroups) != 1 || len(world.Groups) != 1 {
+		t.Fatalf("unexpected group children %+v", world)
+	}
+
+	overlay := &world.Groups[0]
+	if overlay.Opacity != 1 || len(overlay.ImageLayers) != 1 || len(overlay.Layers) != 1 {
+		t.Errorf("unexpected nested group %+v", overlay)
+	}
+
+	zs := map[string]int{
+		"ground":   m.Layers[0].Z,
+		"world":    world.Z,
+		"trees":    world.Layers[0].Z,
+		"spawns":   world.ObjectGroups[0].Z,
+		"overlay":  overlay.Z,
+		"fog":      overlay.ImageLayers[0].Z,
+		"roofs":    overlay.Layers[0].Z,
+		"triggers": m.ObjectGroups[0].Z,
+		"sky":      m.Layers[1].Z,
+	}
+	expected := map[string]int{
+		"ground": 0, "world": 1, "trees": 2, "spawns": 3, "overlay": 4,
+		"fog": 5, "roofs": 6, "triggers": 7, "sky": 8,
+	}
+	if !reflect.DeepEqual(zs, expected) {
+		t.Errorf("expected Z order %v, got %v", expected, zs)
+	}
+}
+
+func TestWalkLayers(t *testing.T) {
+	m := decodeFixture(t, "groups.tmx")
+
+	var names []string
+	m.WalkLayers(func(l *Layer) {
+		names = append(names, l.Name)
+	})
+
+	expected := []string{"ground", "trees", "roofs", "sky"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected layers %v, got %v", expected, names)
+	}
+}
+
+func TestOrderedLayers(t *testing.T) {
+	m := decodeFixture(t, "groups.tmx")
+
+	var names []string
+	for _, e := range m.OrderedLayers() {
+		switch e := e.(type) {
+		case *Layer:
+			names = append(names, "layer "+e.Name)
+		case *ObjectGroup:
+			names = append(names, "objectgroup "+e.Name)
+		case *ImageLayer:
+			names = append(names, "imagelayer "+e.Name)
+		default:
+			t.Fatalf("unexpected element %T", e)
+		}
+	}
+
+	expected := []string{
+		"layer ground", "layer trees", "objectgroup spawns", "imagelayer fog",
+		"layer roofs", "objectgroup triggers", "layer sky",
+	}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected layers %v, got %v", expected, names)
+	}
+
+	if e, ok := m.OrderedLayers()[0].(*Layer); !ok || e != &m.Layers[0] {
+		t.Error("expected the layers of the map, not copies")
+	}
+}
+
+func TestGroupsFreezeAndCrop(t *testing.T) {
+	m := decodeFixture(t, "groups.tmx")
+
+	c, err := m.Crop(1, 0, 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	roofs := &c.Groups[0].Groups[0].Layers[0]
+	gids, err := roofs.DecodeInto(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(gids, []GlobalID{3}) {
+		t.Errorf("expected nested layer to be cropped to [3], got %v", gids)
+	}
+	if fog := c.Groups[0].Groups[0].ImageLayers[0]; fog.OffsetX != -16 {
+		t.Errorf("expected nested image layer to be shifted, got %v", fog.OffsetX)
+	}
+
+	m.Freeze()
+	trees := &m.Groups[0].Layers[0]
+	if !trees.frozen || trees.cachedTileDefs() == nil {
+		t.Error("expected nested layer to be decoded and frozen")
+	}
+}
+
+func TestObjectWithID(t *testing.T) {
+	m := decodeFixture(t, "groups.tmx")
+	m.ObjectGroups[0].Objects[0].Properties = Properties{
+		{Name: "target", Type: "object", Value: "1"},
+		{Name: "none", Type: "object", Value: "0"},
+		{Name: "broken", Type: "object", Value: "one"},
+		{Name: "text", Value: "1"},
+	}
+	pl := m.ObjectGroups[0].Objects[0].Properties
+
+	id, err := pl.Object("target")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o := m.ObjectWithID(id); o == nil || o != &m.Groups[0].ObjectGroups[0].Objects[0] {
+		t.Errorf("expected nested object `player`, got %+v", o)
+	}
+
+	if id, err := pl.Object("none"); err != nil || id != 0 {
+		t.Errorf("expected unset reference to be 0 without error, got %v (%v)", id, err)
+	}
+	if o := m.ObjectWithID(0); o != nil {
+		t.Errorf("expected no object for id 0, got %+v", o)
+	}
+	if _, err := pl.Object("broken"); err != ErrPropertyFailedConversion {
+		t.Errorf("expected conversion error, got %v", err)
+	}
+	if _, err := pl.Object("text"); err != ErrPropertyWrongType {
+		t.Errorf("expected wrong type error, got %v", err)
+	}
+	if o := m.ObjectWithID(2); o == nil || o.Name != "exit" {
+		t.Errorf("expected object `exit`, got %+v", o)
+	}
+}
+
+func TestLayerWithID(t *testing.T) {
+	m := decodeFixture(t, "parallax.tmx")
+
+	if m.NextLayerID != 6 {
+		t.Errorf("expected next layer ID 6, got %v", m.NextLayerID)
+	}
+
+	if id := m.ImageLayers[0].ID; id != 1 {
+		t.Errorf("expected image layer ID 1, got %v", id)
+	}
+	if g := m.Groups[0]; g.ID != 2 || g.ObjectGroups[0].ID != 4 {
+		t.Errorf("expected group ID 2 and object group ID 4, got %v and %v", g.ID, g.ObjectGroups[0].ID)
+	}
+
+	for _, c := range []struct {
+		id   int
+		name string
+	}{
+		{3, "hills"},
+		{5, "ground"},
+	} {
+		if l := m.LayerWithID(c.id); l == nil || l.Name != c.name {
+			t.Errorf("expected layer %v with ID %v, got %+v", c.name, c.id, l)
+		}
+	}
+
+	for _, id := range []int{0, 1, 6} {
+		if l := m.LayerWithID(id); l != nil {
+			t.Errorf("expected no layer with ID %v, got %v", id, l.Name)
+		}
+	}
+}
+
+func TestAllObjects(t *testing.T) {
+	m, err := Decode(strings.NewReader(`<map>
+		<objectgroup name="top"><object id="1" name="a" type="enemy"/></objectgroup>
+		<group name="world">
+			<objectgroup name="spawns"><object id="2" name="b" type="enemy"/><object id="3" name="c" type="player"/></objectgroup>
+		</group>
+		<objectgroup name="bottom"><object id="4" name="d" type="enemy"/></objectgroup>
+	</map>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := func(objects []Object) []string {
+		var ns []string
+		for _, o := range objects {
+			ns = append(ns, o.Name)
+		}
+		return ns
+	}
+
+	if ns, e := names(m.AllObjects()), []string{"a", "b", "c", "d"}; !reflect.DeepEqual(ns, e) {
+		t.Errorf("expected objects %v, got %v", e, ns)
+	}
+	if ns, e := names(m.ObjectsWithType("enemy")), []string{"a", "b", "d"}; !reflect.DeepEqual(ns, e) {
+		t.Errorf("expected enemies %v, got %v", e, ns)
+	}
+	if objects := m.ObjectsWithType("boss"); len(objects) != 0 {
+		t.Errorf("expected no bosses, got %v", names(objects))
+	}
+}
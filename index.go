@@ -0,0 +1,77 @@
+package tmx
+
+import "sort"
+
+// TileIndex is a prebuilt index of the TileSets of a Map, for resolving many
+// GlobalIDs without searching the TileSets each time. It holds pointers into
+// the Map's TileSets, so it must be rebuilt if they are changed, added,
+// removed, or reordered.
+type TileIndex struct {
+	ranges []tileIndexRange
+}
+
+// tileIndexRange is a TileSet covering the GlobalIDs starting at first
+type tileIndexRange struct {
+	first uint32
+	ts    *TileSet
+	tiles map[TileID]*Tile
+}
+
+// BuildTileIndex builds a TileIndex over the map's TileSets, leaving
+// m.TileSets in their order.
+func (m *Map) BuildTileIndex() *TileIndex {
+	ti := &TileIndex{ranges: make([]tileIndexRange, len(m.TileSets))}
+
+	for i := range m.TileSets {
+		ts := &m.TileSets[i]
+		r := tileIndexRange{
+			first: uint32(ts.FirstGlobalID),
+			ts:    ts,
+			tiles: make(map[TileID]*Tile, len(ts.Tiles)),
+		}
+
+		// keep the first tile for each ID, matching TileWithID
+		for j := len(ts.Tiles) - 1; j >= 0; j-- {
+			r.tiles[ts.Tiles[j].TileID] = &ts.Tiles[j]
+		}
+
+		ti.ranges[i] = r
+	}
+
+	sort.SliceStable(ti.ranges, func(i, j int) bool {
+		return ti.ranges[i].first < ti.ranges[j].first
+	})
+
+	return ti
+}
+
+// Resolve returns the TileDef for the given GlobalID, in O(log n) for n
+// TileSets. An empty tile returns a TileDef with Nil set, while a GlobalID not
+// covered by any TileSet returns nil.
+func (ti *TileIndex) Resolve(gid GlobalID) *TileDef {
+	bid := gid.BareID()
+	if bid == 0 {
+		return &TileDef{Nil: true}
+	}
+
+	// find the last range starting at or before the bare ID
+	i := sort.Search(len(ti.ranges), func(i int) bool {
+		return ti.ranges[i].first > bid
+	}) - 1
+	if i < 0 {
+		return nil
+	}
+
+	r := &ti.ranges[i]
+	id := gid.TileID(r.ts)
+
+	return &TileDef{
+		ID:                  id,
+		GlobalID:            gid,
+		TileSet:             r.ts,
+		Tile:                r.tiles[id],
+		HorizontallyFlipped: gid.IsFlippedHorizontally(),
+		VerticallyFlipped:   gid.IsFlippedVertically(),
+		DiagonallyFlipped:   gid.IsFlippedDiagonally(),
+	}
+}
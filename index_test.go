@@ -0,0 +1,73 @@
+package tmx
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTileIndex(t *testing.T) {
+	m := &Map{
+		TileSets: []TileSet{
+			{FirstGlobalID: 9, Name: "third", TileCount: 4},
+			{FirstGlobalID: 1, Name: "first", TileCount: 4, Tiles: []Tile{{TileID: 2, Type: "two"}}},
+			{FirstGlobalID: 5, Name: "second", TileCount: 4},
+		},
+	}
+
+	ti := m.BuildTileIndex()
+
+	if n := m.TileSets[0].Name; n != "third" {
+		t.Errorf("expected tilesets not to be reordered, got `%v` first", n)
+	}
+
+	for _, c := range []struct {
+		gid  GlobalID
+		ts   string
+		id   TileID
+		tile bool
+	}{
+		{1, "first", 0, false},
+		{3, "first", 2, true},
+		{4, "first", 3, false},
+		{5, "second", 0, false},
+		{8 | TileFlippedHorizontally, "second", 3, false},
+		{12, "third", 3, false},
+	} {
+		td := ti.Resolve(c.gid)
+		if td == nil {
+			t.Errorf("gid(%v): expected a tile, got nil", c.gid)
+			continue
+		}
+		if td.TileSet.Name != c.ts || td.ID != c.id || (td.Tile != nil) != c.tile {
+			t.Errorf("gid(%v): expected tile %v of `%v`, got %+v", c.gid, c.id, c.ts, td)
+		}
+		if td.HorizontallyFlipped != c.gid.IsFlippedHorizontally() {
+			t.Errorf("gid(%v): unexpected flip flags", c.gid)
+		}
+	}
+
+	if td := ti.Resolve(0); td == nil || !td.Nil {
+		t.Errorf("expected a nil tile for gid 0, got %+v", td)
+	}
+
+	if td := (&Map{TileSets: []TileSet{{FirstGlobalID: 5}}}).BuildTileIndex().Resolve(2); td != nil {
+		t.Errorf("expected nil for an unresolvable gid, got %+v", td)
+	}
+}
+
+func TestTileIndexMatchesTileDefs(t *testing.T) {
+	m := decodeFixture(t, "test.tmx")
+	walls := m.LayerWithName("walls")
+
+	tds, err := walls.TileDefs(m.TileSets)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ti := m.BuildTileIndex()
+	for i, td := range tds {
+		if r := ti.Resolve(td.GlobalID); !reflect.DeepEqual(r, td) {
+			t.Fatalf("idx(%v): expected %+v, got %+v", i, td, r)
+		}
+	}
+}
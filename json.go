@@ -0,0 +1,757 @@
+package tmx
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DecodeJSON takes a reader for a map in Tiled's JSON format, as saved to
+// .tmj or .json files, and returns a new Map decoded from it. The result is
+// the same as decoding the equivalent TMX file with Decode, such that it may
+// be used in the same way, and encoded to TMX with Encode.
+func DecodeJSON(r io.Reader) (*Map, error) {
+	d := json.NewDecoder(r)
+	d.UseNumber()
+
+	var jm jsonMap
+	if err := d.Decode(&jm); err != nil {
+		return nil, err
+	}
+
+	return jm.toMap()
+}
+
+// DecodeAuto decodes a map in either format, TMX or JSON, telling them apart
+// by the first byte of the map which is not whitespace: `<` for TMX, decoded
+// as with Decode, or `{` for JSON, decoded as with DecodeJSON. Returns
+// ErrUnknownFormat for anything else.
+func DecodeAuto(r io.Reader) (*Map, error) {
+	br := bufio.NewReader(r)
+
+	for {
+		c, err := br.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+
+		switch c {
+		case ' ', '\t', '\r', '\n':
+			continue
+		case '<':
+			br.UnreadByte()
+			return Decode(br)
+		case '{':
+			br.UnreadByte()
+			return DecodeJSON(br)
+		}
+
+		return nil, fmt.Errorf("%w: map begins with %q", ErrUnknownFormat, c)
+	}
+}
+
+type jsonMap struct {
+	Version         json.Number     `json:"version"`
+	Class           string          `json:"class"`
+	Orientation     string          `json:"orientation"`
+	RenderOrder     string          `json:"renderorder"`
+	Width           int             `json:"width"`
+	Height          int             `json:"height"`
+	TileWidth       int             `json:"tilewidth"`
+	TileHeight      int             `json:"tileheight"`
+	HexSideLength   int             `json:"hexsidelength"`
+	StaggerAxis     string          `json:"staggeraxis"`
+	StaggerIndex    string          `json:"staggerindex"`
+	BackgroundColor string          `json:"backgroundcolor"`
+	NextObjectID    ObjectID        `json:"nextobjectid"`
+	NextLayerID     int             `json:"nextlayerid"`
+	ParallaxOriginX float64         `json:"parallaxoriginx"`
+	ParallaxOriginY float64         `json:"parallaxoriginy"`
+	Infinite        bool            `json:"infinite"`
+	EditorSettings  *EditorSettings `json:"editorsettings"`
+	Properties      []jsonProperty  `json:"properties"`
+	TileSets        []jsonTileSet   `json:"tilesets"`
+	Layers          []jsonLayer     `json:"layers"`
+}
+
+type jsonProperty struct {
+	Name         string      `json:"name"`
+	Type         string      `json:"type"`
+	PropertyType string      `json:"propertytype"`
+	Value        interface{} `json:"value"`
+}
+
+type jsonTileSet struct {
+	FirstGlobalID    GlobalID         `json:"firstgid"`
+	Source           string           `json:"source"`
+	Name             string           `json:"name"`
+	Class            string           `json:"class"`
+	TileWidth        int              `json:"tilewidth"`
+	TileHeight       int              `json:"tileheight"`
+	Spacing          int              `json:"spacing"`
+	Margin           int              `json:"margin"`
+	TileCount        int              `json:"tilecount"`
+	Columns          int              `json:"columns"`
+	Image            string           `json:"image"`
+	ImageWidth       int              `json:"imagewidth"`
+	ImageHeight      int              `json:"imageheight"`
+	TransparentColor string           `json:"transparentcolor"`
+	TileOffset       TileOffset       `json:"tileoffset"`
+	Grid             *Grid            `json:"grid"`
+	Transformations  *Transformations `json:"transformations"`
+	ObjectAlignment  string           `json:"objectalignment"`
+	Properties       []jsonProperty   `json:"properties"`
+	Terrains         []jsonTerrain    `json:"terrains"`
+	Tiles            []jsonTile       `json:"tiles"`
+	WangSets         []jsonWangSet    `json:"wangsets"`
+}
+
+type jsonTerrain struct {
+	Name       string         `json:"name"`
+	Tile       TileID         `json:"tile"`
+	Properties []jsonProperty `json:"properties"`
+}
+
+type jsonTile struct {
+	ID          TileID         `json:"id"`
+	Type        string         `json:"type"`
+	Class       string         `json:"class"`
+	Probability *float32       `json:"probability"`
+	Properties  []jsonProperty `json:"properties"`
+	Image       string         `json:"image"`
+	ImageWidth  int            `json:"imagewidth"`
+	ImageHeight int            `json:"imageheight"`
+	Terrain     []int          `json:"terrain"`
+	Animation   []jsonFrame    `json:"animation"`
+	ObjectGroup *jsonLayer     `json:"objectgroup"`
+}
+
+type jsonWangSet struct {
+	Name       string          `json:"name"`
+	Type       string          `json:"type"`
+	Class      string          `json:"class"`
+	Tile       int             `json:"tile"`
+	Properties []jsonProperty  `json:"properties"`
+	Colors     []jsonWangColor `json:"colors"`
+	Tiles      []jsonWangTile  `json:"wangtiles"`
+}
+
+type jsonWangColor struct {
+	Name        string         `json:"name"`
+	Class       string         `json:"class"`
+	Color       string         `json:"color"`
+	Tile        int            `json:"tile"`
+	Probability float32        `json:"probability"`
+	Properties  []jsonProperty `json:"properties"`
+}
+
+type jsonWangTile struct {
+	TileID TileID   `json:"tileid"`
+	WangID [8]uint8 `json:"wangid"`
+}
+
+type jsonFrame struct {
+	TileID   uint32 `json:"tileid"`
+	Duration int    `json:"duration"`
+}
+
+// jsonLayer holds any of the kinds of layer, distinguished by Type
+type jsonLayer struct {
+	ID         int            `json:"id"`
+	Type       string         `json:"type"`
+	Name       string         `json:"name"`
+	X          float64        `json:"x"`
+	Y          float64        `json:"y"`
+	Width      int            `json:"width"`
+	Height     int            `json:"height"`
+	Opacity    *float32       `json:"opacity"`
+	Visible    *bool          `json:"visible"`
+	OffsetX    float64        `json:"offsetx"`
+	OffsetY    float64        `json:"offsety"`
+	ParallaxX  *float32       `json:"parallaxx"`
+	ParallaxY  *float32       `json:"parallaxy"`
+	TintColor  string         `json:"tintcolor"`
+	Properties []jsonProperty `json:"properties"`
+
+	// tile layers
+	Encoding    string          `json:"encoding"`
+	Compression string          `json:"compression"`
+	Data        json.RawMessage `json:"data"`
+	Chunks      []jsonChunk     `json:"chunks"`
+
+	// object groups
+	Color     string       `json:"color"`
+	DrawOrder string       `json:"draworder"`
+	Objects   []jsonObject `json:"objects"`
+
+	// image layers
+	Image            string `json:"image"`
+	ImageWidth       int    `json:"imagewidth"`
+	ImageHeight      int    `json:"imageheight"`
+	TransparentColor string `json:"transparentcolor"`
+	RepeatX          bool   `json:"repeatx"`
+	RepeatY          bool   `json:"repeaty"`
+
+	// groups
+	Layers []jsonLayer `json:"layers"`
+}
+
+type jsonChunk struct {
+	X      int             `json:"x"`
+	Y      int             `json:"y"`
+	Width  int             `json:"width"`
+	Height int             `json:"height"`
+	Data   json.RawMessage `json:"data"`
+}
+
+type jsonObject struct {
+	ID         ObjectID       `json:"id"`
+	Name       string         `json:"name"`
+	Type       string         `json:"type"`
+	Class      string         `json:"class"`
+	X          float64        `json:"x"`
+	Y          float64        `json:"y"`
+	Width      float64        `json:"width"`
+	Height     float64        `json:"height"`
+	Rotation   float64        `json:"rotation"`
+	GlobalID   GlobalID       `json:"gid"`
+	Visible    *bool          `json:"visible"`
+	Properties []jsonProperty `json:"properties"`
+	Polygon    []jsonPoint    `json:"polygon"`
+	Polyline   []jsonPoint    `json:"polyline"`
+	Ellipse    bool           `json:"ellipse"`
+	Point      bool           `json:"point"`
+	Text       *jsonText      `json:"text"`
+	Template   string         `json:"template"`
+
+	// keys of a templated object, which override its template
+	overrides []string
+}
+
+// UnmarshalJSON decodes a jsonObject, taking note of which keys are set on an
+// instance of a template, as only those override the template.
+func (jo *jsonObject) UnmarshalJSON(b []byte) error {
+	type object jsonObject
+
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	if err := d.Decode((*object)(jo)); err != nil {
+		return err
+	}
+
+	if jo.Template == "" {
+		return nil
+	}
+
+	var keys map[string]json.RawMessage
+	if err := json.Unmarshal(b, &keys); err != nil {
+		return err
+	}
+	for k := range keys {
+		jo.overrides = append(jo.overrides, k)
+	}
+	sort.Strings(jo.overrides)
+
+	return nil
+}
+
+// jsonText is a Text as found in JSON, whose keys match the names of the
+// fields of Text
+type jsonText Text
+
+// UnmarshalJSON decodes a jsonText, defaulting each absent key as Tiled does
+func (jt *jsonText) UnmarshalJSON(b []byte) error {
+	type text Text
+	*jt = jsonText(defaultText())
+
+	return json.Unmarshal(b, (*text)(jt))
+}
+
+type jsonPoint struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+func (jm *jsonMap) toMap() (*Map, error) {
+	m := &Map{
+		Version:         jm.Version.String(),
+		Class:           jm.Class,
+		Orientation:     jm.Orientation,
+		RenderOrder:     jm.RenderOrder,
+		Width:           jm.Width,
+		Height:          jm.Height,
+		TileWidth:       jm.TileWidth,
+		TileHeight:      jm.TileHeight,
+		HexSideLength:   jm.HexSideLength,
+		StaggerIndex:    jm.StaggerIndex,
+		BackgroundColor: jm.BackgroundColor,
+		NextObjectID:    jm.NextObjectID,
+		NextLayerID:     jm.NextLayerID,
+		ParallaxOriginX: jm.ParallaxOriginX,
+		ParallaxOriginY: jm.ParallaxOriginY,
+		Properties:      jsonProperties(jm.Properties),
+		RawInfinite:     jm.Infinite,
+		EditorSettings:  jm.EditorSettings,
+	}
+
+	if jm.StaggerAxis != "" {
+		m.StaggerAxis = rune(jm.StaggerAxis[0])
+	}
+
+	for i := range jm.TileSets {
+		ts, err := jm.TileSets[i].toTileSet()
+		if err != nil {
+			return nil, err
+		}
+		m.TileSets = append(m.TileSets, *ts)
+	}
+
+	// layers are numbered in the order they appear, groups before their
+	// children, as with the document order of a TMX file
+	z := 0
+	err := jsonLayers(jm.Layers, &z, m.RenderOrder, &m.Layers, &m.ObjectGroups, &m.ImageLayers, &m.Groups)
+	if err != nil {
+		return nil, err
+	}
+
+	cw, ch := m.ChunkSize()
+	m.walk(func(e interface{}) {
+		if l, ok := e.(*Layer); ok {
+			l.chunkWidth, l.chunkHeight = cw, ch
+		}
+	})
+
+	return m, nil
+}
+
+func jsonLayers(jls []jsonLayer, z *int, renderOrder string, ls *[]Layer, ogs *[]ObjectGroup, ils *[]ImageLayer, gs *[]Group) error {
+	for i := range jls {
+		jl := &jls[i]
+
+		opacity := float32(1)
+		if jl.Opacity != nil {
+			opacity = *jl.Opacity
+		}
+		visible := jl.Visible == nil || *jl.Visible
+		px, py := jl.parallax()
+
+		switch jl.Type {
+		case "tilelayer":
+			l := Layer{
+				ID:          jl.ID,
+				Name:        jl.Name,
+				X:           int(jl.X),
+				Y:           int(jl.Y),
+				Width:       jl.Width,
+				Height:      jl.Height,
+				Opacity:     opacity,
+				Visible:     visible,
+				OffsetX:     int(jl.OffsetX),
+				OffsetY:     int(jl.OffsetY),
+				ParallaxX:   px,
+				ParallaxY:   py,
+				TintColor:   jl.TintColor,
+				Properties:  jsonProperties(jl.Properties),
+				Z:           *z,
+				renderOrder: renderOrder,
+			}
+
+			var err error
+			if l.RawData, err = jl.toData(); err != nil {
+				return fmt.Errorf("layer %v: %w", jl.Name, err)
+			}
+
+			*ls = append(*ls, l)
+		case "objectgroup":
+			og := jl.toObjectGroup(opacity, visible)
+			og.Z = *z
+			*ogs = append(*ogs, og)
+		case "imagelayer":
+			*ils = append(*ils, ImageLayer{
+				ID:         jl.ID,
+				Name:       jl.Name,
+				OffsetX:    jl.OffsetX,
+				OffsetY:    jl.OffsetY,
+				ParallaxX:  px,
+				ParallaxY:  py,
+				TintColor:  jl.TintColor,
+				X:          jl.X,
+				Y:          jl.Y,
+				Opacity:    opacity,
+				Visible:    visible,
+				RepeatX:    jl.RepeatX,
+				RepeatY:    jl.RepeatY,
+				Properties: jsonProperties(jl.Properties),
+				Image: Image{
+					Source:           jl.Image,
+					TransparentColor: jl.TransparentColor,
+					Width:            jl.ImageWidth,
+					Height:           jl.ImageHeight,
+				},
+				Z: *z,
+			})
+		case "group":
+			g := Group{
+				ID:         jl.ID,
+				Name:       jl.Name,
+				OffsetX:    jl.OffsetX,
+				OffsetY:    jl.OffsetY,
+				ParallaxX:  px,
+				ParallaxY:  py,
+				TintColor:  jl.TintColor,
+				Opacity:    opacity,
+				Visible:    visible,
+				Properties: jsonProperties(jl.Properties),
+				Z:          *z,
+			}
+
+			*z++
+			err := jsonLayers(jl.Layers, z, renderOrder, &g.Layers, &g.ObjectGroups, &g.ImageLayers, &g.Groups)
+			if err != nil {
+				return err
+			}
+
+			*gs = append(*gs, g)
+			continue
+		default:
+			return fmt.Errorf("unknown layer type %q", jl.Type)
+		}
+
+		*z++
+	}
+
+	return nil
+}
+
+// parallax returns the parallax factors of the layer, which default to 1
+func (jl *jsonLayer) parallax() (x, y float32) {
+	x, y = 1, 1
+	if jl.ParallaxX != nil {
+		x = *jl.ParallaxX
+	}
+	if jl.ParallaxY != nil {
+		y = *jl.ParallaxY
+	}
+
+	return x, y
+}
+
+// toData converts the tile data of a layer. Data given as an array of GIDs
+// is kept as TileGlobalRefs, but with the csv encoding, as that is how it is
+// described in Tiled.
+func (jl *jsonLayer) toData() (Data, error) {
+	d := Data{Encoding: jl.Encoding, Compression: jl.Compression}
+	if d.Encoding == "" {
+		d.Encoding = "csv"
+	}
+
+	var err error
+	if len(jl.Chunks) > 0 {
+		for _, jc := range jl.Chunks {
+			c := Chunk{X: jc.X, Y: jc.Y, Width: jc.Width, Height: jc.Height}
+			if c.TileGlobalRefs, c.RawBytes, err = jsonTileData(jc.Data); err != nil {
+				return d, err
+			}
+			d.Chunks = append(d.Chunks, c)
+		}
+
+		return d, nil
+	}
+
+	if len(jl.Data) == 0 {
+		return Data{}, nil
+	}
+
+	d.TileGlobalRefs, d.RawBytes, err = jsonTileData(jl.Data)
+
+	return d, err
+}
+
+// jsonTileData converts tile data which is either an array of GIDs, or an
+// encoded string
+func jsonTileData(raw json.RawMessage) ([]TileGlobalRef, []byte, error) {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return nil, []byte(s), nil
+	}
+
+	var gids []uint32
+	if err := json.Unmarshal(raw, &gids); err != nil {
+		return nil, nil, err
+	}
+
+	trs := make([]TileGlobalRef, len(gids))
+	for i, gid := range gids {
+		trs[i].GlobalID = GlobalID(gid)
+	}
+
+	return trs, nil, nil
+}
+
+func (jl *jsonLayer) toObjectGroup(opacity float32, visible bool) ObjectGroup {
+	og := ObjectGroup{
+		ID:         jl.ID,
+		Name:       jl.Name,
+		Color:      jl.Color,
+		X:          int(jl.X),
+		Y:          int(jl.Y),
+		Width:      jl.Width,
+		Height:     jl.Height,
+		Opacity:    opacity,
+		Visible:    visible,
+		OffsetX:    int(jl.OffsetX),
+		OffsetY:    int(jl.OffsetY),
+		DrawOrder:  jl.DrawOrder,
+		Properties: jsonProperties(jl.Properties),
+	}
+	og.ParallaxX, og.ParallaxY = jl.parallax()
+	og.TintColor = jl.TintColor
+
+	for i := range jl.Objects {
+		og.Objects = append(og.Objects, jl.Objects[i].toObject())
+	}
+
+	return og
+}
+
+func (jo *jsonObject) toObject() Object {
+	o := Object{
+		ObjectID:   jo.ID,
+		Name:       jo.Name,
+		Type:       jo.Type,
+		X:          jo.X,
+		Y:          jo.Y,
+		Width:      jo.Width,
+		Height:     jo.Height,
+		Rotation:   jo.Rotation,
+		GlobalID:   jo.GlobalID,
+		Visible:    jo.Visible == nil || *jo.Visible,
+		Properties: jsonProperties(jo.Properties),
+		Template:   jo.Template,
+		overrides:  jo.overrides,
+	}
+
+	if o.Type == "" {
+		o.Type = jo.Class
+	}
+
+	if jo.Polygon != nil {
+		o.Polygons = []Poly{jsonPoly(jo.Polygon)}
+	}
+	if jo.Polyline != nil {
+		o.Polylines = []Poly{jsonPoly(jo.Polyline)}
+	}
+
+	if jo.Ellipse {
+		o.RawExtra = append(o.RawExtra, Tag{XMLName: xml.Name{Local: "ellipse"}})
+	}
+	if jo.Point {
+		o.RawExtra = append(o.RawExtra, Tag{XMLName: xml.Name{Local: "point"}})
+	}
+
+	if jo.Text != nil {
+		t := Text(*jo.Text)
+		o.Text = &t
+	}
+
+	return o
+}
+
+func jsonPoly(pts []jsonPoint) Poly {
+	s := make([]string, len(pts))
+	for i, p := range pts {
+		s[i] = formatFloat(p.X) + "," + formatFloat(p.Y)
+	}
+
+	return Poly{RawPoints: strings.Join(s, " ")}
+}
+
+func (jt *jsonTileSet) toTileSet() (*TileSet, error) {
+	ts := &TileSet{
+		FirstGlobalID:   jt.FirstGlobalID,
+		Source:          jt.Source,
+		Name:            jt.Name,
+		Class:           jt.Class,
+		TileWidth:       jt.TileWidth,
+		TileHeight:      jt.TileHeight,
+		Spacing:         jt.Spacing,
+		Margin:          jt.Margin,
+		TileCount:       jt.TileCount,
+		Columns:         jt.Columns,
+		Properties:      jsonProperties(jt.Properties),
+		TileOffset:      jt.TileOffset,
+		Grid:            jt.Grid,
+		Transformations: jt.Transformations,
+		ObjectAlignment: jt.ObjectAlignment,
+		Image: Image{
+			Source:           jt.Image,
+			TransparentColor: jt.TransparentColor,
+			Width:            jt.ImageWidth,
+			Height:           jt.ImageHeight,
+		},
+	}
+
+	for _, t := range jt.Terrains {
+		ts.TerrainTypes = append(ts.TerrainTypes, Terrain{
+			Name:       t.Name,
+			TileID:     t.Tile,
+			Properties: jsonProperties(t.Properties),
+		})
+	}
+
+	for i := range jt.Tiles {
+		jt := &jt.Tiles[i]
+
+		t := Tile{
+			TileID:      jt.ID,
+			Probability: 1,
+			Properties:  jsonProperties(jt.Properties),
+			Type:        jt.Type,
+			Class:       jt.Class,
+			Image: Image{
+				Source: jt.Image,
+				Width:  jt.ImageWidth,
+				Height: jt.ImageHeight,
+			},
+		}
+
+		if jt.Probability != nil {
+			t.Probability = *jt.Probability
+		}
+
+		if len(jt.Terrain) > 0 {
+			corners := make([]string, len(jt.Terrain))
+			for j, c := range jt.Terrain {
+				if c >= 0 {
+					corners[j] = strconv.Itoa(c)
+				}
+			}
+			t.RawTerrainType = strings.Join(corners, ",")
+		}
+
+		for _, f := range jt.Animation {
+			t.Animation = append(t.Animation, Frame{
+				TileID:       TileID(GlobalID(f.TileID).BareID()),
+				DurationMsec: f.Duration,
+				RawTileID:    f.TileID,
+			})
+		}
+
+		if jt.ObjectGroup != nil {
+			opacity := float32(1)
+			if jt.ObjectGroup.Opacity != nil {
+				opacity = *jt.ObjectGroup.Opacity
+			}
+			visible := jt.ObjectGroup.Visible == nil || *jt.ObjectGroup.Visible
+			t.ObjectGroup = jt.ObjectGroup.toObjectGroup(opacity, visible)
+		}
+
+		ts.Tiles = append(ts.Tiles, t)
+	}
+
+	for _, jw := range jt.WangSets {
+		ws := WangSet{
+			Name:       jw.Name,
+			Type:       jw.Type,
+			Class:      jw.Class,
+			Tile:       jw.Tile,
+			Properties: jsonProperties(jw.Properties),
+		}
+
+		for _, c := range jw.Colors {
+			ws.Colors = append(ws.Colors, WangColor{
+				Name:        c.Name,
+				Class:       c.Class,
+				Color:       c.Color,
+				Tile:        c.Tile,
+				Probability: c.Probability,
+				Properties:  jsonProperties(c.Properties),
+			})
+		}
+
+		for _, wt := range jw.Tiles {
+			ids := make([]string, len(wt.WangID))
+			for i, c := range wt.WangID {
+				ids[i] = strconv.Itoa(int(c))
+			}
+
+			ws.WangTiles = append(ws.WangTiles, WangTile{
+				TileID:    wt.TileID,
+				WangID:    wt.WangID,
+				RawWangID: strings.Join(ids, ","),
+			})
+		}
+
+		ts.WangSets = append(ts.WangSets, ws)
+	}
+
+	return ts, nil
+}
+
+// jsonProperties converts properties, whose values are typed in JSON, to the
+// string values used in TMX. As in TMX, string properties have no type.
+func jsonProperties(jps []jsonProperty) Properties {
+	var pl Properties
+	for _, jp := range jps {
+		p := jsonPropertyValue(jp.Name, jp.Type, jp.Value)
+		p.PropertyType = jp.PropertyType
+
+		pl = append(pl, p)
+	}
+
+	return pl
+}
+
+// jsonPropertyValue converts the value of a property as in TMX; the members of
+// a class property are held in an object, so their types are inferred from
+// their values, and they are sorted by name.
+func jsonPropertyValue(name, typ string, value interface{}) Property {
+	p := Property{Name: name, Type: typ}
+	if p.Type == "string" {
+		p.Type = ""
+	}
+
+	switch v := value.(type) {
+	case nil:
+	case string:
+		p.Value = v
+	case json.Number:
+		p.Value = v.String()
+	case bool:
+		p.Value = strconv.FormatBool(v)
+	case map[string]interface{}:
+		names := make([]string, 0, len(v))
+		for n := range v {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+
+		for _, n := range names {
+			var t string
+			switch m := v[n].(type) {
+			case json.Number:
+				t = "float"
+				if _, err := m.Int64(); err == nil {
+					t = "int"
+				}
+			case bool:
+				t = "bool"
+			case map[string]interface{}:
+				t = "class"
+			}
+			p.Properties = append(p.Properties, jsonPropertyValue(n, t, v[n]))
+		}
+	default:
+		// anything else has no TMX equivalent; keep its JSON
+		b, _ := json.Marshal(v)
+		p.Value = string(b)
+	}
+
+	return p
+}
@@ -0,0 +1,134 @@
+package tmx
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDecodeJSON(t *testing.T) {
+	file, err := os.Open(path.Join("fixtures", "test.tmj"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	jm, err := DecodeJSON(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !jm.LayerWithName("walls").Visible || jm.LayerWithName("non-solid").Visible {
+		t.Error("expected visibility of layers to be decoded")
+	}
+
+	if l := jm.LayerWithID(2); l == nil || l.Name != "non-solid" || jm.NextLayerID != 6 {
+		t.Errorf("expected layer IDs to be decoded, got %+v", l)
+	}
+
+	xm := decodeFixture(t, "test.tmx")
+	if !reflect.DeepEqual(withoutLayerIDs(decodedState(t, jm)), decodedState(t, xm)) {
+		t.Errorf("expected JSON map to match TMX map\n%+v\n%+v", jm, xm)
+	}
+}
+
+// withoutLayerIDs clears the IDs of the layers of the map, and its
+// NextLayerID. fixtures/test.tmx was saved by a version of Tiled without layer
+// IDs, which the export of the same map to fixtures/test.tmj has.
+func withoutLayerIDs(m *Map) *Map {
+	m.NextLayerID = 0
+	m.walk(func(e interface{}) {
+		switch e := e.(type) {
+		case *Layer:
+			e.ID = 0
+		case *ObjectGroup:
+			e.ID = 0
+		case *ImageLayer:
+			e.ID = 0
+		case *Group:
+			e.ID = 0
+		}
+	})
+
+	return m
+}
+
+func TestDecodeAuto(t *testing.T) {
+	xm := decodeFixture(t, "test.tmx")
+
+	for _, name := range []string{"test.tmx", "test.tmj"} {
+		b, err := os.ReadFile(path.Join("fixtures", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		m, err := DecodeAuto(io.MultiReader(strings.NewReader(" \n\t"), bytes.NewReader(b)))
+		if err != nil {
+			t.Fatalf("%v: %v", name, err)
+		}
+		if !reflect.DeepEqual(withoutLayerIDs(decodedState(t, m)), decodedState(t, xm)) {
+			t.Errorf("%v: expected map to match TMX map", name)
+		}
+	}
+
+	if _, err := DecodeAuto(strings.NewReader("  map")); !errors.Is(err, ErrUnknownFormat) {
+		t.Errorf("expected %v, got %v", ErrUnknownFormat, err)
+	}
+	if _, err := DecodeAuto(strings.NewReader("  ")); err != io.EOF {
+		t.Errorf("expected %v, got %v", io.EOF, err)
+	}
+}
+
+func TestDecodeJSONData(t *testing.T) {
+	m, err := DecodeJSON(strings.NewReader(`{
+		"width": 2, "height": 2, "tilewidth": 8, "tileheight": 8,
+		"tilesets": [{"firstgid": 1, "name": "t", "tilewidth": 8, "tileheight": 8,
+			"tiles": [{"id": 0, "terrain": [0, -1, 0, 1], "animation": [{"tileid": 1, "duration": 100}]}]}],
+		"layers": [
+			{"type": "tilelayer", "name": "csv", "width": 2, "height": 2, "data": [1, 0, 2, 2147483649]},
+			{"type": "group", "name": "g", "opacity": 0.5, "layers": [
+				{"type": "objectgroup", "name": "o", "objects": [
+					{"id": 1, "class": "thing", "ellipse": true, "properties": [{"name": "n", "type": "int", "value": 3}]}
+				]}
+			]},
+			{"type": "imagelayer", "name": "i", "image": "bg.png"}
+		]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	trs, err := m.LayerWithName("csv").TileGlobalRefs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(trs) != 4 || trs[3].GlobalID.BareID() != 1 || !trs[3].GlobalID.IsFlippedHorizontally() {
+		t.Errorf("unexpected tile data %v", trs)
+	}
+
+	if len(m.Groups) != 1 || m.Groups[0].Opacity != 0.5 || m.Groups[0].Z != 1 || m.ImageLayers[0].Z != 3 {
+		t.Fatalf("unexpected groups %+v", m.Groups)
+	}
+
+	o := m.Groups[0].ObjectGroups[0].Objects[0]
+	if o.Type != "thing" || len(o.RawExtra) != 1 || o.RawExtra[0].XMLName.Local != "ellipse" {
+		t.Errorf("unexpected object %+v", o)
+	}
+	if n, err := o.Properties.Int("n"); err != nil || n != 3 {
+		t.Errorf("expected property 3, got %v, %v", n, err)
+	}
+
+	tile := m.TileSets[0].Tiles[0]
+	if tile.RawTerrainType != "0,,0,1" || tile.Probability != 1 || tile.Animation[0].TileID != 1 {
+		t.Errorf("unexpected tile %+v", tile)
+	}
+
+	if _, err := DecodeJSON(strings.NewReader(`{"layers": [{"type": "unknown"}]}`)); err == nil {
+		t.Error("expected error for unknown layer type")
+	}
+}
@@ -6,15 +6,23 @@ import (
 	"bytes"
 	"compress/gzip"
 	"compress/zlib"
+	"context"
 	"encoding/base64"
+	"encoding/binary"
 	"encoding/xml"
 	"errors"
 	"fmt"
+	"image"
+	"image/color"
 	"io"
 	"io/ioutil"
+	"math"
+	"path/filepath"
 	"sort"
 	"strconv"
 	"strings"
+	"sync/atomic"
+	"time"
 )
 
 // Bitmasks for tile orientation
@@ -33,6 +41,16 @@ var (
 	ErrPropertyNotFound         = errors.New("no property with a given name was found")
 	ErrPropertyWrongType        = errors.New("a property was found, but its type was incorrect")
 	ErrPropertyFailedConversion = errors.New("the property failed to convert to the expected type")
+	ErrLayerNotFound            = errors.New("no layer with a given name was found")
+	ErrOutOfBounds              = errors.New("the coordinates are outside of the layer")
+	ErrPropertyEmpty            = errors.New("a property was found, but its value was empty")
+	ErrChunkedData              = errors.New("the layer data is split into chunks")
+	ErrTileIDOutOfRange         = errors.New("the tile ID is outside of the tileset")
+	ErrUnresolvedSource         = errors.New("the external source has not been resolved")
+	ErrNoEmbeddedData           = errors.New("the image has no embedded data")
+	ErrNotTileObject            = errors.New("the object is not a tile object")
+	ErrUnknownFormat            = errors.New("the map is neither TMX nor JSON")
+	ErrInvalidStagger           = errors.New("the stagger axis or index is not valid")
 )
 
 // ObjectID specifies a unique ID
@@ -45,6 +63,38 @@ type ObjectID int32
 // structs.
 type GlobalID uint32
 
+// NewGlobalID returns the GlobalID of the tile with the given bare ID, flipped
+// as given. Any flip bits of bare itself are ignored.
+func NewGlobalID(bare uint32, flipH, flipV, flipD bool) GlobalID {
+	return GlobalID(bare).
+		WithFlipHorizontally(flipH).
+		WithFlipVertically(flipV).
+		WithFlipDiagonally(flipD)
+}
+
+// WithFlipHorizontally returns the ID with its horizontal flip set or cleared
+func (g GlobalID) WithFlipHorizontally(flip bool) GlobalID {
+	return g.withFlag(TileFlippedHorizontally, flip)
+}
+
+// WithFlipVertically returns the ID with its vertical flip set or cleared
+func (g GlobalID) WithFlipVertically(flip bool) GlobalID {
+	return g.withFlag(TileFlippedVertically, flip)
+}
+
+// WithFlipDiagonally returns the ID with its diagonal flip set or cleared
+func (g GlobalID) WithFlipDiagonally(flip bool) GlobalID {
+	return g.withFlag(TileFlippedDiagonally, flip)
+}
+
+func (g GlobalID) withFlag(flag GlobalID, set bool) GlobalID {
+	if set {
+		return g | flag
+	}
+
+	return g &^ flag
+}
+
 // IsFlippedHorizontally returns true if the ID specifies a horizontal flip
 func (g GlobalID) IsFlippedHorizontally() bool {
 	return g&TileFlippedHorizontally != 0
@@ -76,23 +126,49 @@ type TileID uint32
 
 // Map represents a Tiled map, and is the top-level container for the map data
 type Map struct {
-	Version         string        `xml:"version,attr"`
-	Orientation     string        `xml:"orientation,attr"`
-	RenderOrder     string        `xml:"renderorder,attr"`
-	Width           int           `xml:"width,attr"`
-	Height          int           `xml:"height,attr"`
-	TileWidth       int           `xml:"tilewidth,attr"`
-	TileHeight      int           `xml:"tileheight,attr"`
-	HexSideLength   int           `xml:"hexsidelength,attr"`
-	StaggerAxis     rune          `xml:"staggeraxis,attr"`
-	StaggerIndex    string        `xml:"staggerindex,attr"`
-	BackgroundColor string        `xml:"backgroundcolor,attr"`
-	NextObjectID    ObjectID      `xml:"nextobjectid,attr"`
-	TileSets        []TileSet     `xml:"tileset"`
-	Properties      Properties    `xml:"properties>property"`
-	Layers          []Layer       `xml:"layer"`
-	ObjectGroups    []ObjectGroup `xml:"objectgroup"`
-	ImageLayers     []ImageLayer  `xml:"imagelayer"`
+	Version         string          `xml:"version,attr"`
+	Class           string          `xml:"class,attr,omitempty"`
+	Orientation     string          `xml:"orientation,attr"`
+	RenderOrder     string          `xml:"renderorder,attr"`
+	Width           int             `xml:"width,attr"`
+	Height          int             `xml:"height,attr"`
+	TileWidth       int             `xml:"tilewidth,attr"`
+	TileHeight      int             `xml:"tileheight,attr"`
+	HexSideLength   int             `xml:"hexsidelength,attr"`
+	StaggerAxis     rune            `xml:"staggeraxis,attr"`
+	StaggerIndex    string          `xml:"staggerindex,attr"`
+	BackgroundColor string          `xml:"backgroundcolor,attr"`
+	NextObjectID    ObjectID        `xml:"nextobjectid,attr"`
+	NextLayerID     int             `xml:"nextlayerid,attr"`
+	ParallaxOriginX float64         `xml:"parallaxoriginx,attr"`
+	ParallaxOriginY float64         `xml:"parallaxoriginy,attr"`
+	EditorSettings  *EditorSettings `xml:"editorsettings"`
+	TileSets        []TileSet       `xml:"tileset"`
+	Properties      Properties      `xml:"properties>property"`
+	Layers          []Layer         `xml:"layer"`
+	ObjectGroups    []ObjectGroup   `xml:"objectgroup"`
+	ImageLayers     []ImageLayer    `xml:"imagelayer"`
+	Groups          []Group         `xml:"group"`
+
+	// Raw Extras loaded from XML; any top-level elements not otherwise
+	// understood by this library, kept so that they may be preserved. Their
+	// positions among the other elements of the map are not kept, so Encode
+	// writes them together after the layers. XML comments directly within
+	// the map are dropped; only those within an extra element are kept, as
+	// part of its Content.
+	RawExtra []Tag `xml:",any"`
+
+	// Raw infinite flag loaded from XML. Not intended to be used directly; use
+	// the methods on this struct to accessed parsed data.
+	RawInfinite bool `xml:"infinite,attr"`
+
+	// directory of the file the map was decoded from, if any
+	baseDir string
+
+	// the order of the TileSets by FirstGlobalID; see tileSetOrder
+	sortedTileSets atomic.Value
+
+	frozen bool
 }
 
 // LayerWithName retrieves the first Layer matching the provided name. Returns
@@ -107,6 +183,135 @@ func (m *Map) LayerWithName(name string) *Layer {
 	return nil
 }
 
+// LayerWithID retrieves the Layer with the given ID, including those within
+// groups, as IDs are unique across the map. Returns `nil` if not found, and
+// always for an ID of 0, as in maps from before Tiled 1.2.
+func (m *Map) LayerWithID(id int) *Layer {
+	if id == 0 {
+		return nil
+	}
+
+	var found *Layer
+	m.walk(func(e interface{}) {
+		if l, ok := e.(*Layer); ok && l.ID == id && found == nil {
+			found = l
+		}
+	})
+
+	return found
+}
+
+// AllocateObjectID returns the NextObjectID of the map, and increments it, so
+// that an object added to the map is given an ID unique within it. Maps from
+// before Tiled 0.11 have no NextObjectID, in which case it begins one past the
+// largest ObjectID of the map.
+func (m *Map) AllocateObjectID() ObjectID {
+	if m.frozen {
+		panic(errFrozen)
+	}
+
+	if m.NextObjectID == 0 {
+		m.NextObjectID = 1
+		m.walk(func(e interface{}) {
+			if og, ok := e.(*ObjectGroup); ok {
+				for i := range og.Objects {
+					if id := og.Objects[i].ObjectID; id >= m.NextObjectID {
+						m.NextObjectID = id + 1
+					}
+				}
+			}
+		})
+	}
+
+	id := m.NextObjectID
+	m.NextObjectID++
+
+	return id
+}
+
+// CollisionMask builds a boolean grid from the Layer with the given name,
+// indexed as `mask[y][x]`, where a cell is true if it contains a tile. Returns
+// ErrLayerNotFound if no such layer exists.
+func (m *Map) CollisionMask(layerName string) ([][]bool, error) {
+	return m.CollisionMaskWithProperty(layerName, "")
+}
+
+// CollisionMaskWithProperty is the same as CollisionMask, but only marks cells
+// whose tile has a bool property with the given name (such as "collides") set
+// to true. An empty property name marks every non-empty cell.
+func (m *Map) CollisionMaskWithProperty(layerName, property string) ([][]bool, error) {
+	l := m.LayerWithName(layerName)
+	if l == nil {
+		return nil, ErrLayerNotFound
+	}
+
+	tds, err := l.mapTileDefs(m)
+	if err != nil {
+		return nil, err
+	}
+
+	if e := l.Width * l.Height; len(tds) != e {
+		return nil, fmt.Errorf(
+			"expected %v tiles in layer %v, got %v",
+			e, layerName, len(tds),
+		)
+	}
+
+	mask := make([][]bool, l.Height)
+	for y := range mask {
+		mask[y] = make([]bool, l.Width)
+		for x := range mask[y] {
+			td := tds[x+y*l.Width]
+			if td.Nil {
+				continue
+			}
+
+			if property == "" {
+				mask[y][x] = true
+				continue
+			}
+
+			if td.Tile != nil {
+				mask[y][x], _ = td.Tile.Properties.Bool(property)
+			}
+		}
+	}
+
+	return mask, nil
+}
+
+// TileGID returns the GlobalID at the given coordinates of the Layer with the
+// given name. Returns ErrLayerNotFound if no such layer exists.
+func (m *Map) TileGID(layerName string, x, y int) (GlobalID, error) {
+	l := m.LayerWithName(layerName)
+	if l == nil {
+		return 0, ErrLayerNotFound
+	}
+
+	return l.GlobalIDAt(x, y)
+}
+
+// TileAt returns the TileDef at the given coordinates of the Layer with the
+// given name, matched with the map's TileSets. Returns ErrLayerNotFound if no
+// such layer exists, or ErrOutOfBounds if the coordinates fall outside it.
+func (m *Map) TileAt(layerName string, x, y int) (*TileDef, error) {
+	l := m.LayerWithName(layerName)
+	if l == nil {
+		return nil, ErrLayerNotFound
+	}
+
+	if x < 0 || y < 0 || x >= l.Width || y >= l.Height {
+		return nil, ErrOutOfBounds
+	}
+
+	tds, err := l.mapTileDefs(m)
+	if err != nil {
+		return nil, err
+	}
+
+	return l.tileDefAt(tds, x, y)
+}
+
 // ObjectGroupWithName retrieves the first ObjectGroup matching the provided
 // name. Returns `nil` if not found.
 func (m *Map) ObjectGroupWithName(name string) *ObjectGroup {
@@ -119,6 +324,32 @@ func (m *Map) ObjectGroupWithName(name string) *ObjectGroup {
 	return nil
 }
 
+// ObjectWithID retrieves the Object with the given ObjectID from any
+// ObjectGroup of the map, including those within groups. Returns `nil` if not
+// found, and always for an ObjectID of 0, which Tiled never assigns.
+func (m *Map) ObjectWithID(id ObjectID) *Object {
+	if id == 0 {
+		return nil
+	}
+
+	var found *Object
+	m.walk(func(e interface{}) {
+		og, ok := e.(*ObjectGroup)
+		if !ok || found != nil {
+			return
+		}
+
+		for i := range og.Objects {
+			if og.Objects[i].ObjectID == id {
+				found = &og.Objects[i]
+				return
+			}
+		}
+	})
+
+	return found
+}
+
 // TileSetWithName retrieves the first TileSet matching the provided name.
 // Returns `nil` if not found.
 func (m *Map) TileSetWithName(name string) *TileSet {
@@ -131,24 +362,61 @@ func (m *Map) TileSetWithName(name string) *TileSet {
 	return nil
 }
 
+// EffectiveObjectProperties returns the properties of the given object merged
+// over those of the ObjectGroup containing it, merged over those of the map;
+// the object's own properties take precedence. The object must be a pointer
+// into one of the map's ObjectGroups for group properties to be found.
+func (m *Map) EffectiveObjectProperties(o *Object) Properties {
+	pl := o.Properties
+
+	if og := m.objectGroupOf(o); og != nil {
+		pl = pl.MergedWith(og.Properties)
+	}
+
+	return pl.MergedWith(m.Properties)
+}
+
+// objectGroupOf returns the ObjectGroup holding the given object, nil if none
+func (m *Map) objectGroupOf(o *Object) *ObjectGroup {
+	var found *ObjectGroup
+	m.walk(func(e interface{}) {
+		if og, ok := e.(*ObjectGroup); ok && found == nil {
+			for j := range og.Objects {
+				if &og.Objects[j] == o {
+					found = og
+				}
+			}
+		}
+	})
+
+	return found
+}
+
 // TileSet is a set of tiles, including the graphics data to be mapped to the
 // tiles, and the actual arrangement of tiles.
 type TileSet struct {
-	FirstGlobalID   GlobalID   `xml:"firstgid,attr"`
-	Source          string     `xml:"source,attr"`
-	Name            string     `xml:"name,attr"`
-	TileWidth       int        `xml:"tilewidth,attr"`
-	TileHeight      int        `xml:"tileheight,attr"`
-	Spacing         int        `xml:"spacing,attr"`
-	Margin          int        `xml:"margin,attr"`
-	TileCount       int        `xml:"tilecount,attr"`
-	Columns         int        `xml:"columns,attr"`
-	Properties      Properties `xml:"properties>property"`
-	TileOffset      TileOffset `xml:"tileoffset"`
-	ObjectAlignment string     `xml:"objectalignment,attr"`
-	Image           Image      `xml:"image"`
-	TerrainTypes    []Terrain  `xml:"terraintypes>terrain"`
-	Tiles           []Tile     `xml:"tile"`
+	FirstGlobalID   GlobalID         `xml:"firstgid,attr,omitempty"`
+	Source          string           `xml:"source,attr,omitempty"`
+	Name            string           `xml:"name,attr"`
+	Class           string           `xml:"class,attr,omitempty"`
+	TileWidth       int              `xml:"tilewidth,attr"`
+	TileHeight      int              `xml:"tileheight,attr"`
+	Spacing         int              `xml:"spacing,attr,omitempty"`
+	Margin          int              `xml:"margin,attr,omitempty"`
+	TileCount       int              `xml:"tilecount,attr"`
+	Columns         int              `xml:"columns,attr"`
+	Properties      Properties       `xml:"properties>property"`
+	TileOffset      TileOffset       `xml:"tileoffset"`
+	Grid            *Grid            `xml:"grid"`
+	Transformations *Transformations `xml:"transformations"`
+	ObjectAlignment string           `xml:"objectalignment,attr,omitempty"`
+	Image           Image            `xml:"image"`
+	TerrainTypes    []Terrain        `xml:"terraintypes>terrain"`
+	Tiles           []Tile           `xml:"tile"`
+	WangSets        []WangSet        `xml:"wangsets>wangset"`
+
+	// set once an external Source has been loaded into the TileSet
+	resolved bool
 }
 
 // TileWithID returns a pointer to the Tile with a given TileID; nil if one is
@@ -163,12 +431,236 @@ func (t *TileSet) TileWithID(id TileID) *Tile {
 	return nil
 }
 
+// TilePropsFor returns the Properties of the Tile with a given TileID, or
+// empty Properties if there is no such Tile, as Tiled only writes tiles with
+// something to note about them; the lookups of the result are always safe.
+func (t *TileSet) TilePropsFor(id TileID) Properties {
+	if tile := t.TileWithID(id); tile != nil {
+		return tile.Properties
+	}
+
+	return nil
+}
+
+// TerrainTiles returns the tiles with at least one corner of the terrain with
+// the given name, in the order they appear in the TileSet; empty if there is
+// no such terrain.
+func (t *TileSet) TerrainTiles(terrainName string) []*Tile {
+	idx := -1
+	for i := range t.TerrainTypes {
+		if t.TerrainTypes[i].Name == terrainName {
+			idx = i
+			break
+		}
+	}
+
+	var tiles []*Tile
+	if idx < 0 {
+		return tiles
+	}
+
+	want := strconv.Itoa(idx)
+	for i := range t.Tiles {
+		// corners without a terrain are left empty, which TerrainType rejects,
+		// so compare the raw corners instead
+		for _, c := range strings.Split(t.Tiles[i].RawTerrainType, ",") {
+			if strings.TrimSpace(c) == want {
+				tiles = append(tiles, &t.Tiles[i])
+				break
+			}
+		}
+	}
+
+	return tiles
+}
+
+// IsCollection returns true if the TileSet is a collection of images, where
+// each tile has an image of its own rather than being part of the image of the
+// TileSet; the tiles of such a TileSet are retrieved with TileImage, as they
+// have no TileRect.
+func (t *TileSet) IsCollection() bool {
+	if t.Image.hasImage() {
+		return false
+	}
+
+	for i := range t.Tiles {
+		if t.Tiles[i].Image.hasImage() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// TileImage returns the image of the tile with the given TileID in a
+// collection of images; ok is false if the tile has no image of its own.
+func (t *TileSet) TileImage(id TileID) (img *Image, ok bool) {
+	tile := t.TileWithID(id)
+	if tile == nil || !tile.Image.hasImage() {
+		return nil, false
+	}
+
+	return &tile.Image, true
+}
+
+// EachTile calls fn for every tile in the TileSet, from 0 to TileCount-1,
+// including those without an explicit <tile> entry, in which case tile will be
+// nil. The rect is the tile's source rectangle within the TileSet image, or
+// the bounds of the tile's own image if it has one. If TileCount is not set,
+// it is computed from the image, as with TileRect.
+func (t *TileSet) EachTile(fn func(id TileID, tile *Tile, rect image.Rectangle)) {
+	for i := 0; i < t.tileCount(); i++ {
+		id := TileID(i)
+		tile := t.TileWithID(id)
+
+		if tile != nil && tile.Image.Source != "" {
+			fn(id, tile, image.Rect(0, 0, tile.Image.Width, tile.Image.Height))
+			continue
+		}
+
+		fn(id, tile, t.tileRect(id))
+	}
+}
+
+// TileRect returns the source rectangle of a tile within the TileSet image,
+// accounting for the margin around the image and the spacing between tiles.
+// If Columns is not set, as in maps from older versions of Tiled, it is
+// computed from the width of the image, and likewise for TileCount. Returns
+// ErrTileIDOutOfRange if the TileID is not within the TileSet. A collection of
+// images has no image to take a rectangle from; see IsCollection.
+func (t *TileSet) TileRect(id TileID) (image.Rectangle, error) {
+	if int64(id) >= int64(t.tileCount()) {
+		return image.Rectangle{}, ErrTileIDOutOfRange
+	}
+
+	return t.tileRect(id), nil
+}
+
+// tileCount returns the number of tiles in the TileSet, computing it from the
+// image if TileCount is not set
+func (t *TileSet) tileCount() int {
+	count := t.TileCount
+	if count <= 0 && t.TileHeight+t.Spacing > 0 {
+		rows := (t.Image.Height - 2*t.Margin + t.Spacing) / (t.TileHeight + t.Spacing)
+		count = rows * t.ColumnsComputed()
+	}
+
+	return count
+}
+
+// ColumnsComputed returns the number of columns of tiles in the TileSet
+// image. This is Columns if it is set; otherwise, as in TileSets from older
+// versions of Tiled, it is computed from the width of the image, accounting
+// for the margin and spacing. It is at least 1.
+func (t *TileSet) ColumnsComputed() int {
+	cols := t.Columns
+	if cols <= 0 && t.TileWidth+t.Spacing > 0 {
+		cols = (t.Image.Width - 2*t.Margin + t.Spacing) / (t.TileWidth + t.Spacing)
+	}
+	if cols <= 0 {
+		cols = 1
+	}
+
+	return cols
+}
+
+// tileRect computes the source rectangle of a tile within the TileSet image
+func (t *TileSet) tileRect(id TileID) image.Rectangle {
+	cols := t.ColumnsComputed()
+
+	x := t.Margin + int(id)%cols*(t.TileWidth+t.Spacing)
+	y := t.Margin + int(id)/cols*(t.TileHeight+t.Spacing)
+
+	return image.Rect(x, y, x+t.TileWidth, y+t.TileHeight)
+}
+
 type byFirstGlobalID []TileSet
 
 func (a byFirstGlobalID) Len() int           { return len(a) }
 func (a byFirstGlobalID) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
 func (a byFirstGlobalID) Less(i, j int) bool { return a[i].FirstGlobalID < a[j].FirstGlobalID }
 
+// tileSetOrder returns pointers to each of tss, sorted by FirstGlobalID,
+// leaving tss in its order
+func tileSetOrder(tss []TileSet) []*TileSet {
+	order := make([]*TileSet, len(tss))
+	for i := range tss {
+		order[i] = &tss[i]
+	}
+
+	if !sort.IsSorted(byFirstGlobalID(tss)) {
+		sort.SliceStable(order, func(i, j int) bool {
+			return order[i].FirstGlobalID < order[j].FirstGlobalID
+		})
+	}
+
+	return order
+}
+
+// tileSetsCache is the order of the TileSets of a map by FirstGlobalID, with
+// the FirstGlobalIDs of the TileSets it was made from, in their order
+type tileSetsCache struct {
+	src       []TileSet
+	firstGIDs []GlobalID
+	order     []*TileSet
+}
+
+// SortedTileSets returns the TileSets of the map sorted by FirstGlobalID,
+// leaving the order of m.TileSets as it is. In maps from Tiled, which are
+// already in order, this is m.TileSets itself; otherwise it is a new copy,
+// made without sorting again from the order kept on the map. The TileDefs of
+// the map and its layers point into m.TileSets, not into the copy. It may be
+// called from any number of goroutines at once.
+func (m *Map) SortedTileSets() []TileSet {
+	if sort.IsSorted(byFirstGlobalID(m.TileSets)) {
+		return m.TileSets
+	}
+
+	order := m.tileSetOrder()
+	sorted := make([]TileSet, len(order))
+	for i, ts := range order {
+		sorted[i] = *ts
+	}
+
+	return sorted
+}
+
+// tileSetOrder returns pointers to each of m.TileSets sorted by
+// FirstGlobalID, which are kept until the TileSets are replaced, reordered,
+// or their FirstGlobalIDs change
+func (m *Map) tileSetOrder() []*TileSet {
+	if c, ok := m.sortedTileSets.Load().(*tileSetsCache); ok && c.matches(m.TileSets) {
+		return c.order
+	}
+
+	c := &tileSetsCache{
+		src:       m.TileSets,
+		firstGIDs: make([]GlobalID, len(m.TileSets)),
+		order:     tileSetOrder(m.TileSets),
+	}
+	for i := range m.TileSets {
+		c.firstGIDs[i] = m.TileSets[i].FirstGlobalID
+	}
+	m.sortedTileSets.Store(c)
+
+	return c.order
+}
+
+// matches returns true if the cache was made from tss as it is now
+func (c *tileSetsCache) matches(tss []TileSet) bool {
+	if len(tss) != len(c.src) || len(tss) == 0 || &tss[0] != &c.src[0] {
+		return false
+	}
+
+	for i := range tss {
+		if tss[i].FirstGlobalID != c.firstGIDs[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
 // TileOffset is used to specify an offset in pixels to be applied when drawing
 // a tile from the related TileSet
 type TileOffset struct {
@@ -176,20 +668,56 @@ type TileOffset struct {
 	Y int `xml:"y,attr"`
 }
 
+// Grid describes the grid of the tiles of a TileSet, as used when they are
+// placed as tile objects or when editing their collision shapes; this is only
+// needed for TileSets of isometric tiles. Width and Height are the size of a
+// cell of the grid, in pixels.
+type Grid struct {
+	Orientation string `xml:"orientation,attr"`
+	Width       int    `xml:"width,attr"`
+	Height      int    `xml:"height,attr"`
+}
+
+// Transformations are the ways in which the tiles of a TileSet may be
+// transformed when placed by the terrain and wang tools of Tiled.
+type Transformations struct {
+	HFlip               bool `xml:"hflip,attr"`
+	VFlip               bool `xml:"vflip,attr"`
+	Rotate              bool `xml:"rotate,attr"`
+	PreferUntransformed bool `xml:"preferuntransformed,attr"`
+}
+
 // Image represents a graphic asset to be used for a TileSet (or other
 // element). While maps created with the Tiled editor may not have the image
-// embedded, the format can support it; no additional decoding or loading is
-// attempted by this library, but the data will be available in the struct.
+// embedded, the format can support it; no loading of the Source is attempted
+// by this library, but embedded data may be decoded with DecodedData.
 type Image struct {
-	Format           string   `xml:"format,attr"`
-	ObjectID         ObjectID `xml:"id,attr"`
-	Source           string   `xml:"source,attr"`
-	TransparentColor string   `xml:"trans,attr"`
-	Width            int      `xml:"width,attr"`
-	Height           int      `xml:"height,attr"`
+	Format           string   `xml:"format,attr,omitempty"`
+	ObjectID         ObjectID `xml:"id,attr,omitempty"`
+	Source           string   `xml:"source,attr,omitempty"`
+	TransparentColor string   `xml:"trans,attr,omitempty"`
+	Width            int      `xml:"width,attr,omitempty"`
+	Height           int      `xml:"height,attr,omitempty"`
 	Data             Data     `xml:"data"`
 }
 
+// DecodedData returns the embedded data of the image, decoded and
+// decompressed; this is the image file itself, in the given Format, such as
+// "png". Returns ErrNoEmbeddedData if the image has no embedded data, as when
+// it is only referenced by its Source.
+func (i *Image) DecodedData() ([]byte, error) {
+	if len(bytes.TrimSpace(i.Data.RawBytes)) == 0 {
+		return nil, ErrNoEmbeddedData
+	}
+
+	return i.Data.Bytes()
+}
+
+// hasImage returns true if the Image refers to a source or embeds its data
+func (i *Image) hasImage() bool {
+	return i.Source != "" || len(bytes.TrimSpace(i.Data.RawBytes)) > 0
+}
+
 // Terrain defines a type of terrain and its associated tile ID.
 type Terrain struct {
 	Name       string     `xml:"name,attr"`
@@ -202,19 +730,68 @@ type Tile struct {
 	TileID      TileID      `xml:"id,attr"`
 	Probability float32     `xml:"probability,attr"`
 	Properties  Properties  `xml:"properties>property"`
-	Type        string      `xml:"type,attr"`
+	Type        string      `xml:"type,attr,omitempty"`
+	Class       string      `xml:"class,attr,omitempty"`
 	Image       Image       `xml:"image"`
 	Animation   []Frame     `xml:"animation>frame"`
 	ObjectGroup ObjectGroup `xml:"objectgroup"`
 
 	// Raw TerrainType loaded from XML. Not intended to be used directly; use
 	// the methods on this struct to accessed parsed data.
-	RawTerrainType string `xml:"terrain,attr"`
+	RawTerrainType string `xml:"terrain,attr,omitempty"`
 
 	// cache values
 	terrainType *TerrainType
 }
 
+// UnmarshalXML decodes a Tile, defaulting Probability to 1 when the attribute
+// is absent, as Tiled does.
+func (t *Tile) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type tile Tile
+	t.Probability = 1
+
+	return decodeError(d, start, d.DecodeElement((*tile)(t), &start))
+}
+
+// CollisionShapes returns the collision objects defined for the tile in the
+// tile collision editor of Tiled, held in its ObjectGroup; nil if there are
+// none. Their positions are relative to the top-left of the tile, and they are
+// otherwise the same as the objects of a map, with Kind, Points, and the
+// rest.
+func (t *Tile) CollisionShapes() []Object {
+	if t == nil {
+		return nil
+	}
+
+	return t.ObjectGroup.Objects
+}
+
+// HasCollision reports whether the tile has any collision objects in its
+// ObjectGroup. A nil Tile, a tile without an ObjectGroup, and one with an empty
+// ObjectGroup all have none.
+func (t *Tile) HasCollision() bool {
+	return len(t.CollisionShapes()) > 0
+}
+
+// EffectiveProbability returns the relative weight of the tile when chosen at
+// random, such as when filling with the terrain tool. Tiles without a <tile>
+// entry, including a nil Tile, and tiles without a probability attribute have
+// the default weight of 1, as in Tiled. An explicit probability of 0 is not
+// raised to 1: Tiled writes it for tiles which are never to be chosen at
+// random, so their weight is 0, as is that of a Tile built in code without a
+// Probability. A negative probability is also taken as 0.
+func (t *Tile) EffectiveProbability() float32 {
+	if t == nil {
+		return 1
+	}
+
+	if t.Probability < 0 {
+		return 0
+	}
+
+	return t.Probability
+}
+
 // TerrainType returns a TerrainType objects from the given Tile
 func (t *Tile) TerrainType() (*TerrainType, error) {
 	if t.RawTerrainType == "" {
@@ -265,31 +842,139 @@ type TerrainType struct {
 
 // Frame is a frame specifier in a given Animation
 type Frame struct {
-	TileID       TileID `xml:"tileid,attr"`
+	TileID       TileID `xml:"-"`
 	DurationMsec int    `xml:"duration,attr"`
+
+	// Raw TileID loaded from XML, which may carry flip bits when written by
+	// tools other than Tiled. Not intended to be used directly; use TileID and
+	// the methods on this struct to access parsed data.
+	RawTileID uint32 `xml:"tileid,attr"`
+}
+
+// UnmarshalXML decodes a Frame, separating any flip bits from its TileID
+func (f *Frame) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type frame Frame
+	if err := d.DecodeElement((*frame)(f), &start); err != nil {
+		return err
+	}
+
+	f.TileID = TileID(GlobalID(f.RawTileID).BareID())
+
+	return nil
+}
+
+// Flips returns the horizontal, vertical, and diagonal flip flags encoded in
+// the frame's raw tile ID; Tiled itself never sets these.
+func (f *Frame) Flips() (h, v, d bool) {
+	g := GlobalID(f.RawTileID)
+	return g.IsFlippedHorizontally(), g.IsFlippedVertically(), g.IsFlippedDiagonally()
+}
+
+// Duration returns the time the frame is displayed for
+func (f *Frame) Duration() time.Duration {
+	return time.Duration(f.DurationMsec) * time.Millisecond
+}
+
+// IsAnimated returns true if the tile has an animation
+func (t *Tile) IsAnimated() bool {
+	return len(t.Animation) > 0
+}
+
+// AnimationDuration returns the time taken by one cycle of the tile's
+// animation, the sum of the durations of its frames; 0 if the tile is not
+// animated. Frames with a negative duration are never shown, so count as 0.
+func (t *Tile) AnimationDuration() time.Duration {
+	var total time.Duration
+	for i := range t.Animation {
+		if d := t.Animation[i].Duration(); d > 0 {
+			total += d
+		}
+	}
+
+	return total
+}
+
+// FrameAt returns the frame of the tile's animation to display once elapsed
+// time has passed since the animation started, looping the animation as Tiled
+// does. Tiles which are not animated, or whose frames have no duration,
+// return a static frame of the tile itself.
+func (t *Tile) FrameAt(elapsed time.Duration) Frame {
+	total := t.AnimationDuration()
+	if total == 0 {
+		return Frame{TileID: t.TileID, RawTileID: uint32(t.TileID)}
+	}
+
+	elapsed %= total
+	if elapsed < 0 {
+		elapsed += total
+	}
+
+	for _, f := range t.Animation {
+		d := f.Duration()
+		if d <= 0 {
+			continue
+		}
+		if elapsed < d {
+			return f
+		}
+		elapsed -= d
+	}
+
+	// unreachable, as elapsed is less than the total duration
+	return t.Animation[len(t.Animation)-1]
 }
 
 // Layer specifies a layer of a given Map; a Layer contains tile arrangement
 // information.
 type Layer struct {
+	ID         int        `xml:"id,attr,omitempty"`
 	Name       string     `xml:"name,attr"`
-	X          int        `xml:"x,attr"`
-	Y          int        `xml:"y,attr"`
+	X          int        `xml:"x,attr,omitempty"`
+	Y          int        `xml:"y,attr,omitempty"`
 	Width      int        `xml:"width,attr"`
 	Height     int        `xml:"height,attr"`
 	Opacity    float32    `xml:"opacity,attr"`
 	Visible    bool       `xml:"visible,attr"`
-	OffsetX    int        `xml:"offsetx,attr"`
-	OffsetY    int        `xml:"offsety,attr"`
+	OffsetX    int        `xml:"offsetx,attr,omitempty"`
+	OffsetY    int        `xml:"offsety,attr,omitempty"`
+	ParallaxX  float32    `xml:"parallaxx,attr"`
+	ParallaxY  float32    `xml:"parallaxy,attr"`
+	TintColor  string     `xml:"tintcolor,attr,omitempty"`
 	Properties Properties `xml:"properties>property"`
 
 	// Raw Data loaded from XML. Not intended to be used directly; use the
 	// methods on this struct to accessed parsed data.
 	RawData Data `xml:"data"`
 
-	// cache values
-	tileGlobalRefs []TileGlobalRef
-	tileDefs       []*TileDef
+	// Z is the position of the layer in the draw order of the whole map
+	Z int `xml:"-"`
+
+	// position of the element in the decoded document
+	offset int64
+
+	// RenderOrder and ChunkSize of the map the layer was decoded with
+	renderOrder             string
+	chunkWidth, chunkHeight int
+
+	// cache values, which may be populated by concurrent readers; see
+	// cachedTileGlobalRefs, cachedTileDefs, and data
+	tileGlobalRefs atomic.Value
+	tileDefs       atomic.Value
+	parsedData     atomic.Value
+
+	frozen bool
+}
+
+// UnmarshalXML decodes a Layer, defaulting Opacity to 1 and Visible to true
+// when the attributes are absent, as Tiled does.
+func (l *Layer) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type layer Layer
+	l.Opacity = 1
+	l.Visible = true
+	l.ParallaxX, l.ParallaxY = 1, 1
+	l.offset = d.InputOffset()
+
+	return decodeError(d, start, d.DecodeElement((*layer)(l), &start))
 }
 
 // TileGlobalRefs retrieves tile reference data from the layer, after processing
@@ -301,121 +986,475 @@ func (l *Layer) TileGlobalRefs() ([]TileGlobalRef, error) {
 	}
 
 	// if we have a cached set of decoded tilerefs, return that
-	if l.tileGlobalRefs != nil {
-		return l.tileGlobalRefs, nil
+	if trs := l.cachedTileGlobalRefs(); trs != nil {
+		return trs, nil
 	}
 
-	// otherwise, we need to get the byte data and figure out what's there
-	bytes, err := l.RawData.Bytes()
+	d, err := l.data()
 	if err != nil {
 		return nil, err
 	}
 
-	var uis []uint32
-	switch l.RawData.Encoding {
-	case "base64":
-		if uis, err = decodeB64LayerData(bytes); err != nil {
+	// XML-encoded tile data may only now have been parsed
+	trs := d.TileGlobalRefs
+	if len(trs) == 0 {
+		// otherwise, we need to get the byte data and figure out what's there
+		gids, err := d.decodeGlobalIDs(nil, l.Width*l.Height)
+		if err != nil {
 			return nil, err
 		}
-	case "csv":
-		if uis, err = decodeCSVLayerData(bytes); err != nil {
+
+		trs = make([]TileGlobalRef, 0, len(gids))
+		for _, gid := range gids {
+			trs = append(trs, TileGlobalRef{
+				GlobalID: gid,
+			})
+		}
+	}
+
+	// cache the result
+	l.tileGlobalRefs.Store(trs)
+
+	return trs, nil
+}
+
+// cachedTileGlobalRefs returns the decoded tile data cached on the layer; nil
+// if it has not been decoded. The cache is written atomically, so concurrent
+// first calls each decode the data, and one of the results is kept.
+func (l *Layer) cachedTileGlobalRefs() []TileGlobalRef {
+	trs, _ := l.tileGlobalRefs.Load().([]TileGlobalRef)
+	return trs
+}
+
+// tileDefsCache is the TileDefs of a layer, with the TileSets they were
+// matched with, which they point into
+type tileDefsCache struct {
+	tss []TileSet
+	tds []*TileDef
+}
+
+// cachedTileDefs returns the TileDefs cached on the layer; nil if they have
+// not been built. Like cachedTileGlobalRefs, it is safe for concurrent use.
+func (l *Layer) cachedTileDefs() []*TileDef {
+	c, _ := l.tileDefs.Load().(*tileDefsCache)
+	if c == nil {
+		return nil
+	}
+
+	return c.tds
+}
+
+// cachedTileDefsFor returns the TileDefs cached on the layer if they were
+// matched with tss, the same slice rather than an equal one; nil otherwise.
+func (l *Layer) cachedTileDefsFor(tss []TileSet) []*TileDef {
+	c, _ := l.tileDefs.Load().(*tileDefsCache)
+	if c == nil || len(c.tss) != len(tss) || (len(tss) > 0 && &c.tss[0] != &tss[0]) {
+		return nil
+	}
+
+	return c.tds
+}
+
+// GlobalIDAt returns the GlobalID at the given coordinates in the layer.
+// Returns ErrOutOfBounds if the coordinates fall outside the layer.
+func (l *Layer) GlobalIDAt(x, y int) (GlobalID, error) {
+	if x < 0 || y < 0 || x >= l.Width || y >= l.Height {
+		return 0, ErrOutOfBounds
+	}
+
+	trs, err := l.TileGlobalRefs()
+	if err != nil {
+		return 0, err
+	}
+
+	i := x + y*l.Width
+	if i >= len(trs) {
+		return 0, ErrOutOfBounds
+	}
+
+	return trs[i].GlobalID, nil
+}
+
+// DecodeInto decodes the tile data of the layer into buf, growing it only if
+// its capacity is insufficient, and returns the resulting slice. Unlike
+// TileGlobalRefs, the result is not cached on the Layer; this is intended for
+// pipelines which decode many layers and wish to reuse a single buffer.
+func (l *Layer) DecodeInto(buf []GlobalID) ([]GlobalID, error) {
+	buf = buf[:0]
+
+	trs := l.RawData.TileGlobalRefs
+	if len(trs) == 0 {
+		trs = l.cachedTileGlobalRefs()
+	}
+
+	if trs == nil {
+		// XML-encoded tile data may not have been parsed yet
+		d, err := l.data()
+		if err != nil {
 			return nil, err
 		}
-	default:
-		return nil, ErrUnsupportedEncoding
+		if len(d.TileGlobalRefs) == 0 {
+			return d.decodeGlobalIDs(buf, 0)
+		}
+		trs = d.TileGlobalRefs
 	}
 
-	var trs []TileGlobalRef
-	for _, ui := range uis {
-		trs = append(trs, TileGlobalRef{
-			GlobalID: GlobalID(ui),
-		})
+	for _, tr := range trs {
+		buf = append(buf, tr.GlobalID)
 	}
 
-	// cache the result
-	l.tileGlobalRefs = trs
+	return buf, nil
+}
+
+// TileDefs gets the definitions for all the tiles in a given Layer, matched
+// with the given TileSets, which need not be sorted by FirstGlobalID and are
+// left in their order. The TileSet of each TileDef points into tss. The
+// TileDefs are cached for tss, the same slice; given another, they are
+// matched again. TileDefs may be called from any number of goroutines at
+// once.
+func (l *Layer) TileDefs(tss []TileSet) ([]*TileDef, error) {
+	return l.tileDefsIn(tss, func() []*TileSet { return tileSetOrder(tss) })
+}
+
+// mapTileDefs returns the TileDefs of the layer matched with the TileSets of
+// m, in the order kept on the map
+func (l *Layer) mapTileDefs(m *Map) ([]*TileDef, error) {
+	return l.tileDefsIn(m.TileSets, m.tileSetOrder)
+}
+
+// tileDefsIn returns the TileDefs of the layer matched with tss, whose order
+// by FirstGlobalID is only computed if they are not cached
+func (l *Layer) tileDefsIn(tss []TileSet, order func() []*TileSet) (tds []*TileDef, err error) {
+	if tds := l.cachedTileDefsFor(tss); tds != nil {
+		return tds, nil
+	}
+
+	tgrs, err := l.TileGlobalRefs()
+	if err != nil {
+		return tds, err
+	}
+
+	sorted := order()
+
+	tds = make([]*TileDef, 0, len(tgrs))
+	for _, tgr := range tgrs {
+		td, err := tileDefForGID(sorted, tgr.GlobalID)
+		if err != nil {
+			return tds, err
+		}
+
+		tds = append(tds, td)
+	}
+
+	l.tileDefs.Store(&tileDefsCache{tss: tss, tds: tds})
+
+	return tds, nil
+}
+
+// TileDefAt returns the TileDef of the tile at the given coordinates in the
+// layer, matched with the given TileSets. Returns ErrOutOfBounds if the
+// coordinates fall outside the layer.
+func (l *Layer) TileDefAt(tss []TileSet, x, y int) (*TileDef, error) {
+	if x < 0 || y < 0 || x >= l.Width || y >= l.Height {
+		return nil, ErrOutOfBounds
+	}
+
+	tds, err := l.TileDefs(tss)
+	if err != nil {
+		return nil, err
+	}
+
+	return l.tileDefAt(tds, x, y)
+}
+
+// tileDefAt returns the TileDef at the given coordinates of the TileDefs of
+// the layer, which are within it
+func (l *Layer) tileDefAt(tds []*TileDef, x, y int) (*TileDef, error) {
+	i := x + y*l.Width
+	if i >= len(tds) {
+		return nil, ErrOutOfBounds
+	}
+
+	return tds[i], nil
+}
+
+// EachTile calls fn with the coordinates and TileDef of every tile in the
+// layer, matched with the given TileSets, in the order they are drawn under
+// the RenderOrder of the map the layer was decoded with. The coordinates are
+// always those of the tile's cell, counted from the top-left. Iteration stops
+// at the first error returned by fn, which is returned.
+func (l *Layer) EachTile(tss []TileSet, fn func(x, y int, td *TileDef) error) error {
+	tds, err := l.TileDefs(tss)
+	if err != nil {
+		return err
+	}
+
+	if len(tds) < l.Width*l.Height {
+		return ErrOutOfBounds
+	}
+
+	for i := 0; i < l.Width*l.Height; i++ {
+		x, y := renderOrderCoords(l.renderOrder, i, l.Width, l.Height)
+		if err := fn(x, y, tds[x+y*l.Width]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ReplaceTile replaces every tile in the layer whose bare ID matches that of
+// from with the bare ID of to, preserving the flip flags of each replaced
+// tile; the flip flags of from and to are ignored. Returns the number of tiles
+// replaced. The tiles are changed where TileGlobalRefs holds them: for CSV
+// and base64 data, its decoded tiles, leaving RawData.RawBytes as it was; for
+// XML data, RawData.TileGlobalRefs itself. Encode writes the changed tiles
+// either way.
+func (l *Layer) ReplaceTile(from, to GlobalID) (int, error) {
+	return l.replaceTiles(func(g GlobalID) (GlobalID, bool) {
+		if g.BareID() != from.BareID() {
+			return g, false
+		}
+
+		return GlobalID(to.BareID()) | g&TileFlipped, true
+	})
+}
+
+// ReplaceGlobalID is the same as ReplaceTile, but only matches tiles whose
+// GlobalID, including flip flags, is exactly from, and replaces them with to
+// as given.
+func (l *Layer) ReplaceGlobalID(from, to GlobalID) (int, error) {
+	return l.replaceTiles(func(g GlobalID) (GlobalID, bool) {
+		return to, g == from
+	})
+}
+
+func (l *Layer) replaceTiles(fn func(GlobalID) (GlobalID, bool)) (int, error) {
+	if l.frozen {
+		panic(errFrozen)
+	}
+
+	trs, err := l.TileGlobalRefs()
+	if err != nil {
+		return 0, err
+	}
+
+	n := 0
+	for i := range trs {
+		if g, ok := fn(trs[i].GlobalID); ok {
+			trs[i].GlobalID = g
+			n++
+		}
+	}
+
+	if n > 0 {
+		l.tileDefs = atomic.Value{}
+	}
+
+	return n, nil
+}
+
+// Neighbors returns the GlobalIDs of the eight tiles surrounding the given
+// coordinates, in row-major order: top-left, top, top-right, left, right,
+// bottom-left, bottom, bottom-right. Neighbors outside the layer are returned
+// as 0. Returns ErrOutOfBounds if the coordinates fall outside the layer.
+func (l *Layer) Neighbors(x, y int) (n [8]GlobalID, err error) {
+	if _, err = l.GlobalIDAt(x, y); err != nil {
+		return n, err
+	}
+
+	trs, err := l.TileGlobalRefs()
+	if err != nil {
+		return n, err
+	}
+
+	i := 0
+	for dy := -1; dy <= 1; dy++ {
+		for dx := -1; dx <= 1; dx++ {
+			if dx == 0 && dy == 0 {
+				continue
+			}
+
+			nx, ny := x+dx, y+dy
+			if nx >= 0 && ny >= 0 && nx < l.Width && ny < l.Height {
+				if j := nx + ny*l.Width; j < len(trs) {
+					n[i] = trs[j].GlobalID
+				}
+			}
+
+			i++
+		}
+	}
+
+	return n, nil
+}
+
+// NeighborDefs returns the TileDefs of the eight tiles surrounding the given
+// coordinates, in the same order as Neighbors. Empty or out of bounds
+// neighbors are nil.
+func (l *Layer) NeighborDefs(x, y int, tss []TileSet) (tds [8]*TileDef, err error) {
+	n, err := l.Neighbors(x, y)
+	if err != nil {
+		return tds, err
+	}
+
+	order := tileSetOrder(tss)
+
+	for i, gid := range n {
+		if gid.BareID() == 0 {
+			continue
+		}
+
+		if tds[i], err = tileDefForGID(order, gid); err != nil {
+			return tds, err
+		}
+	}
+
+	return tds, nil
+}
 
-	return trs, nil
+// TileDefForGID resolves a single GlobalID against the given TileSets into a
+// TileDef. As with Layer.TileDefs, the TileSets are left in their order.
+func TileDefForGID(tss []TileSet, gid GlobalID) (*TileDef, error) {
+	return tileDefForGID(tileSetOrder(tss), gid)
 }
 
-// TileDefs gets the definitions for all the tiles in a given Layer, matched
-// with the given TileSets
-func (l *Layer) TileDefs(tss []TileSet) (tds []*TileDef, err error) {
-	if l.tileDefs != nil {
-		return l.tileDefs, nil
+// TileSetForGID returns the TileSet of the map which the tile of the given
+// GlobalID belongs to, one of m.TileSets; its flips are ignored.
+//
+// Returns an error wrapping ErrNoSuitableTileSet for an empty tile, with a
+// GlobalID of 0, or one before every TileSet, and ErrTileIDOutOfRange for one
+// beyond the tiles of its TileSet; an unresolved external TileSet is taken to
+// hold any number of tiles.
+func (m *Map) TileSetForGID(gid GlobalID) (*TileSet, error) {
+	if gid.BareID() == 0 {
+		return nil, fmt.Errorf("%w: global ID 0 is an empty tile", ErrNoSuitableTileSet)
 	}
 
-	tgrs, err := l.TileGlobalRefs()
+	ts, err := tileSetForGID(m.tileSetOrder(), gid)
 	if err != nil {
-		return tds, err
+		return nil, err
 	}
 
-	sort.Sort(byFirstGlobalID(tss))
+	if (ts.Source == "" || ts.resolved) && int(gid.TileID(ts)) >= ts.tileIDLimit() {
+		return nil, fmt.Errorf("%w: global ID %v in tileset %v", ErrTileIDOutOfRange, gid.BareID(), ts.label())
+	}
 
-	for _, tgr := range tgrs {
-		bid := tgr.GlobalID.BareID()
+	return ts, nil
+}
 
-		if bid == 0 {
-			tds = append(tds, &TileDef{Nil: true})
-			continue
+// tileSetForGID returns the TileSet a non-empty GlobalID falls within, by
+// FirstGlobalID alone, of TileSets which are already sorted by FirstGlobalID
+func tileSetForGID(tss []*TileSet, gid GlobalID) (*TileSet, error) {
+	bid := gid.BareID()
+
+	var ts *TileSet
+	for _, t := range tss {
+		if bid < uint32(t.FirstGlobalID) {
+			break
 		}
 
-		var ts *TileSet
-		for i := range tss {
-			t := &tss[i]
-			if bid < uint32(t.FirstGlobalID) {
-				break
-			}
+		ts = t
+	}
 
-			ts = t
-		}
+	// if we never found a tileset, the file is invalid
+	if ts == nil {
+		return nil, fmt.Errorf("%w: global ID %v; the file is invalid", ErrNoSuitableTileSet, bid)
+	}
 
-		// if we never found a tileset, the file is invalid; return an error that
-		if ts == nil {
-			return tds, fmt.Errorf(
-				"no suitable tileset found for tile with global ID %v; the file is invalid",
-				tgr.GlobalID,
-			)
-		}
+	return ts, nil
+}
 
-		id := tgr.GlobalID.TileID(ts)
-		tds = append(tds, &TileDef{
-			ID:                  id,
-			GlobalID:            tgr.GlobalID,
-			TileSet:             ts,
-			Tile:                ts.TileWithID(id),
-			HorizontallyFlipped: tgr.GlobalID.IsFlippedHorizontally(),
-			VerticallyFlipped:   tgr.GlobalID.IsFlippedVertically(),
-			DiagonallyFlipped:   tgr.GlobalID.IsFlippedDiagonally(),
-		})
+// tileDefForGID resolves a GlobalID against TileSets which are already sorted
+// by FirstGlobalID
+func tileDefForGID(tss []*TileSet, gid GlobalID) (*TileDef, error) {
+	if gid.BareID() == 0 {
+		return &TileDef{Nil: true}, nil
 	}
 
-	l.tileDefs = tds
+	ts, err := tileSetForGID(tss, gid)
+	if err != nil {
+		return nil, err
+	}
 
-	return tds, nil
+	id := gid.TileID(ts)
+	return &TileDef{
+		ID:                  id,
+		GlobalID:            gid,
+		TileSet:             ts,
+		Tile:                ts.TileWithID(id),
+		HorizontallyFlipped: gid.IsFlippedHorizontally(),
+		VerticallyFlipped:   gid.IsFlippedVertically(),
+		DiagonallyFlipped:   gid.IsFlippedDiagonally(),
+	}, nil
 }
 
 // Data represents a payload in a given object; it may be specified in several
 // different encodings and compressions, or as a straight datastructure
 // containing TileGlobalRefs
 type Data struct {
-	Encoding       string          `xml:"encoding,attr"`
-	Compression    string          `xml:"compression,attr"`
+	Encoding       string          `xml:"encoding,attr,omitempty"`
+	Compression    string          `xml:"compression,attr,omitempty"`
 	TileGlobalRefs []TileGlobalRef `xml:"tile"`
+	Chunks         []Chunk         `xml:"chunk"`
 
 	// Raw Data loaded from XML. Not intended to be used directly; use the
 	// methods on this struct to accessed parsed data.
 	RawBytes []byte `xml:",innerxml"`
+
+	// whether any tiles and chunks were left within RawBytes; see
+	// Options.SkipTileData
+	unparsed bool
+
+	// whether the compression is sniffed from the payload when missing; see
+	// Options.Lenient
+	lenient bool
 }
 
-func (d *Data) decodeB64Data() (data []byte, err error) {
+func (d *Data) decodeB64Data() ([]byte, error) {
 	raw := bytes.TrimSpace(d.RawBytes)
-	r := bytes.NewReader(raw)
-	dec := base64.NewDecoder(base64.StdEncoding, r)
+	dec := base64.NewDecoder(base64.StdEncoding, bytes.NewReader(raw))
+
+	if d.Compression != "" || !d.lenient {
+		return decompress(d.Compression, dec)
+	}
 
+	// the compression attribute may have been lost, though the payload is
+	// compressed; if it fails to decompress, it is taken to be uncompressed
+	data, err := ioutil.ReadAll(dec)
+	if err != nil {
+		return nil, err
+	}
+	if c := sniffCompression(data); c != "" {
+		if decompressed, err := decompress(c, bytes.NewReader(data)); err == nil {
+			return decompressed, nil
+		}
+	}
+
+	return data, nil
+}
+
+// sniffCompression returns the compression of data, as told by the magic
+// number or header it begins with; empty if there is none.
+func sniffCompression(data []byte) string {
+	switch {
+	case len(data) >= 2 && data[0] == 0x1f && data[1] == 0x8b:
+		return "gzip"
+	case len(data) >= 4 && binary.LittleEndian.Uint32(data) == zstdMagic:
+		return "zstd"
+	case len(data) >= 2 && data[0]&0x0f == 8 && data[0]>>4 <= 7 && (uint16(data[0])<<8|uint16(data[1]))%31 == 0:
+		// the deflate method, a window of at most 32K, and a valid check
+		return "zlib"
+	}
+
+	return ""
+}
+
+// decompress reads the whole of r, decompressed with the given compression
+func decompress(compression string, dec io.Reader) (data []byte, err error) {
 	var reader io.ReadCloser
 
-	switch d.Compression {
+	switch compression {
 	case "zlib":
 		if reader, err = zlib.NewReader(dec); err != nil {
 			return
@@ -424,6 +1463,15 @@ func (d *Data) decodeB64Data() (data []byte, err error) {
 		if reader, err = gzip.NewReader(dec); err != nil {
 			return
 		}
+	case "zstd":
+		var compressed []byte
+		if compressed, err = ioutil.ReadAll(dec); err != nil {
+			return
+		}
+		if data, err = decompressZstd(compressed); err != nil {
+			return
+		}
+		reader = ioutil.NopCloser(bytes.NewReader(data))
 	case "":
 		reader = ioutil.NopCloser(dec)
 	default:
@@ -436,6 +1484,42 @@ func (d *Data) decodeB64Data() (data []byte, err error) {
 	return
 }
 
+// decodeGlobalIDs appends the GlobalIDs encoded in the payload to dst; if dst
+// is nil, room is made for the given number of tiles first
+func (d *Data) decodeGlobalIDs(dst []GlobalID, size int) ([]GlobalID, error) {
+	if len(d.Chunks) > 0 {
+		return nil, ErrChunkedData
+	}
+
+	bytes, err := d.Bytes()
+	if err != nil {
+		return nil, err
+	}
+
+	fn, ok := lookupEncoding(d.Encoding)
+	if !ok {
+		return nil, ErrUnsupportedEncoding
+	}
+
+	// the size of the layer is taken from the file, so room is only made for
+	// as many tiles as the payload could hold
+	if dst == nil && size > 0 {
+		max := len(bytes)
+		switch d.Encoding {
+		case "base64":
+			max /= 4
+		case "csv", "":
+			max = (max + 1) / 2
+		}
+		if size > max {
+			size = max
+		}
+		dst = make([]GlobalID, 0, size)
+	}
+
+	return fn(dst, bytes)
+}
+
 // Bytes returns the byte array in the Data object, after being uncompressed and
 // decoded. In the case of a non-encoded payload, returning a zero-length array
 // is completely valid.
@@ -453,6 +1537,10 @@ func (d *Data) Bytes() ([]byte, error) {
 		return d.RawBytes, nil
 	}
 
+	if _, ok := lookupEncoding(d.Encoding); ok {
+		return d.RawBytes, nil
+	}
+
 	return nil, ErrUnsupportedEncoding
 }
 
@@ -476,41 +1564,186 @@ type TileDef struct {
 	DiagonallyFlipped   bool
 }
 
+// Class returns the class of the tile, falling back to its type as written by
+// versions of Tiled before the rename; empty if there is no tile.
+func (td *TileDef) Class() string {
+	if td == nil || td.Tile == nil {
+		return ""
+	}
+
+	if td.Tile.Class != "" {
+		return td.Tile.Class
+	}
+
+	return td.Tile.Type
+}
+
+// Orientation returns how the tile should be drawn, as a mirror followed by a
+// clockwise rotation about the tile's center. Tiled applies the diagonal flip
+// first, as a swap of the x and y axes, then the horizontal and vertical
+// flips; each combination of these is collapsed here into the equivalent
+// rotation of 0, 90, 180, or 270 degrees, and at most one mirror.
+func (td *TileDef) Orientation() (flipH, flipV bool, rotationDegrees int) {
+	h, v := td.HorizontallyFlipped, td.VerticallyFlipped
+
+	if !td.DiagonallyFlipped {
+		if h && v {
+			return false, false, 180
+		}
+		return h, v, 0
+	}
+
+	switch {
+	case h && v:
+		return true, false, 90
+	case h:
+		return false, false, 90
+	case v:
+		return false, false, 270
+	default:
+		return true, false, 270
+	}
+}
+
+// CollisionShapes returns the collision objects defined for the tile in its
+// TileSet, relative to the tile's origin; nil if there are none.
+func (td *TileDef) CollisionShapes() []Object {
+	if td == nil {
+		return nil
+	}
+
+	return td.Tile.CollisionShapes()
+}
+
+// CollisionTileDef resolves a tile object found among the tile's collision
+// shapes. The GlobalID of such a nested object belongs to the GID space of the
+// TileSet rather than the map, so it is resolved as though the TileSet's first
+// GlobalID were 1, as it is when the TileSet is saved on its own; the returned
+// TileDef carries the equivalent map GlobalID. Returns an error if o is not a
+// tile object.
+func (td *TileDef) CollisionTileDef(o *Object) (*TileDef, error) {
+	if o.GlobalID.BareID() == 0 {
+		return nil, fmt.Errorf("object %v is not a tile object", o.ObjectID)
+	}
+
+	ts := td.TileSet
+	id := TileID(o.GlobalID.BareID() - 1)
+	if ts.TileCount > 0 && int(id) >= ts.TileCount {
+		return nil, fmt.Errorf(
+			"nested tile object %v references tile %v, but tileset %v has %v tiles",
+			o.ObjectID, id, ts.Name, ts.TileCount,
+		)
+	}
+
+	gid := GlobalID(uint32(ts.FirstGlobalID)+uint32(id)) | o.GlobalID&TileFlipped
+	return &TileDef{
+		ID:                  id,
+		GlobalID:            gid,
+		TileSet:             ts,
+		Tile:                ts.TileWithID(id),
+		HorizontallyFlipped: gid.IsFlippedHorizontally(),
+		VerticallyFlipped:   gid.IsFlippedVertically(),
+		DiagonallyFlipped:   gid.IsFlippedDiagonally(),
+	}, nil
+}
+
 // ObjectGroup is a group of objects within a Map or tile, used to specify
 // sub-objects such as polygons.
 type ObjectGroup struct {
+	ID         int        `xml:"id,attr,omitempty"`
 	Name       string     `xml:"name,attr"`
-	Color      string     `xml:"color,attr"`
-	X          int        `xml:"x,attr"`
-	Y          int        `xml:"y,attr"`
-	Width      int        `xml:"width,attr"`
-	Height     int        `xml:"height,attr"`
+	Color      string     `xml:"color,attr,omitempty"`
+	X          int        `xml:"x,attr,omitempty"`
+	Y          int        `xml:"y,attr,omitempty"`
+	Width      int        `xml:"width,attr,omitempty"`
+	Height     int        `xml:"height,attr,omitempty"`
 	Opacity    float32    `xml:"opacity,attr"`
 	Visible    bool       `xml:"visible,attr"`
-	OffsetX    int        `xml:"offsetx,attr"`
-	OffsetY    int        `xml:"offsety,attr"`
-	DrawOrder  string     `xml:"draworder,attr"`
+	OffsetX    int        `xml:"offsetx,attr,omitempty"`
+	OffsetY    int        `xml:"offsety,attr,omitempty"`
+	ParallaxX  float32    `xml:"parallaxx,attr"`
+	ParallaxY  float32    `xml:"parallaxy,attr"`
+	TintColor  string     `xml:"tintcolor,attr,omitempty"`
+	DrawOrder  string     `xml:"draworder,attr,omitempty"`
 	Properties Properties `xml:"properties>property"`
 	Objects    Objects    `xml:"object"`
+
+	// Z is the position of the group in the draw order of the whole map
+	Z int `xml:"-"`
+
+	// position of the element in the decoded document
+	offset int64
+
+	frozen bool
+}
+
+// UnmarshalXML decodes an ObjectGroup, defaulting Opacity to 1 and Visible to
+// true when the attributes are absent, as Tiled does.
+func (og *ObjectGroup) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type objectGroup ObjectGroup
+	og.Opacity = 1
+	og.Visible = true
+	og.ParallaxX, og.ParallaxY = 1, 1
+	og.offset = d.InputOffset()
+
+	return decodeError(d, start, d.DecodeElement((*objectGroup)(og), &start))
+}
+
+// Translate moves every object in the group by the given delta. Only the
+// object origins are changed; polygon and polyline points are relative to
+// their object, and so move with it.
+func (og *ObjectGroup) Translate(dx, dy float64) {
+	if og.frozen {
+		panic(errFrozen)
+	}
+
+	for i := range og.Objects {
+		og.Objects[i].X += dx
+		og.Objects[i].Y += dy
+	}
+}
+
+// TranslatedCopy returns a copy of the group with every object moved by the
+// given delta, leaving the original untouched. As with Translate, only the
+// object origins are changed.
+func (og *ObjectGroup) TranslatedCopy(dx, dy float64) *ObjectGroup {
+	c := *og
+	c.frozen = false
+	c.Objects = make(Objects, len(og.Objects))
+	copy(c.Objects, og.Objects)
+	c.Translate(dx, dy)
+
+	return &c
 }
 
 // Object is an individual object, such as a Polygon, Polyline, or otherwise.
 type Object struct {
 	ObjectID   ObjectID   `xml:"id,attr"`
-	Name       string     `xml:"name,attr"`
-	Type       string     `xml:"type,attr"`
+	Name       string     `xml:"name,attr,omitempty"`
+	Type       string     `xml:"type,attr,omitempty"`
 	X          float64    `xml:"x,attr"`
 	Y          float64    `xml:"y,attr"`
-	Width      float64    `xml:"width,attr"`
-	Height     float64    `xml:"height,attr"`
-	Rotation   int        `xml:"rotation,attr"`
-	GlobalID   GlobalID   `xml:"gid,attr"`
+	Width      float64    `xml:"width,attr,omitempty"`
+	Height     float64    `xml:"height,attr,omitempty"`
+	Rotation   float64    `xml:"rotation,attr,omitempty"`
+	GlobalID   GlobalID   `xml:"gid,attr,omitempty"`
 	Visible    bool       `xml:"visible,attr"`
 	Properties Properties `xml:"properties>property"`
 	Polygons   []Poly     `xml:"polygon"`
 	Polylines  []Poly     `xml:"polyline"`
 	Image      Image      `xml:"image"`
 
+	// Text holds the text of a text object; nil for any other kind of object
+	Text *Text `xml:"text"`
+
+	// Template is the path of the template the object is an instance of, if
+	// any, relative to the map; see ResolveTemplates.
+	Template string `xml:"template,attr,omitempty"`
+
+	// attributes set on a templated object itself, which override those of
+	// its template
+	overrides []string
+
 	// Raw Extras loaded from XML. Not intended to be used directly; use the
 	// methods on this struct to accessed parsed data.
 	RawExtra []Tag `xml:",any"`
@@ -519,21 +1752,104 @@ type Object struct {
 // Objects is an array of Object
 type Objects []Object
 
-// WithName retrieves the first object with a given name, nil if none
+// WithName retrieves the first object with a given name, nil if none. The
+// object is that in the list, not a copy.
 func (ol Objects) WithName(name string) *Object {
-	for _, o := range ol {
-		if o.Name == name {
-			return &o
+	for i := range ol {
+		if ol[i].Name == name {
+			return &ol[i]
+		}
+	}
+
+	return nil
+}
+
+// WithID retrieves the object with a given ObjectID, nil if none. The object
+// is that in the list, not a copy.
+func (ol Objects) WithID(id ObjectID) *Object {
+	for i := range ol {
+		if ol[i].ObjectID == id {
+			return &ol[i]
 		}
 	}
 
 	return nil
 }
 
+// WithType retrieves every object with a given type, or class as Tiled now
+// calls it, in the order of the list; empty if there are none.
+func (ol Objects) WithType(class string) []Object {
+	var objects []Object
+	for i := range ol {
+		if ol[i].Type == class {
+			objects = append(objects, ol[i])
+		}
+	}
+
+	return objects
+}
+
 // Ellipse returns true if the object is an ellipse, else false
 func (o *Object) Ellipse() bool {
+	return o.hasExtra("ellipse")
+}
+
+// Point returns true if the object is a point, else false
+func (o *Object) Point() bool {
+	return o.hasExtra("point")
+}
+
+// ObjectKind is the kind of shape an Object takes
+type ObjectKind int
+
+// Kinds of Object
+const (
+	ObjectRectangle ObjectKind = iota
+	ObjectEllipse
+	ObjectPoint
+	ObjectPolygon
+	ObjectPolyline
+	ObjectText
+	ObjectTile
+)
+
+// Kind returns the kind of shape the object takes. Objects with a GlobalID are
+// tile objects, whatever else they hold; objects with nothing to say otherwise
+// are rectangles.
+func (o *Object) Kind() ObjectKind {
+	switch {
+	case o.GlobalID != 0:
+		return ObjectTile
+	case len(o.Polygons) > 0:
+		return ObjectPolygon
+	case len(o.Polylines) > 0:
+		return ObjectPolyline
+	case o.Ellipse():
+		return ObjectEllipse
+	case o.Point():
+		return ObjectPoint
+	case o.Text != nil:
+		return ObjectText
+	}
+
+	return ObjectRectangle
+}
+
+// TileDef returns the definition of the tile of a tile object, matched with
+// the given TileSets as with TileDefForGID, including the flips of the tile.
+// Returns ErrNotTileObject if the object has no GlobalID.
+func (o *Object) TileDef(tss []TileSet) (*TileDef, error) {
+	if o.GlobalID == 0 {
+		return nil, ErrNotTileObject
+	}
+
+	return TileDefForGID(tss, o.GlobalID)
+}
+
+// hasExtra returns true if the object has a child element with the given name
+func (o *Object) hasExtra(name string) bool {
 	for _, e := range o.RawExtra {
-		if e.XMLName.Local == "ellipse" {
+		if e.XMLName.Local == name {
 			return true
 		}
 	}
@@ -548,12 +1864,30 @@ type Poly struct {
 	RawPoints string `xml:"points,attr"`
 }
 
-// Points returns a list of points in a Poly
+// Points returns a list of points in a Poly, each rounded to the nearest
+// integer; this is lossy, as Tiled may write fractional coordinates. Use
+// PointsF for the points as written.
 func (p *Poly) Points() (pts []Point, err error) {
+	ptfs, err := p.PointsF()
+	if err != nil {
+		return nil, err
+	}
+
+	pts = make([]Point, len(ptfs))
+	for i, pt := range ptfs {
+		pts[i] = Point{int(math.Round(pt.X)), int(math.Round(pt.Y))}
+	}
+
+	return pts, nil
+}
+
+// PointsF returns a list of points in a Poly, with the precision of the
+// position of an Object.
+func (p *Poly) PointsF() (pts []PointF, err error) {
 	rpts := strings.Split(p.RawPoints, " ")
 
 	for _, rpt := range rpts {
-		var x, y int64
+		var x, y float64
 
 		xy := strings.Split(rpt, ",")
 		if l := len(xy); l != 2 {
@@ -565,16 +1899,16 @@ func (p *Poly) Points() (pts []Point, err error) {
 			return
 		}
 
-		x, err = strconv.ParseInt(xy[0], 10, 32)
+		x, err = strconv.ParseFloat(xy[0], 64)
 		if err != nil {
 			return
 		}
-		y, err = strconv.ParseInt(xy[1], 10, 32)
+		y, err = strconv.ParseFloat(xy[1], 64)
 		if err != nil {
 			return
 		}
 
-		pts = append(pts, Point{int(x), int(y)})
+		pts = append(pts, PointF{x, y})
 	}
 
 	return
@@ -585,27 +1919,76 @@ type Point struct {
 	X, Y int
 }
 
+// PointF is an X, Y coordinate in space, which may be fractional
+type PointF struct {
+	X, Y float64
+}
+
 // ImageLayer is a layer consisting of a single image, such as a background.
+// Its position may be fractional, to allow for sub-pixel placement.
 type ImageLayer struct {
+	ID         int        `xml:"id,attr,omitempty"`
 	Name       string     `xml:"name,attr"`
-	OffsetX    int        `xml:"offsetx,attr"`
-	OffsetY    int        `xml:"offsety,attr"`
-	X          int        `xml:"x,attr"`
-	Y          int        `xml:"y,attr"`
-	Width      int        `xml:"width,attr"`
-	Height     int        `xml:"height,attr"`
+	OffsetX    float64    `xml:"offsetx,attr,omitempty"`
+	OffsetY    float64    `xml:"offsety,attr,omitempty"`
+	ParallaxX  float32    `xml:"parallaxx,attr"`
+	ParallaxY  float32    `xml:"parallaxy,attr"`
+	TintColor  string     `xml:"tintcolor,attr,omitempty"`
+	X          float64    `xml:"x,attr,omitempty"`
+	Y          float64    `xml:"y,attr,omitempty"`
+	Width      int        `xml:"width,attr,omitempty"`
+	Height     int        `xml:"height,attr,omitempty"`
 	Opacity    float32    `xml:"opacity,attr"`
 	Visible    bool       `xml:"visible,attr"`
+	RepeatX    bool       `xml:"repeatx,attr,omitempty"`
+	RepeatY    bool       `xml:"repeaty,attr,omitempty"`
 	Properties Properties `xml:"properties>property"`
 	Image      Image      `xml:"image"`
+
+	// Z is the position of the layer in the draw order of the whole map
+	Z int `xml:"-"`
+
+	// position of the element in the decoded document
+	offset int64
+}
+
+// UnmarshalXML decodes an ImageLayer, defaulting Opacity to 1 and Visible to
+// true when the attributes are absent, as Tiled does.
+func (il *ImageLayer) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type imageLayer ImageLayer
+	il.Opacity = 1
+	il.Visible = true
+	il.ParallaxX, il.ParallaxY = 1, 1
+	il.offset = d.InputOffset()
+
+	return decodeError(d, start, d.DecodeElement((*imageLayer)(il), &start))
 }
 
 // Property wraps any number of custom properties, and is used as a child of a
 // number of other objects.
 type Property struct {
 	Name  string `xml:"name,attr"`
-	Type  string `xml:"type,attr"`
+	Type  string `xml:"type,attr,omitempty"`
 	Value string `xml:"value,attr"`
+
+	// PropertyType is the name of the custom type of the property, defined in
+	// the project of the map, for properties whose Type is `class`, and for
+	// enums, whose Type is `string` or `int`.
+	PropertyType string `xml:"propertytype,attr,omitempty"`
+
+	// Properties are the members of a property whose Type is `class`; only
+	// those members set to other than their default are present.
+	Properties Properties `xml:"properties>property"`
+}
+
+// Class returns the members of a property whose Type is `class`; ok is false
+// for a property of any other type.
+func (p *Property) Class() (members Properties, ok bool) {
+	if p.Type != "class" {
+		return nil, false
+	}
+
+	return p.Properties, true
 }
 
 // Properties is an array of Property objects
@@ -622,84 +2005,297 @@ func (pl Properties) WithName(name string) *Property {
 	return nil
 }
 
-// Float returns a value from a given float property
-func (pl Properties) Float(name string) (v float64, err error) {
-	p := pl.WithName(name)
-	if p == nil {
-		return v, ErrPropertyNotFound
-	}
-
-	if p.Type != "float" {
-		return v, ErrPropertyWrongType
-	}
+// MergedWith returns a new list containing every property of pl, followed by
+// those of other whose names do not appear in pl; properties in pl take
+// precedence. Neither list is modified.
+func (pl Properties) MergedWith(other Properties) Properties {
+	merged := make(Properties, len(pl), len(pl)+len(other))
+	copy(merged, pl)
 
-	if v, err = strconv.ParseFloat(p.Value, 64); err != nil {
-		return v, ErrPropertyFailedConversion
+	for _, p := range other {
+		if pl.WithName(p.Name) == nil {
+			merged = append(merged, p)
+		}
 	}
 
-	return
+	return merged
 }
 
-// Int returns a value from a given integer property
-func (pl Properties) Int(name string) (v int64, err error) {
+// Get sets the value pointed to by v from the property with the given name,
+// converting it according to the type of v, which must be one of *float64,
+// *int64, *int, *bool, *string, *color.RGBA, or *ObjectID. The property's
+// Tiled type must match: "float", "int", "bool", "string" (or none), "color",
+// or "object" respectively. Returns ErrPropertyNotFound if there is no such
+// property, ErrPropertyWrongType if its type does not match v, or
+// ErrPropertyFailedConversion if its value cannot be converted; v is left
+// unchanged on error.
+//
+// The named getters, such as Float and Int, are shorthand for Get.
+func (pl Properties) Get(name string, v interface{}) error {
 	p := pl.WithName(name)
 	if p == nil {
-		return v, ErrPropertyNotFound
+		return ErrPropertyNotFound
 	}
 
-	if p.Type != "int" {
-		return v, ErrPropertyWrongType
+	typeIs := func(types ...string) error {
+		for _, t := range types {
+			if p.Type == t {
+				return nil
+			}
+		}
+		return ErrPropertyWrongType
 	}
 
-	if v, err = strconv.ParseInt(p.Value, 10, 64); err != nil {
-		return v, ErrPropertyFailedConversion
+	switch v := v.(type) {
+	case *float64:
+		if err := typeIs("float"); err != nil {
+			return err
+		}
+		f, err := strconv.ParseFloat(p.Value, 64)
+		if err != nil {
+			return ErrPropertyFailedConversion
+		}
+		*v = f
+	case *int64, *int:
+		if err := typeIs("int"); err != nil {
+			return err
+		}
+		i, err := strconv.ParseInt(p.Value, 10, 64)
+		if err != nil {
+			return ErrPropertyFailedConversion
+		}
+		if p64, ok := v.(*int64); ok {
+			*p64 = i
+		} else {
+			*v.(*int) = int(i)
+		}
+	case *bool:
+		if err := typeIs("bool"); err != nil {
+			return err
+		}
+		*v = p.Value == "true"
+	case *string:
+		if err := typeIs("string", ""); err != nil {
+			return err
+		}
+		*v = p.Value
+	case *color.RGBA:
+		if err := typeIs("color"); err != nil {
+			return err
+		}
+		// Tiled writes an empty value for a color that is unset
+		if p.Value == "" {
+			*v = color.RGBA{}
+			return nil
+		}
+		c, err := parseColor(p.Value)
+		if err != nil {
+			return err
+		}
+		*v = c
+	case *ObjectID:
+		if err := typeIs("object"); err != nil {
+			return err
+		}
+		// a property referencing no object may be written as empty
+		if p.Value == "" {
+			*v = 0
+			return nil
+		}
+		id, err := strconv.ParseInt(p.Value, 10, 32)
+		if err != nil {
+			return ErrPropertyFailedConversion
+		}
+		*v = ObjectID(id)
+	default:
+		return fmt.Errorf("%w: cannot get a property as %T", ErrPropertyWrongType, v)
 	}
 
+	return nil
+}
+
+// Float returns a value from a given float property
+func (pl Properties) Float(name string) (v float64, err error) {
+	err = pl.Get(name, &v)
+	return
+}
+
+// Int returns a value from a given integer property
+func (pl Properties) Int(name string) (v int64, err error) {
+	err = pl.Get(name, &v)
 	return
 }
 
 // Bool returns a value from a given boolean property
 func (pl Properties) Bool(name string) (v bool, err error) {
+	err = pl.Get(name, &v)
+	return
+}
+
+// String returns a value from a given string property; Tiled omits the type
+// of string properties, so an empty type is accepted as well.
+func (pl Properties) String(name string) (v string, err error) {
+	err = pl.Get(name, &v)
+	return
+}
+
+// File returns the path from a given file property. Tiled stores these paths
+// relative to the map file, so relative paths are joined to baseDir, such as
+// from Map.BaseDir, while absolute paths are returned unchanged. Returns
+// ErrPropertyEmpty if the property has no path set.
+func (pl Properties) File(name string, baseDir string) (v string, err error) {
 	p := pl.WithName(name)
 	if p == nil {
 		return v, ErrPropertyNotFound
 	}
 
-	if p.Type != "bool" {
+	if p.Type != "file" {
 		return v, ErrPropertyWrongType
 	}
 
-	return p.Value == "true", nil
+	if p.Value == "" {
+		return v, ErrPropertyEmpty
+	}
+
+	v = filepath.FromSlash(p.Value)
+	if filepath.IsAbs(v) {
+		return p.Value, nil
+	}
+
+	return filepath.Join(baseDir, v), nil
+}
+
+// Object returns the ObjectID referenced by a given object property; follow it
+// with Map.ObjectWithID. A property referencing no object, which Tiled writes
+// as 0, returns an ObjectID of 0 and no error.
+func (pl Properties) Object(name string) (v ObjectID, err error) {
+	err = pl.Get(name, &v)
+	return
 }
 
 // Tag represents a bare XML tag; it is used to decode some not-attribute-nor-
 // data-having properties of other objects, and is not intended for direct use.
 type Tag struct {
 	XMLName xml.Name
-	Content string `xml:",innerxml"`
+	Attrs   []xml.Attr `xml:",any,attr"`
+	Content string     `xml:",innerxml"`
 }
 
 // Decode takes a reader for an XML file, and returns a new Map decoded from
 // that XML.
 func Decode(r io.Reader) (*Map, error) {
-	d := xml.NewDecoder(r)
+	return DecodeContext(context.Background(), r)
+}
+
+// DecodeContext is the same as Decode, but gives up with the error of ctx once
+// it is done, checking it as the XML is read; this bounds the time spent on
+// large maps, such as infinite maps with many chunks. Tile data is decoded
+// lazily, and is not bound by ctx.
+func DecodeContext(ctx context.Context, r io.Reader) (*Map, error) {
+	return decode(ctx, r, Options{})
+}
+
+func decode(ctx context.Context, r io.Reader, opts Options) (*Map, error) {
+	d := xml.NewDecoder(&contextReader{ctx: ctx, r: r})
 	m := new(Map)
 
-	if err := d.Decode(m); err != nil {
+	start, err := rootElement(d)
+	if err == nil {
+		err = m.decodeXML(d, start, opts)
+	}
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		return nil, decodeError(d, xml.StartElement{Name: xml.Name{Local: "map"}}, err)
+	}
+
+	if err := ctx.Err(); err != nil {
 		return nil, err
 	}
 
 	return m, nil
 }
 
+// rootElement reads the start of the root element of a document, skipping
+// the declaration and anything else before it
+func rootElement(d *xml.Decoder) (xml.StartElement, error) {
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return xml.StartElement{}, err
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			return start, nil
+		}
+	}
+}
+
+// DecodeError is returned when an XML document fails to decode, noting where
+// in the document decoding failed.
+type DecodeError struct {
+	// Offset is the byte offset in the document at which decoding failed
+	Offset int64
+	// Element is the name of the innermost element known to enclose the
+	// failure; this is the nearest map, tileset, layer, group, object, or tile.
+	Element string
+	Err     error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("tmx: decode error near offset %v in <%v>: %v", e.Offset, e.Element, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// decodeError wraps an error from decoding the given element in a
+// DecodeError, unless it was already wrapped for an element within it
+func decodeError(d *xml.Decoder, start xml.StartElement, err error) error {
+	var de *DecodeError
+	if err == nil || errors.As(err, &de) {
+		return err
+	}
+
+	return &DecodeError{Offset: d.InputOffset(), Element: start.Name.Local, Err: err}
+}
+
+// contextReader is a reader which fails with the error of its context once the
+// context is done
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r *contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	return r.r.Read(p)
+}
+
 // Same as Decode, but for TSX files
 func DecodeTileset(r io.Reader) (*TileSet, error) {
 	d := xml.NewDecoder(r)
 	ts := new(TileSet)
 
 	if err := d.Decode(ts); err != nil {
-		return nil, err
+		return nil, decodeError(d, xml.StartElement{Name: xml.Name{Local: "tileset"}}, err)
 	}
 
 	return ts, nil
 }
+
+// DecodeBytes is the same as Decode, but for a map already in memory, such as
+// one from an embed.FS.
+func DecodeBytes(b []byte) (*Map, error) {
+	return Decode(bytes.NewReader(b))
+}
+
+// DecodeTilesetBytes is the same as DecodeTileset, but for a TSX file already
+// in memory.
+func DecodeTilesetBytes(b []byte) (*TileSet, error) {
+	return DecodeTileset(bytes.NewReader(b))
+}
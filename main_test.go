@@ -1,20 +1,34 @@
 package tmx
 
 import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
 	"os"
 	"path"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestDecoder(t *testing.T) {
 	file, err := os.Open(path.Join("fixtures", "test.tmx"))
 	if err != nil {
-		t.Fatalf(err.Error())
+		t.Fatal(err)
 	}
 
 	m, err := Decode(file)
 	if err != nil {
-		t.Fatalf(err.Error())
+		t.Fatal(err)
 	}
 
 	if m == nil {
@@ -121,6 +135,16 @@ func TestDecoder(t *testing.T) {
 				t.Errorf("expected property `health` to have value `100`")
 			}
 
+			if food, err := enemy.Properties.String("food"); err != nil {
+				t.Errorf("unexpected error getting property `food`")
+			} else if food != "pizza" {
+				t.Errorf("expected property `food` to have value `pizza`")
+			}
+
+			if _, err := enemy.Properties.String("cool"); err != ErrPropertyWrongType {
+				t.Errorf("expected wrong type error for property `cool`, got %v", err)
+			}
+
 			if food := enemy.Properties.WithName("food"); food == nil {
 				t.Error("expected to find property named food, found none.")
 			} else if food.Value != "pizza" {
@@ -133,3 +157,1901 @@ func TestDecoder(t *testing.T) {
 		t.Error("expected objectgroup with name `enemies`, but found none.")
 	}
 }
+
+func decodeFixture(tb testing.TB, name string) *Map {
+	file, err := os.Open(path.Join("fixtures", name))
+	if err != nil {
+		tb.Fatal(err)
+	}
+	defer file.Close()
+
+	m, err := Decode(file)
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	return m
+}
+
+func TestCollisionMask(t *testing.T) {
+	m := decodeFixture(t, "collision.tmx")
+
+	mask, err := m.CollisionMask("walls")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := [][]bool{
+		{true, true, true, true},
+		{true, false, true, true},
+		{true, false, false, true},
+	}
+	for y := range exp {
+		for x := range exp[y] {
+			if mask[y][x] != exp[y][x] {
+				t.Errorf("(%v,%v): expected %v, got %v", x, y, exp[y][x], mask[y][x])
+			}
+		}
+	}
+
+	mask, err = m.CollisionMaskWithProperty("walls", "collides")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp = [][]bool{
+		{true, true, true, true},
+		{false, false, false, true},
+		{true, false, false, false},
+	}
+	for y := range exp {
+		for x := range exp[y] {
+			if mask[y][x] != exp[y][x] {
+				t.Errorf("(%v,%v): expected %v, got %v", x, y, exp[y][x], mask[y][x])
+			}
+		}
+	}
+
+	if _, err := m.CollisionMask("nope"); err != ErrLayerNotFound {
+		t.Errorf("expected ErrLayerNotFound, got %v", err)
+	}
+}
+
+func TestDecodeTilesetAnimated(t *testing.T) {
+	file, err := os.Open(path.Join("fixtures", "animated.tsx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	ts, err := DecodeTileset(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tile := ts.TileWithID(0)
+	if tile == nil {
+		t.Fatal("expected tile with id 0, found none")
+	}
+
+	if l := len(tile.Animation); l != 3 {
+		t.Fatalf("expected 3 animation frames, got %v", l)
+	}
+	if f := tile.Animation[1]; f.TileID != 1 || f.DurationMsec != 200 {
+		t.Errorf("unexpected animation frame %+v", f)
+	}
+
+	if water, err := tile.Properties.Bool("water"); err != nil || !water {
+		t.Errorf("expected property `water` to be true, got %v (%v)", water, err)
+	}
+
+	if tile := ts.TileWithID(4); tile == nil {
+		t.Error("expected tile with id 4, found none")
+	} else if l := len(tile.ObjectGroup.Objects); l != 1 {
+		t.Errorf("expected 1 collision object, got %v", l)
+	}
+}
+
+func TestTileFrameAt(t *testing.T) {
+	file, err := os.Open(path.Join("fixtures", "animated.tsx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	ts, err := DecodeTileset(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tile := ts.TileWithID(0)
+	if !tile.IsAnimated() {
+		t.Fatal("expected tile 0 to be animated")
+	}
+	if d := tile.AnimationDuration(); d != 600*time.Millisecond {
+		t.Errorf("expected animation of 600ms, got %v", d)
+	}
+	if d := tile.Animation[1].Duration(); d != 200*time.Millisecond {
+		t.Errorf("expected frame of 200ms, got %v", d)
+	}
+
+	for _, c := range []struct {
+		elapsed time.Duration
+		expect  TileID
+	}{
+		{0, 0},
+		{99 * time.Millisecond, 0},
+		{100 * time.Millisecond, 1},
+		{299 * time.Millisecond, 1},
+		{300 * time.Millisecond, 2},
+		{600 * time.Millisecond, 0},
+		{1150 * time.Millisecond, 2},
+		{-50 * time.Millisecond, 2},
+	} {
+		if f := tile.FrameAt(c.elapsed); f.TileID != c.expect {
+			t.Errorf("expected frame of tile %v at %v, got %+v", c.expect, c.elapsed, f)
+		}
+	}
+
+	static := ts.TileWithID(4)
+	if static.IsAnimated() {
+		t.Error("expected tile 4 not to be animated")
+	}
+	if f := static.FrameAt(time.Second); f.TileID != 4 || f.DurationMsec != 0 {
+		t.Errorf("expected static frame of tile 4, got %+v", f)
+	}
+	if d := static.AnimationDuration(); d != 0 {
+		t.Errorf("expected no animation, got %v", d)
+	}
+}
+
+func TestDecodeInto(t *testing.T) {
+	m := decodeFixture(t, "test.tmx")
+	walls := m.LayerWithName("walls")
+
+	trs, err := walls.TileGlobalRefs()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := make([]GlobalID, 0, len(trs))
+	gids, err := walls.DecodeInto(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if l, e := len(gids), len(trs); l != e {
+		t.Fatalf("expected %v ids, got %v", e, l)
+	}
+	if &gids[0] != &buf[:1][0] {
+		t.Error("expected the provided buffer to be reused")
+	}
+	for i := range trs {
+		if gids[i] != trs[i].GlobalID {
+			t.Errorf("idx(%v): expected %v, got %v", i, trs[i].GlobalID, gids[i])
+		}
+	}
+}
+
+var benchmarkLayers = []struct {
+	name    string
+	fixture string
+	layer   string
+}{
+	{"base64", "test.tmx", "walls"},
+	{"csv", "collision.tmx", "walls"},
+}
+
+func BenchmarkTileGlobalRefs(b *testing.B) {
+	for _, bl := range benchmarkLayers {
+		l := decodeFixture(b, bl.fixture).LayerWithName(bl.layer)
+
+		b.Run(bl.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				l.tileGlobalRefs = atomic.Value{}
+				if _, err := l.TileGlobalRefs(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkTileDefs(b *testing.B) {
+	m := decodeFixture(b, "collision.tmx")
+	layers := map[string]*Layer{
+		"csv":   m.LayerWithName("walls"),
+		"large": largeLayer(256, 256),
+	}
+
+	for _, name := range []string{"csv", "large"} {
+		l := layers[name]
+
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				l.tileDefs = atomic.Value{}
+				if _, err := l.TileDefs(m.TileSets); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestTileGlobalRefsSize(t *testing.T) {
+	for _, c := range []struct {
+		width, height int
+	}{
+		{-2, 2},
+		{0, 0},
+		{1 << 20, 1 << 20},
+	} {
+		l := largeLayer(2, 2)
+		l.Width, l.Height = c.width, c.height
+
+		trs, err := l.TileGlobalRefs()
+		if err != nil {
+			t.Errorf("%vx%v: %v", c.width, c.height, err)
+		} else if len(trs) != 4 {
+			t.Errorf("%vx%v: expected the 4 tiles of the payload, got %v", c.width, c.height, len(trs))
+		}
+	}
+
+	// room is only made for as many tiles as the payload could hold
+	gids, err := largeLayer(2, 2).RawData.decodeGlobalIDs(nil, 1<<30)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := cap(gids); c > 4 {
+		t.Errorf("expected room for at most 4 tiles, got %v", c)
+	}
+}
+
+// largeLayer returns a CSV-encoded layer of the given size, with the tiles of
+// the first four GlobalIDs in turn
+func largeLayer(w, h int) *Layer {
+	var buf bytes.Buffer
+	for i := 0; i < w*h; i++ {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		buf.WriteString(strconv.Itoa(i%4 + 1))
+	}
+
+	return &Layer{
+		Width:   w,
+		Height:  h,
+		RawData: Data{Encoding: "csv", RawBytes: buf.Bytes()},
+	}
+}
+
+func BenchmarkDecodeInto(b *testing.B) {
+	for _, bl := range benchmarkLayers {
+		l := decodeFixture(b, bl.fixture).LayerWithName(bl.layer)
+
+		b.Run(bl.name, func(b *testing.B) {
+			var buf []GlobalID
+			var err error
+
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if buf, err = l.DecodeInto(buf); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestImageLayerFractionalPosition(t *testing.T) {
+	m := decodeFixture(t, "imagelayer.tmx")
+
+	if l := len(m.ImageLayers); l != 2 {
+		t.Fatalf("expected 2 image layers, got %v", l)
+	}
+
+	sky := m.ImageLayers[0]
+	if sky.OffsetX != 12.5 || sky.OffsetY != -3.25 {
+		t.Errorf("expected offset (12.5, -3.25), got (%v, %v)", sky.OffsetX, sky.OffsetY)
+	}
+
+	hills := m.ImageLayers[1]
+	if hills.X != 1.75 || hills.Y != 2 {
+		t.Errorf("expected position (1.75, 2), got (%v, %v)", hills.X, hills.Y)
+	}
+}
+
+func TestImageLayerRepeat(t *testing.T) {
+	m := decodeFixture(t, "parallax.tmx")
+
+	sky := m.ImageLayers[0]
+	if !sky.RepeatX || sky.RepeatY {
+		t.Errorf("expected image layer to repeat only horizontally, got %+v", sky)
+	}
+
+	var buf bytes.Buffer
+	if err := Encode(&buf, m); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(buf.Bytes(), []byte(` repeatx="1"`)) || bytes.Contains(buf.Bytes(), []byte(`repeaty=`)) {
+		t.Errorf("expected only repeatx=\"1\" to be encoded, got %s", buf.Bytes())
+	}
+	if rm := roundTrip(t, m); !rm.ImageLayers[0].RepeatX || rm.ImageLayers[0].RepeatY {
+		t.Errorf("expected repetition to be kept, got %+v", rm.ImageLayers[0])
+	}
+
+	m, err := DecodeJSON(strings.NewReader(`{"layers": [{"type": "imagelayer", "name": "floor", "repeaty": true}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if floor := m.ImageLayers[0]; floor.RepeatX || !floor.RepeatY {
+		t.Errorf("expected image layer to repeat only vertically, got %+v", floor)
+	}
+}
+
+func TestTileSetEachTile(t *testing.T) {
+	ts := TileSet{
+		TileWidth:  16,
+		TileHeight: 16,
+		Margin:     1,
+		Spacing:    2,
+		TileCount:  6,
+		Columns:    3,
+		Tiles:      []Tile{{TileID: 4}},
+	}
+
+	var ids []TileID
+	ts.EachTile(func(id TileID, tile *Tile, rect image.Rectangle) {
+		ids = append(ids, id)
+
+		if (tile != nil) != (id == 4) {
+			t.Errorf("id(%v): unexpected tile %v", id, tile)
+		}
+
+		if id == 4 {
+			if e := image.Rect(19, 19, 35, 35); rect != e {
+				t.Errorf("id(%v): expected rect %v, got %v", id, e, rect)
+			}
+		}
+	})
+
+	if l := len(ids); l != ts.TileCount {
+		t.Errorf("expected %v tiles, got %v", ts.TileCount, l)
+	}
+}
+
+func TestTileSetTileRect(t *testing.T) {
+	ts := TileSet{
+		TileWidth:  16,
+		TileHeight: 16,
+		Margin:     1,
+		Spacing:    2,
+		TileCount:  6,
+		Columns:    3,
+	}
+
+	if r, err := ts.TileRect(5); err != nil || r != image.Rect(37, 19, 53, 35) {
+		t.Errorf("expected rect for tile 5, got %v (%v)", r, err)
+	}
+	if _, err := ts.TileRect(6); err != ErrTileIDOutOfRange {
+		t.Errorf("expected ErrTileIDOutOfRange, got %v", err)
+	}
+
+	// without Columns or TileCount, both are computed from the image
+	ts.Columns, ts.TileCount = 0, 0
+	ts.Image = Image{Width: 55, Height: 37}
+
+	if r, err := ts.TileRect(4); err != nil || r != image.Rect(19, 19, 35, 35) {
+		t.Errorf("expected rect for tile 4, got %v (%v)", r, err)
+	}
+	if _, err := ts.TileRect(6); err != ErrTileIDOutOfRange {
+		t.Errorf("expected ErrTileIDOutOfRange, got %v", err)
+	}
+}
+
+func TestTileSetColumnsComputed(t *testing.T) {
+	file, err := os.Open(path.Join("fixtures", "legacy.tsx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	ts, err := DecodeTileset(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ts.Columns != 0 {
+		t.Fatalf("expected no columns in fixture, got %v", ts.Columns)
+	}
+	if c := ts.ColumnsComputed(); c != 3 {
+		t.Errorf("expected 3 columns, got %v", c)
+	}
+
+	if r, err := ts.TileRect(4); err != nil || r != image.Rect(19, 19, 35, 35) {
+		t.Errorf("expected rect for tile 4, got %v (%v)", r, err)
+	}
+
+	var rects []image.Rectangle
+	ts.EachTile(func(id TileID, tile *Tile, rect image.Rectangle) {
+		rects = append(rects, rect)
+	})
+	if l := len(rects); l != 6 {
+		t.Fatalf("expected 6 tiles, got %v", l)
+	}
+	if r := rects[5]; r != image.Rect(37, 19, 53, 35) {
+		t.Errorf("expected rect for tile 5, got %v", r)
+	}
+
+	ts.Columns = 2
+	if c := ts.ColumnsComputed(); c != 2 {
+		t.Errorf("expected Columns to be used when set, got %v", c)
+	}
+	if c := (&TileSet{}).ColumnsComputed(); c != 1 {
+		t.Errorf("expected at least 1 column, got %v", c)
+	}
+}
+
+func TestTileSetCollection(t *testing.T) {
+	file, err := os.Open(path.Join("fixtures", "collection.tsx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	ts, err := DecodeTileset(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !ts.IsCollection() {
+		t.Error("expected tileset to be a collection of images")
+	}
+
+	for _, c := range []struct {
+		id     TileID
+		source string
+		ok     bool
+	}{
+		{0, "tree.png", true},
+		{1, "rock.png", true},
+		{2, "", false},
+		{3, "flower.png", true},
+	} {
+		img, ok := ts.TileImage(c.id)
+		if ok != c.ok || (ok && img.Source != c.source) {
+			t.Errorf("tile %v: expected image %q (%v), got %+v (%v)", c.id, c.source, c.ok, img, ok)
+		}
+	}
+
+	atlas := decodeFixture(t, "test.tmx").TileSets[0]
+	if atlas.IsCollection() {
+		t.Error("expected tileset with an image not to be a collection")
+	}
+	if img, ok := atlas.TileImage(0); ok {
+		t.Errorf("expected no image for tile of atlas, got %+v", img)
+	}
+}
+
+func TestTileSetGrid(t *testing.T) {
+	ts, err := DecodeTileset(strings.NewReader(`<tileset name="blocks" tilewidth="64" tileheight="64" tilecount="0" columns="0">
+		<grid orientation="isometric" width="64" height="32"/>
+	</tileset>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if g := ts.Grid; g == nil || *g != (Grid{Orientation: "isometric", Width: 64, Height: 32}) {
+		t.Errorf("unexpected grid %+v", g)
+	}
+
+	if g := decodeFixture(t, "test.tmx").TileSets[0].Grid; g != nil {
+		t.Errorf("expected no grid, got %+v", g)
+	}
+
+	var b bytes.Buffer
+	if err := xml.NewEncoder(&b).Encode(ts); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), `<grid orientation="isometric" width="64" height="32">`) {
+		t.Errorf("expected grid to be encoded, got %v", b.String())
+	}
+}
+
+func TestDecodeNamespaced(t *testing.T) {
+	m := decodeFixture(t, "namespaced.tmx")
+
+	if ts := m.TileSetWithName("blocks"); ts == nil {
+		t.Error("expected tileset with name `blocks`, but found none.")
+	}
+
+	ground := m.LayerWithName("ground")
+	if ground == nil {
+		t.Fatal("expected layer with name `ground`, but found none.")
+	}
+
+	trs, err := ground.TileGlobalRefs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l := len(trs); l != 4 {
+		t.Errorf("expected 4 tiles, got %v", l)
+	}
+
+	things := m.ObjectGroupWithName("things")
+	if things == nil {
+		t.Fatal("expected objectgroup with name `things`, but found none.")
+	}
+	if thing := things.Objects.WithName("thing"); thing == nil {
+		t.Error("expected object with name `thing`, but found none.")
+	} else if cool, err := thing.Properties.Bool("cool"); err != nil || !cool {
+		t.Errorf("expected property `cool` to be true, got %v (%v)", cool, err)
+	}
+}
+
+func TestTileGID(t *testing.T) {
+	m := decodeFixture(t, "collision.tmx")
+
+	for _, c := range []struct {
+		x, y int
+		gid  GlobalID
+		err  error
+	}{
+		{0, 0, 1, nil},
+		{2, 1, 3, nil},
+		{3, 2, 4, nil},
+		{1, 2, 0, nil},
+		{4, 0, 0, ErrOutOfBounds},
+		{0, -1, 0, ErrOutOfBounds},
+	} {
+		gid, err := m.TileGID("walls", c.x, c.y)
+		if err != c.err {
+			t.Errorf("(%v,%v): expected error %v, got %v", c.x, c.y, c.err, err)
+		}
+		if gid != c.gid {
+			t.Errorf("(%v,%v): expected gid %v, got %v", c.x, c.y, c.gid, gid)
+		}
+	}
+
+	if _, err := m.TileGID("nope", 0, 0); err != ErrLayerNotFound {
+		t.Errorf("expected ErrLayerNotFound, got %v", err)
+	}
+}
+
+func TestNewGlobalID(t *testing.T) {
+	for _, c := range []struct {
+		bare                uint32
+		flipH, flipV, flipD bool
+		exp                 GlobalID
+	}{
+		{7, false, false, false, 7},
+		{7, true, false, false, 7 | TileFlippedHorizontally},
+		{7, false, true, true, 7 | TileFlippedVertically | TileFlippedDiagonally},
+		{7, true, true, true, 7 | TileFlipped},
+		{7 | TileFlippedHorizontally, false, false, false, 7},
+	} {
+		g := NewGlobalID(c.bare, c.flipH, c.flipV, c.flipD)
+		if g != c.exp {
+			t.Errorf("%v %v %v %v: expected %v, got %v", c.bare, c.flipH, c.flipV, c.flipD, c.exp, g)
+		}
+		if g.BareID() != 7 || g.IsFlippedHorizontally() != c.flipH || g.IsFlippedVertically() != c.flipV || g.IsFlippedDiagonally() != c.flipD {
+			t.Errorf("%v: unexpected flips of %v", c.exp, g)
+		}
+	}
+
+	g := GlobalID(3).WithFlipVertically(true).WithFlipDiagonally(true).WithFlipVertically(false)
+	if g != 3|TileFlippedDiagonally {
+		t.Errorf("expected %v, got %v", GlobalID(3|TileFlippedDiagonally), g)
+	}
+}
+
+func TestTileAt(t *testing.T) {
+	m := decodeFixture(t, "collision.tmx")
+
+	td, err := m.TileAt("walls", 3, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if td.ID != 3 || td.TileSet != m.TileSetWithName("blocks") {
+		t.Errorf("expected tile 3 of `blocks`, got %+v", td)
+	}
+
+	again, err := m.TileAt("walls", 3, 2)
+	if err != nil || again != td {
+		t.Errorf("expected cached TileDef to be returned, got %+v (%v)", again, err)
+	}
+
+	if _, err := m.TileAt("walls", 4, 0); err != ErrOutOfBounds {
+		t.Errorf("expected ErrOutOfBounds, got %v", err)
+	}
+	if _, err := m.TileAt("nope", 0, 0); err != ErrLayerNotFound {
+		t.Errorf("expected ErrLayerNotFound, got %v", err)
+	}
+}
+
+func TestFrameFlips(t *testing.T) {
+	var tile Tile
+	err := xml.Unmarshal([]byte(`<tile id="0"><animation>
+		<frame tileid="3" duration="100"/>
+		<frame tileid="2684354563" duration="100"/>
+	</animation></tile>`), &tile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plain, flipped := tile.Animation[0], tile.Animation[1]
+
+	if plain.TileID != 3 || plain.RawTileID != 3 {
+		t.Errorf("expected plain frame tile id 3, got %v (raw %v)", plain.TileID, plain.RawTileID)
+	}
+	if h, v, d := plain.Flips(); h || v || d {
+		t.Errorf("expected plain frame to be unflipped, got %v %v %v", h, v, d)
+	}
+
+	if flipped.TileID != 3 {
+		t.Errorf("expected flipped frame tile id 3, got %v", flipped.TileID)
+	}
+	if h, v, d := flipped.Flips(); !h || v || !d {
+		t.Errorf("expected flipped frame to be flipped h+d, got %v %v %v", h, v, d)
+	}
+}
+
+func TestOpacity(t *testing.T) {
+	m := decodeFixture(t, "opacity.tmx")
+
+	for name, e := range map[string]float32{
+		"hidden":  0,
+		"default": 1,
+		"integer": 1,
+		"half":    0.5,
+	} {
+		if o := m.LayerWithName(name).Opacity; o != e {
+			t.Errorf("layer %v: expected opacity %v, got %v", name, e, o)
+		}
+	}
+
+	if o := m.ObjectGroupWithName("hidden").Opacity; o != 0 {
+		t.Errorf("objectgroup hidden: expected opacity 0, got %v", o)
+	}
+	if o := m.ObjectGroupWithName("default").Opacity; o != 1 {
+		t.Errorf("objectgroup default: expected opacity 1, got %v", o)
+	}
+
+	if o := m.ImageLayers[0].Opacity; o != 0 {
+		t.Errorf("imagelayer hidden: expected opacity 0, got %v", o)
+	}
+	if o := m.ImageLayers[1].Opacity; o != 1 {
+		t.Errorf("imagelayer default: expected opacity 1, got %v", o)
+	}
+}
+
+func TestVisible(t *testing.T) {
+	m, err := Decode(strings.NewReader(`<map>
+		<layer name="shown"/>
+		<layer name="hidden" visible="0"/>
+		<objectgroup name="shown"><object id="1"/><object id="2" visible="0"/></objectgroup>
+		<objectgroup name="hidden" visible="0"/>
+		<imagelayer name="shown"/>
+		<group name="hidden" visible="0"><imagelayer name="hidden" visible="0"/></group>
+	</map>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m.walk(func(e interface{}) {
+		var name string
+		var visible bool
+		switch e := e.(type) {
+		case *Layer:
+			name, visible = e.Name, e.Visible
+		case *ObjectGroup:
+			name, visible = e.Name, e.Visible
+		case *ImageLayer:
+			name, visible = e.Name, e.Visible
+		case *Group:
+			name, visible = e.Name, e.Visible
+		}
+
+		if exp := name == "shown"; visible != exp {
+			t.Errorf("%T %v: expected visible %v, got %v", e, name, exp, visible)
+		}
+	})
+
+	objects := m.ObjectGroupWithName("shown").Objects
+	if !objects[0].Visible || objects[1].Visible {
+		t.Errorf("expected only the first object to be visible, got %v and %v", objects[0].Visible, objects[1].Visible)
+	}
+}
+
+func TestObjectGroupTranslate(t *testing.T) {
+	m := decodeFixture(t, "test.tmx")
+	obstacles := m.ObjectGroupWithName("obstacles")
+
+	moved := obstacles.TranslatedCopy(10, -5)
+	if o, c := obstacles.Objects[0], moved.Objects[0]; c.X != o.X+10 || c.Y != o.Y-5 {
+		t.Errorf("expected copy at (%v,%v), got (%v,%v)", o.X+10, o.Y-5, c.X, c.Y)
+	}
+	if c := moved.Objects[0]; c.Polygons[0].RawPoints != obstacles.Objects[0].Polygons[0].RawPoints {
+		t.Error("expected polygon points to be unchanged")
+	}
+	if x := obstacles.Objects[0].X; x != 176 {
+		t.Errorf("expected original to be unchanged at x 176, got %v", x)
+	}
+
+	obstacles.Translate(1.5, 2)
+	if o := obstacles.Objects[0]; o.X != 177.5 || o.Y != 402 {
+		t.Errorf("expected object at (177.5,402), got (%v,%v)", o.X, o.Y)
+	}
+}
+
+func TestPropertiesGet(t *testing.T) {
+	pl := Properties{
+		{Name: "velX", Type: "float", Value: "1.1"},
+		{Name: "health", Type: "int", Value: "100"},
+		{Name: "cool", Type: "bool", Value: "true"},
+		{Name: "food", Value: "pizza"},
+		{Name: "tint", Type: "color", Value: "#80ff0000"},
+		{Name: "target", Type: "object", Value: "12"},
+		{Name: "broken", Type: "int", Value: "many"},
+	}
+
+	var f float64
+	if err := pl.Get("velX", &f); err != nil || f != 1.1 {
+		t.Errorf("expected 1.1, got %v (%v)", f, err)
+	}
+
+	var i64 int64
+	if err := pl.Get("health", &i64); err != nil || i64 != 100 {
+		t.Errorf("expected 100, got %v (%v)", i64, err)
+	}
+	var i int
+	if err := pl.Get("health", &i); err != nil || i != 100 {
+		t.Errorf("expected 100, got %v (%v)", i, err)
+	}
+
+	var b bool
+	if err := pl.Get("cool", &b); err != nil || !b {
+		t.Errorf("expected true, got %v (%v)", b, err)
+	}
+
+	var s string
+	if err := pl.Get("food", &s); err != nil || s != "pizza" {
+		t.Errorf("expected pizza, got %v (%v)", s, err)
+	}
+
+	var c color.RGBA
+	if err := pl.Get("tint", &c); err != nil || c != (color.RGBA{0x80, 0, 0, 0x80}) {
+		t.Errorf("expected red, got %v (%v)", c, err)
+	}
+
+	var id ObjectID
+	if err := pl.Get("target", &id); err != nil || id != 12 {
+		t.Errorf("expected 12, got %v (%v)", id, err)
+	}
+
+	for _, c := range []struct {
+		name string
+		v    interface{}
+		err  error
+	}{
+		{"nope", &f, ErrPropertyNotFound},
+		{"health", &f, ErrPropertyWrongType},
+		{"velX", &s, ErrPropertyWrongType},
+		{"broken", &i, ErrPropertyFailedConversion},
+		{"food", &[]string{}, ErrPropertyWrongType},
+	} {
+		if err := pl.Get(c.name, c.v); !errors.Is(err, c.err) {
+			t.Errorf("%v as %T: expected %v, got %v", c.name, c.v, c.err, err)
+		}
+	}
+
+	if i != 100 {
+		t.Errorf("expected value to be unchanged on error, got %v", i)
+	}
+}
+
+func TestDecodeTilesetProperties(t *testing.T) {
+	file, err := os.Open(path.Join("fixtures", "properties.tsx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	ts, err := DecodeTileset(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if v, err := ts.Properties.Int("layer"); err != nil || v != 3 {
+		t.Errorf("expected tileset property `layer` to be 3, got %v (%v)", v, err)
+	}
+	if v, err := ts.Properties.Bool("solid"); err != nil || !v {
+		t.Errorf("expected tileset property `solid` to be true, got %v (%v)", v, err)
+	}
+
+	tile := ts.TileWithID(2)
+	if tile == nil {
+		t.Fatal("expected tile with id 2, found none")
+	}
+	if v, err := tile.Properties.Int("damage"); err != nil || v != -7 {
+		t.Errorf("expected tile property `damage` to be -7, got %v (%v)", v, err)
+	}
+	if v, err := tile.Properties.Bool("lava"); err != nil || !v {
+		t.Errorf("expected tile property `lava` to be true, got %v (%v)", v, err)
+	}
+	if _, err := tile.Properties.Int("lava"); err != ErrPropertyWrongType {
+		t.Errorf("expected ErrPropertyWrongType, got %v", err)
+	}
+
+	if v, err := ts.TilePropsFor(2).Int("damage"); err != nil || v != -7 {
+		t.Errorf("expected tile property `damage` to be -7, got %v (%v)", v, err)
+	}
+	if _, err := ts.TilePropsFor(1).Int("damage"); err != ErrPropertyNotFound {
+		t.Errorf("expected ErrPropertyNotFound for tile without entry, got %v", err)
+	}
+	if p := ts.TilePropsFor(1).WithName("damage"); p != nil {
+		t.Errorf("expected no property for tile without entry, got %+v", p)
+	}
+}
+
+func TestClassProperties(t *testing.T) {
+	file, err := os.Open(path.Join("fixtures", "properties.tsx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	ts, err := DecodeTileset(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pl := ts.TileWithID(2).Properties
+
+	facing := pl.WithName("facing")
+	if facing.PropertyType != "Direction" || facing.Value != "north" {
+		t.Errorf("unexpected enum property %+v", facing)
+	}
+	if _, ok := facing.Class(); ok {
+		t.Error("expected enum property not to be a class")
+	}
+
+	stats := pl.WithName("stats")
+	if stats.PropertyType != "Stats" {
+		t.Errorf("expected property type Stats, got %v", stats.PropertyType)
+	}
+	members, ok := stats.Class()
+	if !ok {
+		t.Fatal("expected property `stats` to be a class")
+	}
+	if hp, err := members.Int("hp"); err != nil || hp != 10 {
+		t.Errorf("expected member `hp` to be 10, got %v (%v)", hp, err)
+	}
+
+	resist, ok := members.WithName("resist").Class()
+	if !ok {
+		t.Fatal("expected member `resist` to be a class")
+	}
+	if fire, err := resist.Float("fire"); err != nil || fire != 0.5 {
+		t.Errorf("expected member `fire` to be 0.5, got %v (%v)", fire, err)
+	}
+
+	var b bytes.Buffer
+	if err := xml.NewEncoder(&b).Encode(ts); err != nil {
+		t.Fatal(err)
+	}
+	rts, err := DecodeTileset(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(rts.TileWithID(2).Properties, pl) {
+		t.Errorf("expected class properties to be encoded\n%+v\n%+v", rts.TileWithID(2).Properties, pl)
+	}
+
+	m, err := DecodeJSON(strings.NewReader(`{"properties": [
+		{"name": "facing", "type": "string", "propertytype": "Direction", "value": "north"},
+		{"name": "stats", "type": "class", "propertytype": "Stats", "value": {"resist": {"fire": 0.5}, "hp": 10}}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e := Properties{
+		{Name: "facing", Value: "north", PropertyType: "Direction"},
+		{Name: "stats", Type: "class", PropertyType: "Stats", Properties: Properties{
+			{Name: "hp", Type: "int", Value: "10"},
+			{Name: "resist", Type: "class", Properties: Properties{
+				{Name: "fire", Type: "float", Value: "0.5"},
+			}},
+		}},
+	}
+	if !reflect.DeepEqual(m.Properties, e) {
+		t.Errorf("expected JSON class properties\n%+v\ngot\n%+v", e, m.Properties)
+	}
+}
+
+func TestMapRawExtra(t *testing.T) {
+	m := decodeFixture(t, "extra.tmx")
+
+	if l := len(m.RawExtra); l != 1 {
+		t.Fatalf("expected 1 unknown element, got %v", l)
+	}
+
+	future := m.RawExtra[0]
+	if n := future.XMLName.Local; n != "future" {
+		t.Errorf("expected second element to be `future`, got `%v`", n)
+	}
+	if l := len(future.Attrs); l != 1 || future.Attrs[0].Name.Local != "answer" || future.Attrs[0].Value != "42" {
+		t.Errorf("expected attribute answer=42, got %v", future.Attrs)
+	}
+	if c := strings.TrimSpace(future.Content); c != "<thing/>" {
+		t.Errorf("expected content `<thing/>`, got `%v`", c)
+	}
+
+	if l := len(m.Layers); l != 1 {
+		t.Errorf("expected 1 layer, got %v", l)
+	}
+	if l := len(m.Properties); l != 1 {
+		t.Errorf("expected 1 property, got %v", l)
+	}
+}
+
+func TestNeighbors(t *testing.T) {
+	m := decodeFixture(t, "collision.tmx")
+	walls := m.LayerWithName("walls")
+
+	n, err := walls.Neighbors(0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := [8]GlobalID{0, 1, 1, 0, 0, 0, 1, 0}; n != e {
+		t.Errorf("expected %v, got %v", e, n)
+	}
+
+	tds, err := walls.NeighborDefs(2, 1, m.TileSets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, e := range []TileID{0, 0, 0, 255, 0, 255, 255, 3} {
+		td := tds[i]
+		if e == 255 {
+			if td != nil {
+				t.Errorf("idx(%v): expected nil, got %+v", i, td)
+			}
+			continue
+		}
+		if td == nil || td.ID != e || td.TileSet.Name != "blocks" {
+			t.Errorf("idx(%v): expected tile %v of `blocks`, got %+v", i, e, td)
+		}
+	}
+	if collides, _ := tds[0].Tile.Properties.Bool("collides"); !collides {
+		t.Error("expected neighbor properties to be resolved")
+	}
+
+	if _, err := walls.Neighbors(4, 0); err != ErrOutOfBounds {
+		t.Errorf("expected ErrOutOfBounds, got %v", err)
+	}
+}
+
+func TestReplaceTile(t *testing.T) {
+	m := decodeFixture(t, "test.tmx")
+	walls := m.LayerWithName("walls")
+
+	// populate the cache, to ensure it is invalidated
+	if _, err := walls.TileDefs(m.TileSets); err != nil {
+		t.Fatal(err)
+	}
+
+	// tile 127 of `temp` appears unflipped and flipped in the first row
+	n, err := walls.ReplaceTile(128, 11|TileFlippedVertically)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n < 5 {
+		t.Errorf("expected at least 5 replacements, got %v", n)
+	}
+
+	tds, err := walls.TileDefs(m.TileSets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 3; i < 8; i++ {
+		if id := tds[i].ID; id != 10 {
+			t.Errorf("idx(%v): expected tile id 10, got %v", i, id)
+		}
+	}
+	if !tds[4].HorizontallyFlipped || !tds[4].DiagonallyFlipped || tds[4].VerticallyFlipped {
+		t.Error("idx(4): expected original flip flags to be preserved")
+	}
+
+	n, err = walls.ReplaceGlobalID(11, 12)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n < 2 {
+		t.Errorf("expected at least 2 replacements, got %v", n)
+	}
+	if gid, _ := walls.GlobalIDAt(3, 0); gid != 12 {
+		t.Errorf("expected gid 12, got %v", gid)
+	}
+	if gid, _ := walls.GlobalIDAt(4, 0); gid.BareID() != 11 {
+		t.Errorf("expected flipped tile to be left alone, got %v", gid)
+	}
+
+	// the tiles of XML data are those of the RawData, edited in place
+	xl := decodeFixture(t, "encodings.tmx").LayerWithName("xml")
+	raw := string(xl.RawData.RawBytes)
+	if n, err := xl.ReplaceGlobalID(1, 7); err != nil || n == 0 {
+		t.Fatalf("expected tiles of XML data to be replaced, got %v (%v)", n, err)
+	}
+	if gid := xl.RawData.TileGlobalRefs[0].GlobalID; gid != 7 {
+		t.Errorf("expected RawData.TileGlobalRefs to be changed, got %v", gid)
+	}
+	if string(xl.RawData.RawBytes) != raw {
+		t.Error("expected RawBytes to be left as it was")
+	}
+}
+
+func TestTileCollisionShapes(t *testing.T) {
+	file, err := os.Open(path.Join("fixtures", "shapes.tsx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	ts, err := DecodeTileset(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	shapes := ts.TileWithID(0).CollisionShapes()
+	for i, c := range []struct {
+		name string
+		kind ObjectKind
+		x, y float64
+	}{
+		{"roof", ObjectPolygon, 2, 4},
+		{"boulder", ObjectEllipse, 1, 6},
+		{"anchor", ObjectPoint, 8, 8},
+		{"ledge", ObjectPolyline, 0, 12},
+		{"floor", ObjectRectangle, 0, 14},
+	} {
+		o := &shapes[i]
+		if o.Name != c.name || o.Kind() != c.kind || o.X != c.x || o.Y != c.y {
+			t.Errorf("expected %v %v at (%v,%v), got %v %v at (%v,%v)", c.kind, c.name, c.x, c.y, o.Kind(), o.Name, o.X, o.Y)
+		}
+	}
+
+	pts, err := shapes[0].Polygons[0].Points()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := []Point{{0, 0}, {12, 0}, {6, -4}}; !reflect.DeepEqual(pts, e) {
+		t.Errorf("expected points %v, got %v", e, pts)
+	}
+
+	bb, err := shapes[0].BoundingBox()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := image.Rect(2, 0, 14, 4); bb != e {
+		t.Errorf("expected bounding box %v, got %v", e, bb)
+	}
+
+	if shapes := ts.TileWithID(1).CollisionShapes(); shapes != nil {
+		t.Errorf("expected no collision shapes, got %v", shapes)
+	}
+	if !ts.TileWithID(0).HasCollision() || ts.TileWithID(1).HasCollision() || ts.TileWithID(2).HasCollision() {
+		t.Error("expected only tile 0 to have collision")
+	}
+	if shapes := ts.TileWithID(2).CollisionShapes(); shapes != nil {
+		t.Errorf("expected no collision shapes for a tile without an entry, got %v", shapes)
+	}
+}
+
+func TestTileSetForGID(t *testing.T) {
+	m := decodeFixture(t, "external.tmx")
+
+	if ts, err := m.TileSetForGID(3); err != nil || ts.Name != "blocks" {
+		t.Errorf("expected tileset blocks, got %+v (%v)", ts, err)
+	}
+	// the external tileset is unresolved, so may hold any number of tiles
+	if ts, err := m.TileSetForGID(40 | TileFlippedHorizontally); err != nil || ts.Source != "animated.tsx" {
+		t.Errorf("expected tileset animated.tsx, got %+v (%v)", ts, err)
+	}
+	if _, err := m.TileSetForGID(0); !errors.Is(err, ErrNoSuitableTileSet) {
+		t.Errorf("expected %v for an empty tile, got %v", ErrNoSuitableTileSet, err)
+	}
+
+	if err := m.ResolveTileSets(os.DirFS("fixtures")); err != nil {
+		t.Fatal(err)
+	}
+	if ts, err := m.TileSetForGID(12); err != nil || ts.Name != "animated" {
+		t.Errorf("expected tileset animated, got %+v (%v)", ts, err)
+	}
+	if _, err := m.TileSetForGID(40); !errors.Is(err, ErrTileIDOutOfRange) {
+		t.Errorf("expected %v, got %v", ErrTileIDOutOfRange, err)
+	}
+
+	m.TileSets = []TileSet{{FirstGlobalID: 5, TileCount: 4}}
+	if _, err := m.TileSetForGID(3); !errors.Is(err, ErrNoSuitableTileSet) {
+		t.Errorf("expected %v before every tileset, got %v", ErrNoSuitableTileSet, err)
+	}
+}
+
+func TestSortedTileSets(t *testing.T) {
+	m := &Map{
+		TileSets: []TileSet{
+			{FirstGlobalID: 5, Name: "b", TileCount: 4},
+			{FirstGlobalID: 1, Name: "a", TileCount: 4},
+		},
+		Layers: []Layer{{Width: 2, Height: 1, RawData: Data{TileGlobalRefs: []TileGlobalRef{{GlobalID: 2}, {GlobalID: 6}}}}},
+	}
+
+	sorted := m.SortedTileSets()
+	if sorted[0].Name != "a" || sorted[1].Name != "b" {
+		t.Errorf("expected tilesets a, b, got %v, %v", sorted[0].Name, sorted[1].Name)
+	}
+	if order := m.tileSetOrder(); &m.tileSetOrder()[0] != &order[0] || order[0] != &m.TileSets[1] {
+		t.Error("expected the order of the tilesets to be kept, pointing into the map")
+	}
+
+	tds, err := m.Layers[0].TileDefs(m.TileSets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tds[0].TileSet != &m.TileSets[1] || tds[1].TileSet != &m.TileSets[0] {
+		t.Errorf("expected tiles to point into the tilesets of the map, got %p, %p", tds[0].TileSet, tds[1].TileSet)
+	}
+	if m.TileSets[0].Name != "b" {
+		t.Error("expected tilesets of map not to be sorted")
+	}
+
+	// matched with another slice, the TileDefs are not those cached
+	tds, err = m.Layers[0].TileDefs(sorted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tds[0].TileSet != &sorted[0] {
+		t.Error("expected tiles to point into the tilesets given")
+	}
+	if td, err := m.TileAt(m.Layers[0].Name, 0, 0); err != nil || td.TileSet != &m.TileSets[1] {
+		t.Errorf("expected tile of map to point into its tilesets, got %+v (%v)", td, err)
+	}
+
+	m.TileSets[0].FirstGlobalID = 9
+	if sorted := m.SortedTileSets(); sorted[1].FirstGlobalID != 9 {
+		t.Errorf("expected sorted tilesets to be rebuilt, got %+v", sorted)
+	}
+
+	m.TileSets[0], m.TileSets[1] = m.TileSets[1], m.TileSets[0]
+	if sorted := m.SortedTileSets(); &sorted[0] != &m.TileSets[0] {
+		t.Error("expected tilesets in order to be returned as they are")
+	}
+}
+
+func TestCollisionTileDef(t *testing.T) {
+	file, err := os.Open(path.Join("fixtures", "nested.tsx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	ts, err := DecodeTileset(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// embed the tileset in a map at a non-default first GID
+	ts.FirstGlobalID = 10
+	td, err := TileDefForGID([]TileSet{*ts}, 13)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	shapes := td.CollisionShapes()
+	if l := len(shapes); l != 2 {
+		t.Fatalf("expected 2 collision shapes, got %v", l)
+	}
+
+	if _, err := td.CollisionTileDef(&shapes[0]); err == nil {
+		t.Error("expected an error resolving a non-tile object")
+	}
+
+	nested, err := td.CollisionTileDef(&shapes[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nested.ID != 1 || nested.GlobalID.BareID() != 11 || !nested.HorizontallyFlipped {
+		t.Errorf("expected flipped tile 1 (gid 11), got %+v", nested)
+	}
+	if spike, _ := nested.Tile.Properties.Bool("spike"); !spike {
+		t.Error("expected nested tile to resolve to the tile with property `spike`")
+	}
+
+	if shapes := (&TileDef{Nil: true}).CollisionShapes(); shapes != nil {
+		t.Errorf("expected no collision shapes for an empty tile, got %v", shapes)
+	}
+}
+
+func TestMapClass(t *testing.T) {
+	m := decodeFixture(t, "class.tmx")
+
+	if m.Class != "dungeon" {
+		t.Errorf("expected map class `dungeon`, got `%v`", m.Class)
+	}
+	if c := m.TileSets[0].Class; c != "stone" {
+		t.Errorf("expected tileset class `stone`, got `%v`", c)
+	}
+
+	td, err := m.TileAt("ground", 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := td.Class(); c != "wall" {
+		t.Errorf("expected tile class `wall`, got `%v`", c)
+	}
+
+	if m := decodeFixture(t, "test.tmx"); m.Class != "" || m.TileSets[0].Class != "" {
+		t.Errorf("expected no classes before Tiled 1.9, got `%v` and `%v`", m.Class, m.TileSets[0].Class)
+	}
+}
+
+func TestTileDefClass(t *testing.T) {
+	for _, c := range []struct {
+		td  *TileDef
+		exp string
+	}{
+		{nil, ""},
+		{&TileDef{Nil: true}, ""},
+		{&TileDef{Tile: &Tile{Type: "grass"}}, "grass"},
+		{&TileDef{Tile: &Tile{Class: "water"}}, "water"},
+		{&TileDef{Tile: &Tile{Type: "grass", Class: "water"}}, "water"},
+	} {
+		if v := c.td.Class(); v != c.exp {
+			t.Errorf("%+v: expected class `%v`, got `%v`", c.td, c.exp, v)
+		}
+	}
+}
+
+func TestTileDefOrientation(t *testing.T) {
+	for _, c := range []struct {
+		h, v, d bool
+		flipH   bool
+		flipV   bool
+		degrees int
+	}{
+		{false, false, false, false, false, 0},
+		{true, false, false, true, false, 0},
+		{false, true, false, false, true, 0},
+		{true, true, false, false, false, 180},
+		{false, false, true, true, false, 270},
+		{true, false, true, false, false, 90},
+		{false, true, true, false, false, 270},
+		{true, true, true, true, false, 90},
+	} {
+		td := &TileDef{HorizontallyFlipped: c.h, VerticallyFlipped: c.v, DiagonallyFlipped: c.d}
+		flipH, flipV, degrees := td.Orientation()
+		if flipH != c.flipH || flipV != c.flipV || degrees != c.degrees {
+			t.Errorf("h=%v v=%v d=%v: expected %v %v %v, got %v %v %v",
+				c.h, c.v, c.d, c.flipH, c.flipV, c.degrees, flipH, flipV, degrees)
+		}
+
+		// the corner of the tile, as Tiled places it, should land in the same
+		// place once mirrored and rotated; y points down, so a clockwise
+		// rotation takes (x, y) to (-y, x)
+		x, y := 1, 2
+		tx, ty := x, y
+		if c.d {
+			tx, ty = ty, tx
+		}
+		if c.h {
+			tx = -tx
+		}
+		if c.v {
+			ty = -ty
+		}
+
+		rx, ry := x, y
+		if flipH {
+			rx = -rx
+		}
+		if flipV {
+			ry = -ry
+		}
+		for i := 0; i < degrees/90; i++ {
+			rx, ry = -ry, rx
+		}
+
+		if rx != tx || ry != ty {
+			t.Errorf("h=%v v=%v d=%v: expected corner at %v,%v, got %v,%v", c.h, c.v, c.d, tx, ty, rx, ry)
+		}
+	}
+}
+
+func TestObjectsLookup(t *testing.T) {
+	objects := decodeFixture(t, "test.tmx").ObjectGroupWithName("obstacles").Objects
+
+	o := objects.WithID(76)
+	if o == nil || o.Type != "wall" || o.X != 48 {
+		t.Fatalf("unexpected object %+v", o)
+	}
+	if o != &objects[2] {
+		t.Error("expected object in the list, not a copy")
+	}
+	if o := objects.WithID(1); o != nil {
+		t.Errorf("expected no object with id 1, got %+v", o)
+	}
+
+	walls := objects.WithType("wall")
+	var ids []ObjectID
+	for _, w := range walls {
+		ids = append(ids, w.ObjectID)
+	}
+	if !reflect.DeepEqual(ids, []ObjectID{76, 81, 84, 86}) {
+		t.Errorf("unexpected walls %v", ids)
+	}
+	if l := len(objects.WithType("lava")); l != 0 {
+		t.Errorf("expected no lava, got %v", l)
+	}
+}
+
+func TestObjectsWithNameMutable(t *testing.T) {
+	objects := decodeFixture(t, "test.tmx").ObjectGroupWithName("enemies").Objects
+
+	o := objects.WithName("enemy1")
+	if o == nil {
+		t.Fatal("expected enemy1")
+	}
+	o.X = 1
+
+	if x := objects.WithName("enemy1").X; x != 1 {
+		t.Errorf("expected change to be visible, got x %v", x)
+	}
+	if o != &objects[0] {
+		t.Error("expected object in the list, not a copy")
+	}
+}
+
+func TestAllocateObjectID(t *testing.T) {
+	m := decodeFixture(t, "test.tmx")
+
+	for _, e := range []ObjectID{93, 94} {
+		if id := m.AllocateObjectID(); id != e {
+			t.Errorf("expected object ID %v, got %v", e, id)
+		}
+	}
+	if m.NextObjectID != 95 {
+		t.Errorf("expected next object ID 95, got %v", m.NextObjectID)
+	}
+
+	// as in maps from before the next object ID was written
+	m.NextObjectID = 0
+	if id := m.AllocateObjectID(); id != 93 {
+		t.Errorf("expected object ID one past the largest, 93, got %v", id)
+	}
+	if id := new(Map).AllocateObjectID(); id != 1 {
+		t.Errorf("expected object ID 1 for empty map, got %v", id)
+	}
+}
+
+func TestObjectKind(t *testing.T) {
+	shapes := decodeFixture(t, "objects.tmx").ObjectGroupWithName("shapes")
+
+	for _, c := range []struct {
+		name  string
+		kind  ObjectKind
+		point bool
+	}{
+		{"rectangle", ObjectRectangle, false},
+		{"ellipse", ObjectEllipse, false},
+		{"point", ObjectPoint, true},
+		{"polygon", ObjectPolygon, false},
+		{"polyline", ObjectPolyline, false},
+		{"text", ObjectText, false},
+		{"tile", ObjectTile, false},
+	} {
+		o := shapes.Objects.WithName(c.name)
+		if o == nil {
+			t.Fatalf("expected object %v, found none", c.name)
+		}
+
+		if k := o.Kind(); k != c.kind {
+			t.Errorf("%v: expected kind %v, got %v", c.name, c.kind, k)
+		}
+		if p := o.Point(); p != c.point {
+			t.Errorf("%v: expected point %v, got %v", c.name, c.point, p)
+		}
+	}
+}
+
+func TestPolyPointsF(t *testing.T) {
+	ramp := decodeFixture(t, "objects.tmx").ObjectGroupWithName("fractional").Objects.WithName("ramp")
+
+	ptfs, err := ramp.Polygons[0].PointsF()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := []PointF{{0, 0}, {10.5, -3.25}, {4.5, 6.75}}; !reflect.DeepEqual(ptfs, e) {
+		t.Errorf("expected points %v, got %v", e, ptfs)
+	}
+
+	pts, err := ramp.Polygons[0].Points()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := []Point{{0, 0}, {11, -3}, {5, 7}}; !reflect.DeepEqual(pts, e) {
+		t.Errorf("expected rounded points %v, got %v", e, pts)
+	}
+
+	if _, err := (&Poly{RawPoints: "0,0 1"}).PointsF(); err == nil {
+		t.Error("expected error for point with one coordinate")
+	}
+}
+
+func TestObjectTileDef(t *testing.T) {
+	m := decodeFixture(t, "objects.tmx")
+	shapes := m.ObjectGroupWithName("shapes")
+
+	tile := shapes.Objects.WithName("tile")
+	td, err := tile.TileDef(m.TileSets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if td.ID != 1 || td.TileSet.Name != "temp" || td.HorizontallyFlipped || td.VerticallyFlipped {
+		t.Errorf("unexpected tile def %+v", td)
+	}
+
+	tile.GlobalID |= TileFlippedHorizontally | TileFlippedVertically
+	td, err = tile.TileDef(m.TileSets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if td.ID != 1 || !td.HorizontallyFlipped || !td.VerticallyFlipped || td.DiagonallyFlipped {
+		t.Errorf("expected flipped tile def, got %+v", td)
+	}
+
+	if td, err := shapes.Objects.WithName("rectangle").TileDef(m.TileSets); td != nil || err != ErrNotTileObject {
+		t.Errorf("expected %v, got %+v (%v)", ErrNotTileObject, td, err)
+	}
+}
+
+func TestObjectText(t *testing.T) {
+	shapes := decodeFixture(t, "objects.tmx").ObjectGroupWithName("shapes")
+
+	if o := shapes.Objects.WithName("rectangle"); o.Text != nil {
+		t.Errorf("expected no text, got %+v", o.Text)
+	}
+
+	text := shapes.Objects.WithName("text").Text
+	if text == nil {
+		t.Fatal("expected text, found none")
+	}
+	e := Text{
+		Text:       "Hello World",
+		FontFamily: "sans-serif",
+		PixelSize:  16,
+		Wrap:       true,
+		Color:      "#000000",
+		Kerning:    true,
+		HAlign:     "left",
+		VAlign:     "top",
+	}
+	if *text != e {
+		t.Errorf("expected text %+v, got %+v", e, *text)
+	}
+	if c, ok := text.ColorRGBA(); !ok || c != (color.RGBA{0, 0, 0, 0xff}) {
+		t.Errorf("expected black, got %v (%v)", c, ok)
+	}
+
+	sign := shapes.Objects.WithName("sign").Text
+	e = Text{
+		Text:       "Fish & Chips",
+		FontFamily: "Serif",
+		PixelSize:  12,
+		Color:      "#80ff0000",
+		Bold:       true,
+		Italic:     true,
+		Underline:  true,
+		Strikeout:  true,
+		HAlign:     "center",
+		VAlign:     "bottom",
+	}
+	if *sign != e {
+		t.Errorf("expected text %+v, got %+v", e, *sign)
+	}
+}
+
+func TestEffectiveObjectProperties(t *testing.T) {
+	m := decodeFixture(t, "inherit.tmx")
+	reds := m.ObjectGroupWithName("reds")
+
+	for _, c := range []struct {
+		object string
+		team   string
+		health int64
+	}{
+		{"grunt", "red", 50},
+		{"boss", "red", 500},
+	} {
+		var o *Object
+		for i := range reds.Objects {
+			if reds.Objects[i].Name == c.object {
+				o = &reds.Objects[i]
+			}
+		}
+
+		pl := m.EffectiveObjectProperties(o)
+
+		if team := pl.WithName("team"); team == nil || team.Value != c.team {
+			t.Errorf("%v: expected team `%v`, got %v", c.object, c.team, team)
+		}
+		if health, err := pl.Int("health"); err != nil || health != c.health {
+			t.Errorf("%v: expected health %v, got %v (%v)", c.object, c.health, health, err)
+		}
+		if gravity, err := pl.Float("gravity"); err != nil || gravity != 9.8 {
+			t.Errorf("%v: expected gravity from the map, got %v (%v)", c.object, gravity, err)
+		}
+		if l := len(pl); l != 3 {
+			t.Errorf("%v: expected 3 properties, got %v", c.object, l)
+		}
+	}
+
+	if l := len(reds.Objects[1].Properties); l != 1 {
+		t.Errorf("expected object properties to be left untouched, got %v", l)
+	}
+}
+
+func TestTileProbability(t *testing.T) {
+	var ts TileSet
+	err := xml.Unmarshal([]byte(`<tileset name="p" tilecount="5">
+		<tile id="0"/>
+		<tile id="1" probability="0.25"/>
+		<tile id="2" probability="0"/>
+		<tile id="4" probability="-1"/>
+	</tileset>`), &ts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Tiled never picks a tile with an explicit probability of 0, so it is
+	// kept rather than taken as the default
+	for id, e := range map[TileID]float32{0: 1, 1: 0.25, 2: 0, 4: 0} {
+		if p := ts.TileWithID(id).EffectiveProbability(); p != e {
+			t.Errorf("id(%v): expected probability %v, got %v", id, e, p)
+		}
+	}
+
+	if p := ts.TileWithID(3).EffectiveProbability(); p != 1 {
+		t.Errorf("expected implicit tile probability 1, got %v", p)
+	}
+	if p := (&Tile{TileID: 3}).EffectiveProbability(); p != 0 {
+		t.Errorf("expected probability 0 for a tile built without one, got %v", p)
+	}
+}
+
+func TestMixedEncodings(t *testing.T) {
+	m := decodeFixture(t, "encodings.tmx")
+
+	exp := []GlobalID{
+		1, 2, 3, 0,
+		4, 1 | TileFlippedHorizontally, 2 | TileFlippedVertically, 3 | TileFlippedDiagonally,
+		0, 0, 5, 1,
+	}
+
+	for _, name := range []string{"csv", "base64", "zlib", "gzip", "zstd", "xml"} {
+		l := m.LayerWithName(name)
+		if l == nil {
+			t.Errorf("expected layer with name `%v`, but found none.", name)
+			continue
+		}
+
+		trs, err := l.TileGlobalRefs()
+		if err != nil {
+			t.Errorf("%v: unexpected error %v", name, err)
+			continue
+		}
+
+		if len(trs) != len(exp) {
+			t.Errorf("%v: expected %v tiles, got %v", name, len(exp), len(trs))
+			continue
+		}
+		for i, e := range exp {
+			if g := trs[i].GlobalID; g != e {
+				t.Errorf("%v idx(%v): expected %v, got %v", name, i, e, g)
+			}
+		}
+
+		if _, err := l.TileDefs(m.TileSets); err != nil {
+			t.Errorf("%v: unexpected error %v", name, err)
+		}
+	}
+}
+
+func TestTerrainTiles(t *testing.T) {
+	var ts TileSet
+	err := xml.Unmarshal([]byte(`<tileset name="terrain" tilecount="4">
+		<terraintypes>
+			<terrain name="grass" tile="0"/>
+			<terrain name="water" tile="3"/>
+		</terraintypes>
+		<tile id="0" terrain="0,0,0,0"/>
+		<tile id="1" terrain="0,0,1,1"/>
+		<tile id="2" terrain=",,0,"/>
+		<tile id="3" terrain="1,1,1,1"/>
+	</tileset>`), &ts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, exp := range map[string][]TileID{
+		"grass": {0, 1, 2},
+		"water": {1, 3},
+		"lava":  nil,
+	} {
+		tiles := ts.TerrainTiles(name)
+		if len(tiles) != len(exp) {
+			t.Errorf("%v: expected %v tiles, got %v", name, len(exp), len(tiles))
+			continue
+		}
+		for i, id := range exp {
+			if tiles[i].TileID != id {
+				t.Errorf("%v idx(%v): expected tile %v, got %v", name, i, id, tiles[i].TileID)
+			}
+		}
+	}
+}
+
+func TestZstdCompression(t *testing.T) {
+	m := decodeFixture(t, "zstd.tmx")
+
+	want, err := m.LayerWithName("zlib").DecodeInto(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := m.LayerWithName("zstd").DecodeInto(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(got) != 48*30 || !reflect.DeepEqual(got, want) {
+		t.Errorf("expected zstd layer to match zlib layer, got %v", got)
+	}
+}
+
+func TestTileDefAt(t *testing.T) {
+	m := decodeFixture(t, "collision.tmx")
+	l := m.LayerWithName("walls")
+
+	td, err := l.TileDefAt(m.TileSets, 2, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if td.ID != 2 {
+		t.Errorf("expected tile 2 at 2,1, got %v", td.ID)
+	}
+
+	for _, c := range [][2]int{{-1, 0}, {4, 0}, {0, 3}} {
+		if _, err := l.TileDefAt(m.TileSets, c[0], c[1]); err != ErrOutOfBounds {
+			t.Errorf("%v: expected ErrOutOfBounds, got %v", c, err)
+		}
+	}
+}
+
+func TestLayerEachTile(t *testing.T) {
+	b, err := os.ReadFile(path.Join("fixtures", "collision.tmx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		order string
+		first [2]int
+		last  [2]int
+	}{
+		{"right-down", [2]int{0, 0}, [2]int{3, 2}},
+		{"right-up", [2]int{0, 2}, [2]int{3, 0}},
+		{"left-down", [2]int{3, 0}, [2]int{0, 2}},
+		{"left-up", [2]int{3, 2}, [2]int{0, 0}},
+	}
+
+	for _, test := range tests {
+		src := strings.Replace(string(b), `renderorder="right-down"`, `renderorder="`+test.order+`"`, 1)
+		m, err := Decode(strings.NewReader(src))
+		if err != nil {
+			t.Fatal(err)
+		}
+		l := m.LayerWithName("walls")
+
+		var coords [][2]int
+		err = l.EachTile(m.TileSets, func(x, y int, td *TileDef) error {
+			if exp, _ := l.TileDefAt(m.TileSets, x, y); exp != td {
+				t.Errorf("%v: unexpected TileDef at %v,%v", test.order, x, y)
+			}
+			coords = append(coords, [2]int{x, y})
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(coords) != 12 || coords[0] != test.first || coords[11] != test.last {
+			t.Errorf("%v: expected to run from %v to %v, got %v", test.order, test.first, test.last, coords)
+		}
+	}
+
+	m := decodeFixture(t, "collision.tmx")
+	stop := errors.New("stop")
+	n := 0
+	err = m.Layers[0].EachTile(m.TileSets, func(x, y int, td *TileDef) error {
+		n++
+		return stop
+	})
+	if err != stop || n != 1 {
+		t.Errorf("expected iteration to stop with the error, got %v after %v", err, n)
+	}
+}
+
+func TestTileDefsConcurrent(t *testing.T) {
+	m := decodeFixture(t, "test.tmx")
+
+	var wg sync.WaitGroup
+	results := make([][]*TileDef, 8)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := range m.Layers {
+				tds, err := m.Layers[j].TileDefs(m.TileSets)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if j == 0 {
+					results[i] = tds
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	for i := range results {
+		if len(results[i]) != 48*30 {
+			t.Errorf("goroutine %v: expected %v tiles, got %v", i, 48*30, len(results[i]))
+		}
+	}
+}
+
+// cancelingReader cancels its context after the first read
+type cancelingReader struct {
+	r      io.Reader
+	cancel context.CancelFunc
+}
+
+func (r *cancelingReader) Read(p []byte) (int, error) {
+	defer r.cancel()
+	return r.r.Read(p)
+}
+
+func TestDecodeContext(t *testing.T) {
+	var b strings.Builder
+	b.WriteString(`<map width="16" height="16" infinite="1"><layer name="ground"><data encoding="csv">`)
+	for i := 0; i < 256; i++ {
+		fmt.Fprintf(&b, `<chunk x="%v" y="0" width="16" height="16">%v</chunk>`, i*16, strings.Repeat("1,", 255)+"1")
+	}
+	b.WriteString(`</data></layer></map>`)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	_, err := DecodeContext(ctx, &cancelingReader{r: strings.NewReader(b.String()), cancel: cancel})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected %v, got %v", context.Canceled, err)
+	}
+
+	m, err := DecodeContext(context.Background(), strings.NewReader(b.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l := len(m.Layers[0].RawData.Chunks); l != 256 {
+		t.Errorf("expected 256 chunks, got %v", l)
+	}
+}
+
+func TestDecodeError(t *testing.T) {
+	for _, c := range []struct {
+		name    string
+		in      string
+		element string
+		offset  int64
+	}{
+		{"map attribute", `<map width="wide"></map>`, "map", 18},
+		{"layer attribute", `<map><tileset firstgid="1"/><layer width="wide"></layer></map>`, "layer", 48},
+		{"unclosed data", `<map><group><layer><data encoding="csv">1,2</layer></group></map>`, "layer", 51},
+		{"object", `<map><objectgroup><object x="left"/></objectgroup></map>`, "object", 36},
+		{"tileset", `<map><tileset firstgid="one"/></map>`, "map", 30},
+		{"empty", ``, "map", 0},
+	} {
+		_, err := Decode(strings.NewReader(c.in))
+
+		var de *DecodeError
+		if !errors.As(err, &de) {
+			t.Errorf("%v: expected DecodeError, got %v", c.name, err)
+			continue
+		}
+
+		if de.Element != c.element || de.Offset != c.offset {
+			t.Errorf("%v: expected error near %v in <%v>, got %v", c.name, c.offset, c.element, err)
+		}
+		if errors.Unwrap(err) == nil {
+			t.Errorf("%v: expected the decode error to be wrapped", c.name)
+		}
+	}
+}
+
+func TestDecodeBytes(t *testing.T) {
+	b, err := os.ReadFile(path.Join("fixtures", "test.tmx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := DecodeBytes(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decodedState(t, m), decodedState(t, decodeFixture(t, "test.tmx"))) {
+		t.Error("expected map decoded from bytes to match map decoded from file")
+	}
+
+	if b, err = os.ReadFile(path.Join("fixtures", "animated.tsx")); err != nil {
+		t.Fatal(err)
+	}
+	ts, err := DecodeTilesetBytes(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ts.Name != "animated" {
+		t.Errorf("unexpected tileset %+v", ts)
+	}
+
+	if _, err := DecodeBytes([]byte("<map")); err == nil {
+		t.Error("expected error for malformed map")
+	}
+}
+
+func TestImageDecodedData(t *testing.T) {
+	ts, err := DecodeTileset(strings.NewReader(`<tileset name="embedded" tilewidth="4" tileheight="4">
+		<image format="png" width="4" height="4">
+			<data encoding="base64">iVBORw0KGgpub3QgcmVhbGx5</data>
+		</image>
+		<tile id="0">
+			<image format="png" width="4" height="4">
+				<data encoding="base64" compression="gzip">
+					H4sIAAAAAAAC/+sM8HPn5ZLiyssvUShKTczJqQQAXDXqMxIAAAA=
+				</data>
+			</image>
+		</tile>
+		<tile id="1"><image source="tile.png" width="4" height="4"/></tile>
+	</tileset>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := []byte("\x89PNG\r\n\x1a\nnot really")
+	for _, img := range []Image{ts.Image, ts.Tiles[0].Image} {
+		b, err := img.DecodedData()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(b, exp) {
+			t.Errorf("expected %q, got %q", exp, b)
+		}
+	}
+
+	if _, err := ts.Tiles[1].Image.DecodedData(); err != ErrNoEmbeddedData {
+		t.Errorf("expected %v, got %v", ErrNoEmbeddedData, err)
+	}
+}
@@ -0,0 +1,82 @@
+package tmx
+
+import "fmt"
+
+// TileRectRun is a rectangle of identical tiles within a Layer, in tile
+// coordinates.
+type TileRectRun struct {
+	GID  GlobalID
+	X, Y int
+	W, H int
+}
+
+// GreedyRects coalesces the tiles of the layer into rectangles of identical
+// GlobalIDs (including flip flags), skipping empty tiles. Rectangles are grown
+// greedily, first to the right and then downward, starting from the top-left
+// most tile not yet covered; every non-empty tile is covered by exactly one
+// rectangle. This is typically used to reduce the number of bodies needed to
+// build collision geometry from a tile layer.
+func (l *Layer) GreedyRects() ([]TileRectRun, error) {
+	trs, err := l.TileGlobalRefs()
+	if err != nil {
+		return nil, err
+	}
+
+	w, h := l.Width, l.Height
+	if len(trs) != w*h {
+		return nil, fmt.Errorf(
+			"expected %v tiles in layer %v, got %v",
+			w*h, l.Name, len(trs),
+		)
+	}
+
+	covered := make([]bool, len(trs))
+	matches := func(i int, gid GlobalID) bool {
+		return !covered[i] && trs[i].GlobalID == gid
+	}
+
+	var runs []TileRectRun
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			i := x + y*w
+			gid := trs[i].GlobalID
+
+			if covered[i] || gid.BareID() == 0 {
+				continue
+			}
+
+			rw := 1
+			for x+rw < w && matches(i+rw, gid) {
+				rw++
+			}
+
+			rh := 1
+		grow:
+			for y+rh < h {
+				row := x + (y+rh)*w
+				for k := 0; k < rw; k++ {
+					if !matches(row+k, gid) {
+						break grow
+					}
+				}
+				rh++
+			}
+
+			for ry := y; ry < y+rh; ry++ {
+				for rx := x; rx < x+rw; rx++ {
+					covered[rx+ry*w] = true
+				}
+			}
+
+			runs = append(runs, TileRectRun{
+				GID: gid,
+				X:   x,
+				Y:   y,
+				W:   rw,
+				H:   rh,
+			})
+		}
+	}
+
+	return runs, nil
+}
@@ -0,0 +1,124 @@
+package tmx
+
+import (
+	"reflect"
+	"testing"
+)
+
+func layerFromGIDs(w, h int, gids ...GlobalID) *Layer {
+	l := &Layer{Width: w, Height: h}
+	for _, gid := range gids {
+		l.RawData.TileGlobalRefs = append(l.RawData.TileGlobalRefs, TileGlobalRef{gid})
+	}
+
+	return l
+}
+
+func TestGreedyRects(t *testing.T) {
+	const flipped = 1 | TileFlippedHorizontally
+
+	for _, c := range []struct {
+		name string
+		l    *Layer
+		exp  []TileRectRun
+	}{
+		{
+			"empty",
+			layerFromGIDs(2, 2, 0, 0, 0, 0),
+			nil,
+		},
+		{
+			"single",
+			layerFromGIDs(3, 2, 1, 1, 1, 1, 1, 1),
+			[]TileRectRun{{1, 0, 0, 3, 2}},
+		},
+		{
+			"holes",
+			layerFromGIDs(3, 3,
+				1, 1, 0,
+				1, 1, 2,
+				0, 2, 2,
+			),
+			[]TileRectRun{
+				{1, 0, 0, 2, 2},
+				{2, 2, 1, 1, 2},
+				{2, 1, 2, 1, 1},
+			},
+		},
+		{
+			"ragged",
+			layerFromGIDs(4, 3,
+				1, 1, 1, 1,
+				1, 1, 0, 0,
+				1, 1, 1, 0,
+			),
+			[]TileRectRun{
+				{1, 0, 0, 4, 1},
+				{1, 0, 1, 2, 2},
+				{1, 2, 2, 1, 1},
+			},
+		},
+		{
+			"flips are distinct",
+			layerFromGIDs(3, 1, 1, flipped, flipped),
+			[]TileRectRun{
+				{1, 0, 0, 1, 1},
+				{flipped, 1, 0, 2, 1},
+			},
+		},
+	} {
+		runs, err := c.l.GreedyRects()
+		if err != nil {
+			t.Errorf("%v: unexpected error %v", c.name, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(runs, c.exp) {
+			t.Errorf("%v: expected %v, got %v", c.name, c.exp, runs)
+		}
+	}
+}
+
+func TestGreedyRectsCoverage(t *testing.T) {
+	m := decodeFixture(t, "test.tmx")
+	walls := m.LayerWithName("walls")
+
+	trs, err := walls.TileGlobalRefs()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	runs, err := walls.GreedyRects()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	seen := make([]int, len(trs))
+	for _, r := range runs {
+		for y := r.Y; y < r.Y+r.H; y++ {
+			for x := r.X; x < r.X+r.W; x++ {
+				i := x + y*walls.Width
+				seen[i]++
+
+				if g := trs[i].GlobalID; g != r.GID {
+					t.Errorf("(%v,%v): run %v covers gid %v", x, y, r, g)
+				}
+			}
+		}
+	}
+
+	for i, tr := range trs {
+		e := 1
+		if tr.GlobalID.BareID() == 0 {
+			e = 0
+		}
+
+		if seen[i] != e {
+			t.Errorf("idx(%v): expected to be covered %v times, got %v", i, e, seen[i])
+		}
+	}
+
+	if _, err := layerFromGIDs(2, 2, 1).GreedyRects(); err == nil {
+		t.Error("expected an error for a layer with missing tiles")
+	}
+}
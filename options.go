@@ -0,0 +1,126 @@
+package tmx
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"io"
+)
+
+// Options changes how a map is decoded; see DecodeWithOptions.
+type Options struct {
+	// SkipTileData leaves the tile data of each layer as it was written,
+	// without parsing the tiles or chunks within it, for tools which only
+	// read the metadata of maps. The tiles are parsed when first needed, by
+	// TileGlobalRefs, Chunks, and the methods built upon them, and kept for
+	// later calls.
+	SkipTileData bool
+
+	// Lenient decodes base64 tile data whose compression attribute is
+	// missing, though it is compressed, as from tools which strip it. The
+	// compression is then told by the gzip, zlib, or zstd header the data
+	// begins with, if any; data which fails to decompress is taken as it is,
+	// uncompressed.
+	Lenient bool
+}
+
+// DecodeWithOptions is the same as Decode, with the given Options.
+func DecodeWithOptions(r io.Reader, opts Options) (*Map, error) {
+	return decode(context.Background(), r, opts)
+}
+
+// UnmarshalXML decodes a Data, leaving any tiles and chunks within RawBytes;
+// once the layers of a map are decoded, their data is parsed in place unless
+// the map is decoded with Options.SkipTileData.
+func (d *Data) UnmarshalXML(dec *xml.Decoder, start xml.StartElement) error {
+	var raw struct {
+		Encoding    string `xml:"encoding,attr"`
+		Compression string `xml:"compression,attr"`
+		RawBytes    []byte `xml:",innerxml"`
+	}
+	if err := dec.DecodeElement(&raw, &start); err != nil {
+		return err
+	}
+
+	*d = Data{
+		Encoding:    raw.Encoding,
+		Compression: raw.Compression,
+		RawBytes:    raw.RawBytes,
+		// CSV and base64 data is read from RawBytes as it is; only data
+		// holding elements has anything to parse
+		unparsed: bytes.IndexByte(raw.RawBytes, '<') >= 0,
+	}
+
+	return nil
+}
+
+// applyOptions parses the tiles and chunks of the layer's data, unless the
+// options skip them, and notes how the data is to be read
+func (l *Layer) applyOptions(opts Options) error {
+	l.RawData.lenient = opts.Lenient
+	if opts.SkipTileData || !l.RawData.unparsed {
+		return nil
+	}
+
+	d, err := l.RawData.parsed()
+	if err != nil {
+		return &DecodeError{Offset: l.offset, Element: "layer", Err: err}
+	}
+	l.RawData = *d
+
+	return nil
+}
+
+// parsed returns the Data with any tiles and chunks left within RawBytes
+// parsed; the Data itself is not changed, so that it may be read from any
+// number of goroutines at once
+func (d *Data) parsed() (*Data, error) {
+	if !d.unparsed {
+		return d, nil
+	}
+
+	var b bytes.Buffer
+	b.WriteString("<data>")
+	b.Write(d.RawBytes)
+	b.WriteString("</data>")
+
+	// decoded as the underlying type, as UnmarshalXML would leave the
+	// tiles and chunks within RawBytes again
+	type data Data
+	p := new(Data)
+	if err := xml.Unmarshal(b.Bytes(), (*data)(p)); err != nil {
+		return nil, err
+	}
+	p.Encoding, p.Compression, p.lenient = d.Encoding, d.Compression, d.lenient
+
+	return p, nil
+}
+
+// parsedDataCache is the parsed Data of a layer decoded with
+// Options.SkipTileData, with the RawBytes it was parsed from
+type parsedDataCache struct {
+	raw  []byte
+	data *Data
+}
+
+// data returns the Data of the layer with any tiles and chunks parsed. Data
+// left unparsed by Options.SkipTileData is parsed on the first call, and
+// cached as with cachedTileGlobalRefs, until RawBytes is replaced.
+func (l *Layer) data() (*Data, error) {
+	if !l.RawData.unparsed {
+		return &l.RawData, nil
+	}
+
+	raw := l.RawData.RawBytes
+	if c, ok := l.parsedData.Load().(*parsedDataCache); ok && len(c.raw) == len(raw) && &c.raw[0] == &raw[0] {
+		return c.data, nil
+	}
+
+	d, err := l.RawData.parsed()
+	if err != nil {
+		return nil, err
+	}
+	l.parsedData.Store(&parsedDataCache{raw: raw, data: d})
+
+	return d, nil
+}
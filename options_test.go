@@ -0,0 +1,100 @@
+package tmx
+
+import (
+	"bytes"
+	"os"
+	"path"
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+func TestDecodeWithOptions(t *testing.T) {
+	for _, name := range []string{"encodings.tmx", "infinite.tmx"} {
+		file, err := os.Open(path.Join("fixtures", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		m, err := DecodeWithOptions(file, Options{SkipTileData: true})
+		file.Close()
+		if err != nil {
+			t.Fatalf("%v: %v", name, err)
+		}
+
+		exp := decodeFixture(t, name)
+		for i := range exp.Layers {
+			l, el := &m.Layers[i], &exp.Layers[i]
+
+			if len(l.RawData.TileGlobalRefs) != 0 || len(l.RawData.Chunks) != 0 {
+				t.Errorf("%v: layer %q: expected tile data to be left unparsed", name, l.Name)
+			}
+
+			if l.RawData.unparsed {
+				d, err := l.data()
+				if err != nil {
+					t.Fatalf("%v: layer %q: %v", name, l.Name, err)
+				}
+				if cd, _ := l.data(); cd != d {
+					t.Errorf("%v: layer %q: expected parsed data to be cached", name, l.Name)
+				}
+			}
+
+			chunks, err := l.Chunks()
+			if err != nil {
+				t.Fatalf("%v: layer %q: %v", name, l.Name, err)
+			}
+			if e, _ := el.Chunks(); !reflect.DeepEqual(chunks, e) {
+				t.Errorf("%v: layer %q: expected chunks %+v, got %+v", name, l.Name, e, chunks)
+			}
+			if chunks != nil {
+				continue
+			}
+
+			trs, err := l.TileGlobalRefs()
+			if err != nil {
+				t.Fatalf("%v: layer %q: %v", name, l.Name, err)
+			}
+			if e, _ := el.TileGlobalRefs(); !reflect.DeepEqual(trs, e) {
+				t.Errorf("%v: layer %q: expected tiles %v, got %v", name, l.Name, e, trs)
+			}
+
+			gids, err := l.DecodeInto(nil)
+			if err != nil || len(gids) != len(trs) {
+				t.Errorf("%v: layer %q: expected %v tiles, got %v (%v)", name, l.Name, len(trs), len(gids), err)
+			}
+		}
+	}
+}
+
+func TestDecodeLenient(t *testing.T) {
+	b, err := os.ReadFile(path.Join("fixtures", "encodings.tmx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// as from a tool which strips the compression attribute
+	stripped := regexp.MustCompile(` compression="\w+"`).ReplaceAll(b, nil)
+
+	exp, _ := decodeFixture(t, "encodings.tmx").LayerWithName("base64").TileGlobalRefs()
+
+	m, err := DecodeWithOptions(bytes.NewReader(stripped), Options{Lenient: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"base64", "zlib", "gzip", "zstd"} {
+		trs, err := m.LayerWithName(name).TileGlobalRefs()
+		if err != nil {
+			t.Errorf("%v: %v", name, err)
+		} else if !reflect.DeepEqual(trs, exp) {
+			t.Errorf("%v: expected tiles %v, got %v", name, exp, trs)
+		}
+	}
+
+	m, err = DecodeWithOptions(bytes.NewReader(stripped), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if trs, _ := m.LayerWithName("gzip").TileGlobalRefs(); reflect.DeepEqual(trs, exp) {
+		t.Error("expected compressed data to be taken as it is without Lenient")
+	}
+}
@@ -0,0 +1,406 @@
+package tmx
+
+import (
+	"fmt"
+	"math"
+)
+
+// LayerLike is implemented by each kind of layer in a Map, and exposes the
+// properties which affect where its contents are drawn.
+type LayerLike interface {
+	// LayerOffset returns the offset of the layer, in pixels
+	LayerOffset() (x, y float64)
+	// LayerParallax returns the parallax scrolling factors of the layer
+	LayerParallax() (x, y float64)
+}
+
+// LayerElement is one of the layers drawn by a Map: a *Layer, *ObjectGroup, or
+// *ImageLayer; see Map.OrderedLayers.
+type LayerElement interface {
+	LayerLike
+
+	// z returns the position of the layer in the draw order of the map
+	z() int
+}
+
+func (l *Layer) z() int        { return l.Z }
+func (og *ObjectGroup) z() int { return og.Z }
+func (il *ImageLayer) z() int  { return il.Z }
+
+// LayerOffset returns the offset of the layer, in pixels
+func (l *Layer) LayerOffset() (x, y float64) {
+	return float64(l.OffsetX), float64(l.OffsetY)
+}
+
+// LayerParallax returns the parallax scrolling factors of the layer
+func (l *Layer) LayerParallax() (x, y float64) {
+	return float64(l.ParallaxX), float64(l.ParallaxY)
+}
+
+// LayerOffset returns the offset of the group, in pixels
+func (og *ObjectGroup) LayerOffset() (x, y float64) {
+	return float64(og.OffsetX), float64(og.OffsetY)
+}
+
+// LayerParallax returns the parallax scrolling factors of the group
+func (og *ObjectGroup) LayerParallax() (x, y float64) {
+	return float64(og.ParallaxX), float64(og.ParallaxY)
+}
+
+// LayerOffset returns the offset of the image layer, in pixels
+func (il *ImageLayer) LayerOffset() (x, y float64) {
+	return il.OffsetX, il.OffsetY
+}
+
+// LayerParallax returns the parallax scrolling factors of the image layer
+func (il *ImageLayer) LayerParallax() (x, y float64) {
+	return float64(il.ParallaxX), float64(il.ParallaxY)
+}
+
+// TileToPixel returns the pixel position of the top-left of the bounding box
+// of the tile cell x, y, as Tiled lays it out for the map's Orientation. In
+// "isometric" maps, cells are diamonds, with the top corner of cell 0, 0 at
+// the middle of the top edge of the map; in "staggered" and "hexagonal" maps,
+// every other row or column, as given by StaggerAxis and StaggerIndex, is
+// shifted by half a cell. Any other orientation is treated as "orthogonal".
+func (m *Map) TileToPixel(x, y int) (px, py int) {
+	switch m.Orientation {
+	case "isometric":
+		return (x - y + m.Height - 1) * m.TileWidth / 2, (x + y) * m.TileHeight / 2
+	case "staggered", "hexagonal":
+		p := m.staggerParams()
+		if p.staggerX {
+			py = y * (p.tileHeight + p.sideLengthY)
+			if p.staggered(x) {
+				py += p.rowHeight
+			}
+			return x * p.columnWidth, py
+		}
+
+		px = x * (p.tileWidth + p.sideLengthX)
+		if p.staggered(y) {
+			px += p.columnWidth
+		}
+		return px, y * p.rowHeight
+	}
+
+	return x * m.TileWidth, y * m.TileHeight
+}
+
+// PixelToTile returns the tile cell containing the pixel position px, py; the
+// inverse of TileToPixel. Positions outside of the map give cells outside of
+// it, rather than being clamped.
+func (m *Map) PixelToTile(px, py int) (x, y int) {
+	switch m.Orientation {
+	case "isometric":
+		if m.TileWidth <= 0 || m.TileHeight <= 0 {
+			return 0, 0
+		}
+
+		// relative to the top corner of cell 0, 0
+		rx := float64(px) - float64(m.Height*m.TileWidth)/2
+		ry := float64(py)
+		fx := ry/float64(m.TileHeight) + rx/float64(m.TileWidth)
+		fy := ry/float64(m.TileHeight) - rx/float64(m.TileWidth)
+
+		return int(math.Floor(fx)), int(math.Floor(fy))
+	case "staggered":
+		return m.staggerParams().staggeredPixelToTile(float64(px), float64(py))
+	case "hexagonal":
+		return m.staggerParams().hexagonalPixelToTile(float64(px), float64(py))
+	}
+
+	if m.TileWidth <= 0 || m.TileHeight <= 0 {
+		return 0, 0
+	}
+
+	return floorDiv(px, m.TileWidth), floorDiv(py, m.TileHeight)
+}
+
+// floorDiv divides a by b, rounding toward negative infinity
+func floorDiv(a, b int) int {
+	q := a / b
+	if (a%b != 0) && ((a < 0) != (b < 0)) {
+		q--
+	}
+
+	return q
+}
+
+// Axis is the axis along which every other row or column of a staggered or
+// hexagonal map is shifted
+type Axis int
+
+// Axes of a staggered or hexagonal map
+const (
+	AxisY Axis = iota
+	AxisX
+)
+
+// StaggerIndex tells whether the even or odd rows or columns of a staggered
+// or hexagonal map are shifted
+type StaggerIndex int
+
+// Stagger indexes of a staggered or hexagonal map
+const (
+	StaggerOdd StaggerIndex = iota
+	StaggerEven
+)
+
+// StaggerAxisAxis returns the StaggerAxis of the map as an Axis; AxisY, as in
+// Tiled, if it has none. Returns an error wrapping ErrInvalidStagger for any
+// axis other than "x" or "y", along with AxisY.
+func (m *Map) StaggerAxisAxis() (Axis, error) {
+	switch m.StaggerAxis {
+	case 'x':
+		return AxisX, nil
+	case 'y', 0:
+		return AxisY, nil
+	}
+
+	return AxisY, fmt.Errorf("%w: stagger axis %q", ErrInvalidStagger, string(m.StaggerAxis))
+}
+
+// StaggerIndexValue returns the StaggerIndex of the map; StaggerOdd, as in
+// Tiled, if it has none. Returns an error wrapping ErrInvalidStagger for any
+// index other than "even" or "odd", along with StaggerOdd.
+func (m *Map) StaggerIndexValue() (StaggerIndex, error) {
+	switch m.StaggerIndex {
+	case "even":
+		return StaggerEven, nil
+	case "odd", "":
+		return StaggerOdd, nil
+	}
+
+	return StaggerOdd, fmt.Errorf("%w: stagger index %q", ErrInvalidStagger, m.StaggerIndex)
+}
+
+// staggerParams holds the measurements of the cells of a staggered or
+// hexagonal map, as computed by Tiled
+type staggerParams struct {
+	tileWidth, tileHeight    int
+	sideLengthX, sideLengthY int
+	sideOffsetX, sideOffsetY int
+	columnWidth, rowHeight   int
+	staggerX, staggerEven    bool
+}
+
+// staggerParams returns the measurements of the cells of the map; an invalid
+// stagger axis or index is taken to be Tiled's default
+func (m *Map) staggerParams() staggerParams {
+	axis, _ := m.StaggerAxisAxis()
+	index, _ := m.StaggerIndexValue()
+
+	p := staggerParams{
+		// Tiled rounds cells down to an even size
+		tileWidth:   m.TileWidth &^ 1,
+		tileHeight:  m.TileHeight &^ 1,
+		staggerX:    axis == AxisX,
+		staggerEven: index == StaggerEven,
+	}
+
+	if p.staggerX {
+		p.sideLengthX = m.HexSideLength
+	} else {
+		p.sideLengthY = m.HexSideLength
+	}
+
+	p.sideOffsetX = (p.tileWidth - p.sideLengthX) / 2
+	p.sideOffsetY = (p.tileHeight - p.sideLengthY) / 2
+	p.columnWidth = p.sideOffsetX + p.sideLengthX
+	p.rowHeight = p.sideOffsetY + p.sideLengthY
+
+	return p
+}
+
+// staggered returns true if the row or column i, along the stagger axis, is
+// shifted
+func (p staggerParams) staggered(i int) bool {
+	return (i&1 != 0) != p.staggerEven
+}
+
+// neighbor returns the cell diagonally adjacent to x, y in a staggered map, in
+// the direction dx, dy, each of which is -1 or 1
+func (p staggerParams) neighbor(x, y, dx, dy int) (int, int) {
+	if p.staggerX {
+		// moving up from a shifted column, or down from an unshifted one,
+		// stays in the same row
+		if p.staggered(x) == (dy > 0) {
+			return x + dx, y + dy
+		}
+		return x + dx, y
+	}
+
+	if p.staggered(y) == (dx > 0) {
+		return x + dx, y + dy
+	}
+	return x, y + dy
+}
+
+func (p staggerParams) staggeredPixelToTile(px, py float64) (x, y int) {
+	if p.tileWidth <= 0 || p.tileHeight <= 0 {
+		return 0, 0
+	}
+
+	if p.staggerEven {
+		if p.staggerX {
+			px -= float64(p.sideOffsetX)
+		} else {
+			py -= float64(p.sideOffsetY)
+		}
+	}
+
+	// start from the cell of the rectangle grid the position falls in, which
+	// holds the diamond of one cell, and the corners of four others
+	w, h := float64(p.tileWidth), float64(p.tileHeight)
+	x, y = int(math.Floor(px/w)), int(math.Floor(py/h))
+	relX, relY := px-float64(x)*w, py-float64(y)*h
+
+	if p.staggerX {
+		x *= 2
+		if p.staggerEven {
+			x++
+		}
+	} else {
+		y *= 2
+		if p.staggerEven {
+			y++
+		}
+	}
+
+	yPos := relX * h / w
+	so := float64(p.sideOffsetY)
+	switch {
+	case so-yPos > relY:
+		return p.neighbor(x, y, -1, -1)
+	case -so+yPos > relY:
+		return p.neighbor(x, y, 1, -1)
+	case so+yPos < relY:
+		return p.neighbor(x, y, -1, 1)
+	case so*3-yPos < relY:
+		return p.neighbor(x, y, 1, 1)
+	}
+
+	return x, y
+}
+
+func (p staggerParams) hexagonalPixelToTile(px, py float64) (x, y int) {
+	if p.columnWidth <= 0 || p.rowHeight <= 0 {
+		return 0, 0
+	}
+
+	if p.staggerX {
+		if p.staggerEven {
+			px -= float64(p.tileWidth)
+		} else {
+			px -= float64(p.sideOffsetX)
+		}
+	} else {
+		if p.staggerEven {
+			py -= float64(p.tileHeight)
+		} else {
+			py -= float64(p.sideOffsetY)
+		}
+	}
+
+	// start from a cell of the grid aligned to pairs of columns or rows, then
+	// pick the nearest of the four cells whose centers are around it
+	cw, rh := float64(p.columnWidth*2), float64(p.rowHeight*2)
+	x, y = int(math.Floor(px/cw)), int(math.Floor(py/rh))
+	relX, relY := px-float64(x)*cw, py-float64(y)*rh
+
+	var centers [4][2]float64
+	var offsets [4][2]int
+	if p.staggerX {
+		x *= 2
+		if p.staggerEven {
+			x++
+		}
+
+		left := float64(p.sideLengthX / 2)
+		centerX := left + float64(p.columnWidth)
+		centerY := float64(p.tileHeight / 2)
+		centers = [4][2]float64{
+			{left, centerY},
+			{centerX, centerY - float64(p.rowHeight)},
+			{centerX, centerY + float64(p.rowHeight)},
+			{centerX + float64(p.columnWidth), centerY},
+		}
+		offsets = [4][2]int{{0, 0}, {1, -1}, {1, 0}, {2, 0}}
+	} else {
+		y *= 2
+		if p.staggerEven {
+			y++
+		}
+
+		top := float64(p.sideLengthY / 2)
+		centerX := float64(p.tileWidth / 2)
+		centerY := top + float64(p.rowHeight)
+		centers = [4][2]float64{
+			{centerX, top},
+			{centerX - float64(p.columnWidth), centerY},
+			{centerX + float64(p.columnWidth), centerY},
+			{centerX, centerY + float64(p.rowHeight)},
+		}
+		offsets = [4][2]int{{0, 0}, {-1, 1}, {0, 1}, {0, 2}}
+	}
+
+	nearest, minDist := 0, math.Inf(1)
+	for i, c := range centers {
+		dx, dy := c[0]-relX, c[1]-relY
+		if d := dx*dx + dy*dy; d < minDist {
+			nearest, minDist = i, d
+		}
+	}
+
+	return x + offsets[nearest][0], y + offsets[nearest][1]
+}
+
+// CellScreenPos returns the screen position at which the tile cell x, y of the
+// given layer is drawn, for a camera at camX, camY. The camera position is in
+// map pixels, in the same frame as the map's parallax origin; Tiled itself
+// uses the center of the view. The cell's pixel position is adjusted by the
+// layer offset, and by the camera scaled by the layer's parallax factor, such
+// that a layer with a factor of 1 scrolls with the camera and one with a
+// factor of 0 is fixed in place, as in Tiled.
+func (m *Map) CellScreenPos(l LayerLike, x, y int, camX, camY float64) (float64, float64) {
+	cx, cy := m.TileToPixel(x, y)
+	px, py := float64(cx), float64(cy)
+	ox, oy := l.LayerOffset()
+	fx, fy := l.LayerParallax()
+
+	sx := px + ox - camX*fx + m.ParallaxOriginX*(fx-1)
+	sy := py + oy - camY*fy + m.ParallaxOriginY*(fy-1)
+
+	return sx, sy
+}
+
+// renderOrderCoords returns the cell coordinates of the i-th tile drawn in a
+// w by h layer under the given render order. Tile data is always stored in
+// rows from the top-left; the render order only changes the sequence in which
+// the cells are drawn. An empty render order is treated as "right-down".
+func renderOrderCoords(order string, i, w, h int) (x, y int) {
+	x, y = i%w, i/w
+
+	switch order {
+	case "right-up":
+		y = h - 1 - y
+	case "left-down":
+		x = w - 1 - x
+	case "left-up":
+		x, y = w-1-x, h-1-y
+	}
+
+	return x, y
+}
+
+// FirstTileCoords returns the coordinates of the first tile drawn under the
+// map's RenderOrder; the top-left for "right-down", but the bottom-left for
+// "right-up", for instance.
+func (m *Map) FirstTileCoords() (x, y int) {
+	if m.Width <= 0 || m.Height <= 0 {
+		return 0, 0
+	}
+
+	return renderOrderCoords(m.RenderOrder, 0, m.Width, m.Height)
+}
@@ -0,0 +1,222 @@
+package tmx
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// fixedLayer is a LayerLike with arbitrary offset and parallax
+type fixedLayer struct {
+	ox, oy, fx, fy float64
+}
+
+func (f fixedLayer) LayerOffset() (float64, float64)   { return f.ox, f.oy }
+func (f fixedLayer) LayerParallax() (float64, float64) { return f.fx, f.fy }
+
+func TestCellScreenPos(t *testing.T) {
+	m := &Map{Orientation: "orthogonal", TileWidth: 16, TileHeight: 8}
+
+	for _, c := range []struct {
+		name       string
+		l          LayerLike
+		origin     [2]float64
+		x, y       int
+		camX, camY float64
+		expX, expY float64
+	}{
+		{"layer", &Layer{OffsetX: 2, OffsetY: -3, ParallaxX: 1, ParallaxY: 1}, [2]float64{}, 2, 3, 10, 20, 32 + 2 - 10, 24 - 3 - 20},
+		{"image layer", &ImageLayer{OffsetX: 0.5, ParallaxX: 1, ParallaxY: 1}, [2]float64{}, 1, 0, 0, 0, 16.5, 0},
+		{"object group", &ObjectGroup{OffsetY: 4, ParallaxX: 1, ParallaxY: 1}, [2]float64{}, 0, 1, 0, 0, 0, 12},
+		{"fixed", fixedLayer{0, 0, 0, 0}, [2]float64{}, 1, 1, 100, 100, 16, 8},
+		{"half speed", fixedLayer{0, 0, 0.5, 0.5}, [2]float64{}, 1, 1, 100, 100, 16 - 50, 8 - 50},
+		{"half speed at origin", fixedLayer{0, 0, 0.5, 0.5}, [2]float64{100, 100}, 1, 1, 100, 100, 16 - 100, 8 - 100},
+	} {
+		m.ParallaxOriginX, m.ParallaxOriginY = c.origin[0], c.origin[1]
+
+		x, y := m.CellScreenPos(c.l, c.x, c.y, c.camX, c.camY)
+		if x != c.expX || y != c.expY {
+			t.Errorf("%v: expected (%v,%v), got (%v,%v)", c.name, c.expX, c.expY, x, y)
+		}
+	}
+}
+
+func TestLayerParallax(t *testing.T) {
+	m := decodeFixture(t, "parallax.tmx")
+
+	far := &m.Groups[0]
+	for _, c := range []struct {
+		name       string
+		l          LayerLike
+		expX, expY float64
+	}{
+		{"sky", &m.ImageLayers[0], 0, 0},
+		{"far", far, 0.5, 0.5},
+		{"hills", &far.Layers[0], 0.5, 0.25},
+		{"clouds", &far.ObjectGroups[0], 1, 0.75},
+		{"ground", m.LayerWithName("ground"), 1, 1},
+	} {
+		if x, y := c.l.LayerParallax(); x != c.expX || y != c.expY {
+			t.Errorf("%v: expected parallax (%v,%v), got (%v,%v)", c.name, c.expX, c.expY, x, y)
+		}
+	}
+
+	// with the camera at the parallax origin, layers are drawn as if they
+	// scrolled with it
+	if x, y := m.CellScreenPos(&far.Layers[0], 1, 1, 16, 8); x != 0 || y != 8 {
+		t.Errorf("expected cell at (0,8), got (%v,%v)", x, y)
+	}
+}
+
+func TestFirstTileCoords(t *testing.T) {
+	for _, c := range []struct {
+		order string
+		x, y  int
+	}{
+		{"", 0, 0},
+		{"right-down", 0, 0},
+		{"right-up", 0, 2},
+		{"left-down", 3, 0},
+		{"left-up", 3, 2},
+	} {
+		m := &Map{RenderOrder: c.order, Width: 4, Height: 3}
+		if x, y := m.FirstTileCoords(); x != c.x || y != c.y {
+			t.Errorf("%v: expected (%v,%v), got (%v,%v)", c.order, c.x, c.y, x, y)
+		}
+	}
+}
+
+func TestRenderOrderCoords(t *testing.T) {
+	for _, order := range []string{"right-down", "right-up", "left-down", "left-up"} {
+		seen := make(map[[2]int]bool)
+		for i := 0; i < 12; i++ {
+			x, y := renderOrderCoords(order, i, 4, 3)
+			if x < 0 || y < 0 || x >= 4 || y >= 3 || seen[[2]int{x, y}] {
+				t.Errorf("%v idx(%v): unexpected or repeated cell (%v,%v)", order, i, x, y)
+			}
+			seen[[2]int{x, y}] = true
+		}
+	}
+
+	// rows are still drawn as rows, from the first tile's row onward
+	if x, y := renderOrderCoords("right-up", 5, 4, 3); x != 1 || y != 1 {
+		t.Errorf("expected (1,1), got (%v,%v)", x, y)
+	}
+}
+
+func TestTileToPixel(t *testing.T) {
+	for _, c := range []struct {
+		name       string
+		m          Map
+		x, y       int
+		expX, expY int
+	}{
+		{"orthogonal", Map{Orientation: "orthogonal", TileWidth: 16, TileHeight: 8}, 2, 3, 32, 24},
+		{"isometric origin", Map{Orientation: "isometric", Width: 4, Height: 4, TileWidth: 32, TileHeight: 16}, 0, 0, 48, 0},
+		{"isometric x", Map{Orientation: "isometric", Width: 4, Height: 4, TileWidth: 32, TileHeight: 16}, 1, 0, 64, 8},
+		{"isometric y", Map{Orientation: "isometric", Width: 4, Height: 4, TileWidth: 32, TileHeight: 16}, 0, 1, 32, 8},
+		{"isometric far", Map{Orientation: "isometric", Width: 4, Height: 4, TileWidth: 32, TileHeight: 16}, 3, 3, 48, 48},
+		{"staggered odd row", Map{Orientation: "staggered", StaggerAxis: 'y', StaggerIndex: "odd", TileWidth: 32, TileHeight: 16}, 1, 1, 48, 8},
+		{"staggered even row", Map{Orientation: "staggered", StaggerAxis: 'y', StaggerIndex: "odd", TileWidth: 32, TileHeight: 16}, 1, 2, 32, 16},
+		{"staggered even index", Map{Orientation: "staggered", StaggerAxis: 'y', StaggerIndex: "even", TileWidth: 32, TileHeight: 16}, 0, 0, 16, 0},
+		{"staggered x", Map{Orientation: "staggered", StaggerAxis: 'x', StaggerIndex: "odd", TileWidth: 32, TileHeight: 16}, 1, 0, 16, 8},
+		{"hexagonal y", Map{Orientation: "hexagonal", StaggerAxis: 'y', StaggerIndex: "odd", TileWidth: 14, TileHeight: 12, HexSideLength: 6}, 0, 1, 7, 9},
+		{"hexagonal y far", Map{Orientation: "hexagonal", StaggerAxis: 'y', StaggerIndex: "odd", TileWidth: 14, TileHeight: 12, HexSideLength: 6}, 1, 2, 14, 18},
+		{"hexagonal x", Map{Orientation: "hexagonal", StaggerAxis: 'x', StaggerIndex: "odd", TileWidth: 12, TileHeight: 14, HexSideLength: 6}, 1, 0, 9, 7},
+		{"hexagonal x even", Map{Orientation: "hexagonal", StaggerAxis: 'x', StaggerIndex: "even", TileWidth: 12, TileHeight: 14, HexSideLength: 6}, 0, 1, 0, 21},
+	} {
+		if x, y := c.m.TileToPixel(c.x, c.y); x != c.expX || y != c.expY {
+			t.Errorf("%v: expected (%v,%v), got (%v,%v)", c.name, c.expX, c.expY, x, y)
+		}
+	}
+}
+
+func TestPixelToTile(t *testing.T) {
+	var maps []Map
+	maps = append(maps,
+		Map{Orientation: "orthogonal", Width: 5, Height: 5, TileWidth: 16, TileHeight: 8},
+		Map{Orientation: "isometric", Width: 5, Height: 5, TileWidth: 32, TileHeight: 16},
+	)
+	for _, axis := range []rune{'x', 'y'} {
+		for _, index := range []string{"odd", "even"} {
+			maps = append(maps,
+				Map{Orientation: "staggered", StaggerAxis: axis, StaggerIndex: index, Width: 5, Height: 5, TileWidth: 32, TileHeight: 16},
+				Map{Orientation: "hexagonal", StaggerAxis: axis, StaggerIndex: index, Width: 5, Height: 5, TileWidth: 14, TileHeight: 12, HexSideLength: 6},
+			)
+		}
+	}
+
+	// the center of each cell should be within the cell
+	for _, m := range maps {
+		for y := 0; y < m.Height; y++ {
+			for x := 0; x < m.Width; x++ {
+				px, py := m.TileToPixel(x, y)
+				cx, cy := px+m.TileWidth/2, py+m.TileHeight/2
+				if rx, ry := m.PixelToTile(cx, cy); rx != x || ry != y {
+					t.Errorf("%v %c %v: expected (%v,%v) at (%v,%v), got (%v,%v)",
+						m.Orientation, m.StaggerAxis, m.StaggerIndex, x, y, cx, cy, rx, ry)
+				}
+			}
+		}
+	}
+
+	for _, c := range []struct {
+		name       string
+		m          Map
+		px, py     int
+		expX, expY int
+	}{
+		{"orthogonal negative", Map{Orientation: "orthogonal", TileWidth: 16, TileHeight: 8}, -1, -9, -1, -2},
+		{"isometric left corner", Map{Orientation: "isometric", Width: 4, Height: 4, TileWidth: 32, TileHeight: 16}, 50, 8, 0, 0},
+		{"isometric outside corner", Map{Orientation: "isometric", Width: 4, Height: 4, TileWidth: 32, TileHeight: 16}, 49, 15, 0, 1},
+		{"isometric below", Map{Orientation: "isometric", Width: 4, Height: 4, TileWidth: 32, TileHeight: 16}, 64, 20, 1, 1},
+		{"staggered corner", Map{Orientation: "staggered", StaggerAxis: 'y', StaggerIndex: "odd", TileWidth: 32, TileHeight: 16}, 1, 1, -1, -1},
+	} {
+		if x, y := c.m.PixelToTile(c.px, c.py); x != c.expX || y != c.expY {
+			t.Errorf("%v: expected (%v,%v), got (%v,%v)", c.name, c.expX, c.expY, x, y)
+		}
+	}
+}
+
+func TestDecodeStaggerAxis(t *testing.T) {
+	m, err := Decode(strings.NewReader(`<map orientation="hexagonal" staggeraxis="x" staggerindex="even" hexsidelength="6"></map>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if m.StaggerAxis != 'x' || m.StaggerIndex != "even" || m.HexSideLength != 6 {
+		t.Errorf("unexpected stagger of map %+v", m)
+	}
+}
+
+func TestStaggerAccessors(t *testing.T) {
+	for _, c := range []struct {
+		axis  rune
+		index string
+		a     Axis
+		i     StaggerIndex
+		valid bool
+	}{
+		{'x', "even", AxisX, StaggerEven, true},
+		{'y', "odd", AxisY, StaggerOdd, true},
+		{0, "", AxisY, StaggerOdd, true},
+		{'z', "sometimes", AxisY, StaggerOdd, false},
+	} {
+		m := &Map{Orientation: "staggered", StaggerAxis: c.axis, StaggerIndex: c.index}
+
+		a, aerr := m.StaggerAxisAxis()
+		i, ierr := m.StaggerIndexValue()
+		if a != c.a || i != c.i {
+			t.Errorf("%q %q: expected axis %v and index %v, got %v and %v", c.axis, c.index, c.a, c.i, a, i)
+		}
+		if valid := aerr == nil && ierr == nil; valid != c.valid {
+			t.Errorf("%q %q: expected valid %v, got errors %v, %v", c.axis, c.index, c.valid, aerr, ierr)
+		}
+		if !c.valid && (!errors.Is(aerr, ErrInvalidStagger) || !errors.Is(ierr, ErrInvalidStagger)) {
+			t.Errorf("%q %q: expected %v, got %v, %v", c.axis, c.index, ErrInvalidStagger, aerr, ierr)
+		}
+		if err := m.Validate(); (err == nil) != c.valid {
+			t.Errorf("%q %q: expected valid %v, got %v", c.axis, c.index, c.valid, err)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package tmx
+
+import "sync"
+
+// decodeFunc appends the GlobalIDs decoded from a <data> payload to dst
+type decodeFunc func(dst []GlobalID, b []byte) ([]GlobalID, error)
+
+var encodings = struct {
+	sync.RWMutex
+	m map[string]decodeFunc
+}{
+	m: map[string]decodeFunc{
+		"base64": decodeB64LayerData,
+		"csv":    decodeCSVLayerData,
+	},
+}
+
+// RegisterEncoding registers a decoder for the tile data of layers whose
+// <data> element specifies the given encoding, replacing any existing decoder
+// for that encoding. The built-in "base64" and "csv" encodings are registered
+// the same way, and may be replaced.
+//
+// The function receives the payload of the <data> element; for "base64" this
+// is after base64 decoding and decompression, for any other encoding it is
+// the raw content of the element. It returns the GlobalIDs of the tiles, as
+// integers. RegisterEncoding is safe for concurrent use.
+func RegisterEncoding(name string, fn func(raw []byte) ([]uint32, error)) {
+	encodings.Lock()
+	defer encodings.Unlock()
+
+	encodings.m[name] = func(dst []GlobalID, b []byte) ([]GlobalID, error) {
+		uis, err := fn(b)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, ui := range uis {
+			dst = append(dst, GlobalID(ui))
+		}
+
+		return dst, nil
+	}
+}
+
+func lookupEncoding(name string) (decodeFunc, bool) {
+	encodings.RLock()
+	defer encodings.RUnlock()
+
+	fn, ok := encodings.m[name]
+
+	return fn, ok
+}
@@ -0,0 +1,75 @@
+package tmx
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// decodeRLE decodes a toy run-length encoding of `count*gid` pairs
+func decodeRLE(raw []byte) ([]uint32, error) {
+	var uis []uint32
+	for _, run := range strings.Fields(string(raw)) {
+		parts := strings.SplitN(run, "*", 2)
+		if len(parts) != 2 {
+			return nil, ErrUnsupportedEncoding
+		}
+
+		n, err := strconv.Atoi(parts[0])
+		if err != nil {
+			return nil, err
+		}
+		gid, err := strconv.ParseUint(parts[1], 10, 32)
+		if err != nil {
+			return nil, err
+		}
+
+		for i := 0; i < n; i++ {
+			uis = append(uis, uint32(gid))
+		}
+	}
+
+	return uis, nil
+}
+
+func TestRegisterEncoding(t *testing.T) {
+	l := &Layer{
+		Width:  3,
+		Height: 2,
+		RawData: Data{
+			Encoding: "x-rle",
+			RawBytes: []byte(" 4*1 2*7 "),
+		},
+	}
+
+	if _, err := l.TileGlobalRefs(); err != ErrUnsupportedEncoding {
+		t.Fatalf("expected ErrUnsupportedEncoding before registering, got %v", err)
+	}
+
+	RegisterEncoding("x-rle", decodeRLE)
+	defer func() {
+		encodings.Lock()
+		delete(encodings.m, "x-rle")
+		encodings.Unlock()
+	}()
+
+	if b, err := l.RawData.Bytes(); err != nil || !bytes.Equal(b, l.RawData.RawBytes) {
+		t.Errorf("expected raw bytes for a registered encoding, got %q (%v)", b, err)
+	}
+
+	trs, err := l.TileGlobalRefs()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := []GlobalID{1, 1, 1, 1, 7, 7}
+	if len(trs) != len(exp) {
+		t.Fatalf("expected %v tiles, got %v", len(exp), len(trs))
+	}
+	for i, e := range exp {
+		if trs[i].GlobalID != e {
+			t.Errorf("idx(%v): expected %v, got %v", i, e, trs[i].GlobalID)
+		}
+	}
+}
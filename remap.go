@@ -0,0 +1,96 @@
+package tmx
+
+import "sync/atomic"
+
+// RemapTileSet moves ts, one of the TileSets of the map, to begin at the
+// given GlobalID, rewriting the tiles of every layer and tile object of the
+// map which refer to it. The flips of each tile are preserved. This is needed
+// to combine the TileSets of several maps into one.
+//
+// A tile refers to the TileSet if its bare ID is at least the FirstGlobalID of
+// the TileSet, and below that of the next TileSet, as when it is resolved to a
+// TileDef. Nothing prevents the new range of the TileSet from overlapping
+// another, which Validate will report. If the tile data of any layer fails to
+// decode, the error is returned and nothing is changed.
+func (m *Map) RemapTileSet(ts *TileSet, newFirstGID GlobalID) error {
+	if m.frozen {
+		panic(errFrozen)
+	}
+
+	first, limit := uint32(ts.FirstGlobalID), uint32(1<<32-1)
+	for i := range m.TileSets {
+		if next := uint32(m.TileSets[i].FirstGlobalID); next > first && next < limit {
+			limit = next
+		}
+	}
+
+	remap := func(g GlobalID) (GlobalID, bool) {
+		bid := g.BareID()
+		if bid < first || bid >= limit {
+			return g, false
+		}
+
+		return GlobalID(bid-first+uint32(newFirstGID)) | g&TileFlipped, true
+	}
+
+	// decode every layer first, so that nothing is changed on failure
+	type layerTiles struct {
+		l      *Layer
+		trs    []TileGlobalRef
+		chunks []Chunk
+	}
+
+	var layers []layerTiles
+	var err error
+	m.walk(func(e interface{}) {
+		l, ok := e.(*Layer)
+		if !ok || err != nil {
+			return
+		}
+
+		lt := layerTiles{l: l}
+		if lt.chunks, err = l.Chunks(); err == nil && lt.chunks == nil {
+			lt.trs, err = l.TileGlobalRefs()
+		}
+		layers = append(layers, lt)
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, lt := range layers {
+		remapTiles(lt.trs, remap)
+
+		// decoded chunks are kept in place of the raw chunks
+		for i := range lt.chunks {
+			remapTiles(lt.chunks[i].TileGlobalRefs, remap)
+		}
+		if lt.chunks != nil {
+			lt.l.RawData.Chunks, lt.l.RawData.unparsed = lt.chunks, false
+		}
+
+		lt.l.tileDefs = atomic.Value{}
+	}
+
+	m.walk(func(e interface{}) {
+		if og, ok := e.(*ObjectGroup); ok {
+			for i := range og.Objects {
+				if g, ok := remap(og.Objects[i].GlobalID); ok {
+					og.Objects[i].GlobalID = g
+				}
+			}
+		}
+	})
+
+	ts.FirstGlobalID = newFirstGID
+
+	return nil
+}
+
+func remapTiles(trs []TileGlobalRef, remap func(GlobalID) (GlobalID, bool)) {
+	for i := range trs {
+		if g, ok := remap(trs[i].GlobalID); ok {
+			trs[i].GlobalID = g
+		}
+	}
+}
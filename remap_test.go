@@ -0,0 +1,111 @@
+package tmx
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRemapTileSet(t *testing.T) {
+	m, err := Decode(strings.NewReader(`<map width="2" height="2" infinite="0">
+		<tileset firstgid="1" name="a" tilecount="4"/>
+		<tileset firstgid="5" name="b" tilecount="4"/>
+		<layer name="ground" width="2" height="2"><data encoding="csv">1,5,2147483656,3221225474</data></layer>
+		<group name="g">
+			<layer name="chunked" width="2" height="2"><data encoding="csv">
+				<chunk x="0" y="0" width="2" height="1">6,0</chunk>
+				<chunk x="0" y="1" width="2" height="1">1073741829,4</chunk>
+			</data></layer>
+		</group>
+		<objectgroup name="things">
+			<object id="1" gid="536870918"/>
+			<object id="2" gid="2"/>
+			<object id="3"/>
+		</objectgroup>
+	</map>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// cache the TileDefs, which must be discarded
+	if _, err := m.Layers[0].TileDefs(m.TileSets); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.RemapTileSet(m.TileSetWithName("b"), 101); err != nil {
+		t.Fatal(err)
+	}
+
+	if ts := m.TileSetWithName("b"); ts.FirstGlobalID != 101 {
+		t.Errorf("expected first gid 101, got %v", ts.FirstGlobalID)
+	}
+	if ts := m.TileSetWithName("a"); ts.FirstGlobalID != 1 {
+		t.Errorf("expected first gid 1, got %v", ts.FirstGlobalID)
+	}
+
+	trs, err := m.Layers[0].TileGlobalRefs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := []TileGlobalRef{{1}, {101}, {104 | TileFlippedHorizontally}, {2 | TileFlippedHorizontally | TileFlippedVertically}}
+	if !reflect.DeepEqual(trs, exp) {
+		t.Errorf("expected tiles %v, got %v", exp, trs)
+	}
+
+	tds, err := m.Layers[0].TileDefs(m.TileSets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if td := tds[2]; td.TileSet.Name != "b" || td.ID != 3 || !td.HorizontallyFlipped || td.VerticallyFlipped {
+		t.Errorf("unexpected tile def %+v", td)
+	}
+
+	chunks, err := m.Groups[0].Layers[0].Chunks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if trs := chunks[0].TileGlobalRefs; !reflect.DeepEqual(trs, []TileGlobalRef{{102}, {0}}) {
+		t.Errorf("unexpected tiles of first chunk %v", trs)
+	}
+	if trs := chunks[1].TileGlobalRefs; !reflect.DeepEqual(trs, []TileGlobalRef{{101 | TileFlippedVertically}, {4}}) {
+		t.Errorf("unexpected tiles of second chunk %v", trs)
+	}
+
+	objects := m.ObjectGroups[0].Objects
+	if g := objects[0].GlobalID; g != 102|TileFlippedDiagonally {
+		t.Errorf("expected object gid %v, got %v", GlobalID(102|TileFlippedDiagonally), g)
+	}
+	if g := objects[1].GlobalID; g != 2 {
+		t.Errorf("expected object gid 2, got %v", g)
+	}
+	if g := objects[2].GlobalID; g != 0 {
+		t.Errorf("expected object gid 0, got %v", g)
+	}
+
+	// the remapped tiles are kept when encoded
+	rm := roundTrip(t, m)
+	if trs, _ := rm.Layers[0].TileGlobalRefs(); !reflect.DeepEqual(trs, exp) {
+		t.Errorf("expected encoded tiles %v, got %v", exp, trs)
+	}
+}
+
+func TestRemapTileSetError(t *testing.T) {
+	m := &Map{
+		TileSets: []TileSet{{FirstGlobalID: 1, Name: "a"}},
+		Layers: []Layer{
+			{Name: "good", Width: 1, Height: 1, RawData: Data{Encoding: "csv", RawBytes: []byte("1")}},
+			{Name: "bad", Width: 1, Height: 1, RawData: Data{Encoding: "csv", RawBytes: []byte("one")}},
+		},
+	}
+
+	if err := m.RemapTileSet(&m.TileSets[0], 10); err == nil {
+		t.Fatal("expected error for malformed layer")
+	}
+
+	if m.TileSets[0].FirstGlobalID != 1 {
+		t.Errorf("expected tileset to be unchanged, got first gid %v", m.TileSets[0].FirstGlobalID)
+	}
+	if gid, err := m.Layers[0].GlobalIDAt(0, 0); err != nil || gid != 1 {
+		t.Errorf("expected layer to be unchanged, got %v (%v)", gid, err)
+	}
+}
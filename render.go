@@ -0,0 +1,96 @@
+package tmx
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// RenderToImage draws the layer onto a new image, blitting each tile's source
+// rectangle from the image of its TileSet, honoring tile flips, the TileSet's
+// tile offset, and the layer's opacity. Tiles larger than the map grid are
+// aligned to the bottom-left of their cell, as Tiled does. Only orthogonal
+// maps are supported.
+//
+// This library does not load images itself, so callers must supply the
+// decoded image for every TileSet used by the layer, keyed by pointers into
+// m.TileSets, such as &m.TileSets[i]; the TileSets need not be sorted.
+func (l *Layer) RenderToImage(m *Map, images map[*TileSet]image.Image) (image.Image, error) {
+	if m.Orientation != "" && m.Orientation != "orthogonal" {
+		return nil, fmt.Errorf("unsupported orientation %v for rendering", m.Orientation)
+	}
+
+	tds, err := l.mapTileDefs(m)
+	if err != nil {
+		return nil, err
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, l.Width*m.TileWidth, l.Height*m.TileHeight))
+	mask := image.NewUniform(color.Alpha{uint8(clampUnit(float64(l.Opacity)) * 0xff)})
+
+	for i, td := range tds {
+		if td.Nil {
+			continue
+		}
+
+		src, ok := images[td.TileSet]
+		if !ok {
+			return nil, fmt.Errorf("no image provided for tileset %v", td.TileSet.Name)
+		}
+
+		tile := flippedTile(src, td.TileSet.tileRect(td.ID), td)
+		size := tile.Bounds().Size()
+
+		x, y := i%l.Width, i/l.Width
+		at := image.Pt(
+			x*m.TileWidth+td.TileSet.TileOffset.X,
+			(y+1)*m.TileHeight-size.Y+td.TileSet.TileOffset.Y,
+		)
+
+		draw.DrawMask(dst, image.Rectangle{at, at.Add(size)}, tile, image.Point{}, mask, image.Point{}, draw.Over)
+	}
+
+	return dst, nil
+}
+
+// flippedTile copies the given rectangle of src to a new image, applying the
+// flips of the TileDef. As in Tiled, the diagonal flip is applied first,
+// followed by the horizontal and vertical flips.
+func flippedTile(src image.Image, r image.Rectangle, td *TileDef) *image.RGBA {
+	w, h := r.Dx(), r.Dy()
+	if td.DiagonallyFlipped {
+		w, h = h, w
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	for v := 0; v < h; v++ {
+		for u := 0; u < w; u++ {
+			su, sv := u, v
+			if td.VerticallyFlipped {
+				sv = h - 1 - sv
+			}
+			if td.HorizontallyFlipped {
+				su = w - 1 - su
+			}
+			if td.DiagonallyFlipped {
+				su, sv = sv, su
+			}
+
+			dst.Set(u, v, src.At(r.Min.X+su, r.Min.Y+sv))
+		}
+	}
+
+	return dst
+}
+
+func clampUnit(f float64) float64 {
+	if f < 0 {
+		return 0
+	}
+	if f > 1 {
+		return 1
+	}
+
+	return f
+}
@@ -0,0 +1,130 @@
+package tmx
+
+import (
+	"image"
+	"image/color"
+	"sync/atomic"
+	"testing"
+)
+
+func TestRenderToImage(t *testing.T) {
+	var (
+		red   = color.RGBA{0xff, 0, 0, 0xff}
+		green = color.RGBA{0, 0xff, 0, 0xff}
+		blue  = color.RGBA{0, 0, 0xff, 0xff}
+		white = color.RGBA{0xff, 0xff, 0xff, 0xff}
+	)
+
+	// a single 2x2 tile, with a distinct color in each corner
+	atlas := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	atlas.Set(0, 0, red)
+	atlas.Set(1, 0, green)
+	atlas.Set(0, 1, blue)
+	atlas.Set(1, 1, white)
+
+	m := &Map{
+		Orientation: "orthogonal",
+		TileWidth:   2,
+		TileHeight:  2,
+		TileSets: []TileSet{{
+			FirstGlobalID: 1,
+			Name:          "corners",
+			TileWidth:     2,
+			TileHeight:    2,
+			TileCount:     1,
+			Columns:       1,
+		}},
+	}
+
+	l := layerFromGIDs(5, 1,
+		1,
+		1|TileFlippedHorizontally,
+		1|TileFlippedVertically,
+		1|TileFlippedDiagonally,
+		0,
+	)
+	l.Opacity = 1
+
+	img, err := l.RenderToImage(m, map[*TileSet]image.Image{&m.TileSets[0]: atlas})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if b := img.Bounds(); b != image.Rect(0, 0, 10, 2) {
+		t.Fatalf("unexpected bounds %v", b)
+	}
+
+	for _, c := range []struct {
+		x, y int
+		exp  color.RGBA
+	}{
+		// unflipped
+		{0, 0, red}, {1, 0, green}, {0, 1, blue}, {1, 1, white},
+		// horizontal
+		{2, 0, green}, {3, 0, red}, {2, 1, white}, {3, 1, blue},
+		// vertical
+		{4, 0, blue}, {5, 0, white}, {4, 1, red}, {5, 1, green},
+		// diagonal
+		{6, 0, red}, {7, 0, blue}, {6, 1, green}, {7, 1, white},
+		// empty
+		{8, 0, color.RGBA{}},
+	} {
+		if v := color.RGBAModel.Convert(img.At(c.x, c.y)); v != c.exp {
+			t.Errorf("(%v,%v): expected %v, got %v", c.x, c.y, c.exp, v)
+		}
+	}
+
+	l.Opacity = 0.5
+	l.tileDefs = atomic.Value{}
+	img, err = l.RenderToImage(m, map[*TileSet]image.Image{&m.TileSets[0]: atlas})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, _, a := img.At(0, 0).RGBA(); a>>8 != 0x7f {
+		t.Errorf("expected half opacity, got alpha %v", a>>8)
+	}
+
+	if _, err := l.RenderToImage(m, nil); err == nil {
+		t.Error("expected an error when no tileset image is provided")
+	}
+}
+
+func TestRenderToImageUnsortedTileSets(t *testing.T) {
+	red := color.RGBA{0xff, 0, 0, 0xff}
+	green := color.RGBA{0, 0xff, 0, 0xff}
+
+	m := &Map{
+		Orientation: "orthogonal",
+		TileWidth:   1,
+		TileHeight:  1,
+		TileSets: []TileSet{
+			{FirstGlobalID: 2, Name: "green", TileWidth: 1, TileHeight: 1, TileCount: 1, Columns: 1},
+			{FirstGlobalID: 1, Name: "red", TileWidth: 1, TileHeight: 1, TileCount: 1, Columns: 1},
+		},
+	}
+	images := map[*TileSet]image.Image{
+		&m.TileSets[0]: image.NewUniform(green),
+		&m.TileSets[1]: image.NewUniform(red),
+	}
+
+	l := layerFromGIDs(2, 1, 1, 2)
+	l.Opacity = 1
+
+	// TileDefs matched with a copy of the tilesets first are not reused
+	if _, err := l.TileDefs(m.SortedTileSets()); err != nil {
+		t.Fatal(err)
+	}
+
+	img, err := l.RenderToImage(m, images)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for x, exp := range []color.RGBA{red, green} {
+		if v := color.RGBAModel.Convert(img.At(x, 0)); v != exp {
+			t.Errorf("(%v,0): expected %v, got %v", x, exp, v)
+		}
+	}
+	if m.TileSets[0].Name != "green" {
+		t.Error("expected tilesets of map not to be sorted")
+	}
+}
@@ -0,0 +1,138 @@
+package tmx
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sync/atomic"
+)
+
+// DecodeFile opens and decodes the map at the given path, then resolves its
+// external TileSets and object templates relative to the map's directory, as
+// with ResolveTileSets and ResolveTemplates. Absolute sources are used as-is.
+// The map's directory is available afterwards from BaseDir, for resolving
+// image sources.
+func DecodeFile(name string) (*Map, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	m, err := Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode map %v: %w", name, err)
+	}
+
+	m.baseDir = filepath.Dir(name)
+
+	open := func(source string) (io.ReadCloser, error) {
+		p := filepath.FromSlash(source)
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(m.baseDir, p)
+		}
+
+		return os.Open(p)
+	}
+
+	if err := m.resolveTileSets(open); err != nil {
+		return nil, fmt.Errorf("map %v: %w", name, err)
+	}
+
+	if err := m.resolveTemplates(open); err != nil {
+		return nil, fmt.Errorf("map %v: %w", name, err)
+	}
+
+	return m, nil
+}
+
+// BaseDir returns the directory of the file the map was decoded from with
+// DecodeFile; empty if it was decoded from a reader.
+func (m *Map) BaseDir() string {
+	return m.baseDir
+}
+
+// ResolveTileSets loads the external TileSets of the map, those with a
+// non-empty Source, from the given filesystem. Each is decoded with
+// DecodeTileset and merged into the map's TileSet, preserving its
+// FirstGlobalID and Source; TileSets embedded in the map are left untouched.
+// As sources are relative to the map file, fsys should be rooted at the map's
+// directory, such as with fs.Sub; sources outside of it cannot be opened.
+//
+// Image sources within an external TileSet are relative to the TileSet file,
+// so they are rewritten to be relative to the map, like those of embedded
+// TileSets. Any TileDefs cached on the map's layers are discarded.
+func (m *Map) ResolveTileSets(fsys fs.FS) error {
+	return m.resolveTileSets(func(source string) (io.ReadCloser, error) {
+		return fsys.Open(path.Clean(source))
+	})
+}
+
+func (m *Map) resolveTileSets(open func(source string) (io.ReadCloser, error)) error {
+	if m.frozen {
+		panic(errFrozen)
+	}
+
+	for i := range m.TileSets {
+		ts := &m.TileSets[i]
+		if ts.Source == "" || ts.resolved {
+			continue
+		}
+
+		ext, err := decodeTileSetSource(open, ts.Source)
+		if err != nil {
+			return fmt.Errorf("could not resolve tileset %v: %w", ts.Source, err)
+		}
+
+		ts.merge(ext)
+	}
+
+	m.sortedTileSets = atomic.Value{}
+	m.walk(func(e interface{}) {
+		if l, ok := e.(*Layer); ok {
+			l.tileDefs = atomic.Value{}
+		}
+	})
+
+	return nil
+}
+
+func decodeTileSetSource(open func(source string) (io.ReadCloser, error), source string) (*TileSet, error) {
+	r, err := open(source)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	return DecodeTileset(r)
+}
+
+// merge replaces the TileSet with one decoded from its Source, keeping the
+// FirstGlobalID and Source, and rebasing image sources onto the directory of
+// the Source.
+func (ts *TileSet) merge(ext *TileSet) {
+	first, source := ts.FirstGlobalID, ts.Source
+
+	*ts = *ext
+	ts.FirstGlobalID = first
+	ts.Source = source
+	ts.resolved = true
+
+	dir := path.Dir(source)
+	ts.Image.Source = rebase(dir, ts.Image.Source)
+	for i := range ts.Tiles {
+		ts.Tiles[i].Image.Source = rebase(dir, ts.Tiles[i].Image.Source)
+	}
+}
+
+// rebase makes a relative path relative to dir instead
+func rebase(dir, p string) string {
+	if p == "" || path.IsAbs(p) {
+		return p
+	}
+
+	return path.Join(dir, p)
+}
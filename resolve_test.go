@@ -0,0 +1,192 @@
+package tmx
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestResolveTileSets(t *testing.T) {
+	m := decodeFixture(t, "external.tmx")
+
+	if _, err := m.LayerWithName("ground").TileDefs(m.TileSets); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.ResolveTileSets(os.DirFS("fixtures")); err != nil {
+		t.Fatal(err)
+	}
+
+	blocks := m.TileSetWithName("blocks")
+	if blocks == nil || blocks.Image.Source != "blocks.png" {
+		t.Errorf("expected embedded tileset to be untouched, got %+v", blocks)
+	}
+
+	animated := m.TileSetWithName("animated")
+	if animated == nil {
+		t.Fatal("expected tileset with name `animated`, but found none.")
+	}
+	if animated.FirstGlobalID != 5 || animated.Source != "animated.tsx" {
+		t.Errorf("expected first gid 5 and source to be kept, got %v and %v", animated.FirstGlobalID, animated.Source)
+	}
+	if animated.TileCount != 8 || animated.Image.Source != "animated.png" {
+		t.Errorf("expected tileset data to be merged, got %+v", animated)
+	}
+	if speed, err := animated.Properties.Float("speed"); err != nil || speed != 1.5 {
+		t.Errorf("expected tileset property `speed` to be 1.5, got %v (%v)", speed, err)
+	}
+
+	tds, err := m.LayerWithName("ground").TileDefs(m.TileSets)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	water := tds[0]
+	if water.TileSet != animated || water.ID != 0 || water.Tile == nil {
+		t.Fatalf("expected tile 0 of `animated`, got %+v", water)
+	}
+	if l := len(water.Tile.Animation); l != 3 {
+		t.Errorf("expected 3 animation frames, got %v", l)
+	}
+	for _, c := range []struct {
+		elapsed time.Duration
+		expect  TileID
+	}{
+		{0, 0},
+		{150 * time.Millisecond, 1},
+		{350 * time.Millisecond, 2},
+		{650 * time.Millisecond, 0},
+	} {
+		if f := water.Tile.FrameAt(c.elapsed); f.TileID != c.expect {
+			t.Errorf("expected frame of tile %v at %v, got %v", c.expect, c.elapsed, f.TileID)
+		}
+	}
+	if v, err := water.Tile.Properties.Bool("water"); err != nil || !v {
+		t.Errorf("expected property `water` to be true, got %v (%v)", v, err)
+	}
+
+	solid := tds[3]
+	if solid.ID != 4 || len(solid.CollisionShapes()) != 1 {
+		t.Errorf("expected tile 4 with a collision shape, got %+v", solid)
+	}
+}
+
+func TestResolveTileSetsPaths(t *testing.T) {
+	tsx, err := os.ReadFile("fixtures/animated.tsx")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fsys := fstest.MapFS{"tilesets/animated.tsx": &fstest.MapFile{Data: tsx}}
+
+	m := &Map{TileSets: []TileSet{{FirstGlobalID: 1, Source: "./tilesets/animated.tsx"}}}
+	if err := m.ResolveTileSets(fsys); err != nil {
+		t.Fatal(err)
+	}
+	if s := m.TileSets[0].Image.Source; s != "tilesets/animated.png" {
+		t.Errorf("expected image source relative to the map, got %v", s)
+	}
+
+	m = &Map{TileSets: []TileSet{{FirstGlobalID: 1, Source: "missing.tsx"}}}
+	err = m.ResolveTileSets(fsys)
+	if !errors.Is(err, fs.ErrNotExist) || !strings.Contains(err.Error(), "missing.tsx") {
+		t.Errorf("expected a not-exist error naming the source, got %v", err)
+	}
+}
+
+func TestDecodeFile(t *testing.T) {
+	m, err := DecodeFile("fixtures/external.tmx")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if d := m.BaseDir(); d != "fixtures" {
+		t.Errorf("expected base dir `fixtures`, got %v", d)
+	}
+
+	if ts := m.TileSetWithName("animated"); ts == nil || ts.TileCount != 8 {
+		t.Errorf("expected external tileset to be resolved, got %+v", ts)
+	}
+}
+
+func TestDecodeFileAbsoluteSource(t *testing.T) {
+	tsx, err := filepath.Abs("fixtures/animated.tsx")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	name := filepath.Join(dir, "map.tmx")
+	data := `<map version="1.0" orientation="orthogonal" width="1" height="1" tilewidth="8" tileheight="8">
+ <tileset firstgid="1" source="` + filepath.ToSlash(tsx) + `"/>
+</map>`
+	if err := os.WriteFile(name, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := DecodeFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.TileSets[0].TileCount != 8 {
+		t.Errorf("expected absolute tileset source to be resolved, got %+v", m.TileSets[0])
+	}
+}
+
+func TestDecodeFileMissingTileSet(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "map.tmx")
+	data := `<map version="1.0"><tileset firstgid="1" source="nope.tsx"/></map>`
+	if err := os.WriteFile(name, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := DecodeFile(name)
+	if err == nil {
+		t.Fatal("expected error for missing tileset, got none")
+	}
+	if msg := err.Error(); !strings.Contains(msg, name) || !strings.Contains(msg, "nope.tsx") {
+		t.Errorf("expected error to name map and tileset, got %v", msg)
+	}
+}
+
+func TestPropertiesFile(t *testing.T) {
+	abs, err := filepath.Abs("dialogue.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pl := Properties{
+		{Name: "script", Type: "file", Value: "../scripts/./intro.lua"},
+		{Name: "absolute", Type: "file", Value: filepath.ToSlash(abs)},
+		{Name: "unset", Type: "file", Value: ""},
+		{Name: "text", Value: "intro.lua"},
+	}
+
+	tests := []struct {
+		name     string
+		expected string
+		err      error
+	}{
+		{"script", filepath.Join("maps", "..", "scripts", "intro.lua"), nil},
+		{"absolute", filepath.ToSlash(abs), nil},
+		{"unset", "", ErrPropertyEmpty},
+		{"text", "", ErrPropertyWrongType},
+		{"missing", "", ErrPropertyNotFound},
+	}
+
+	for _, test := range tests {
+		v, err := pl.File(test.name, "maps")
+		if err != test.err {
+			t.Errorf("%v: expected error %v, got %v", test.name, test.err, err)
+		}
+		if v != test.expected {
+			t.Errorf("%v: expected %v, got %v", test.name, test.expected, v)
+		}
+	}
+}
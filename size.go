@@ -0,0 +1,114 @@
+package tmx
+
+import "unsafe"
+
+// EstimatedSize returns an approximation of the memory used by the decoded
+// map, in bytes. It is not exact, but scales with the dominant costs: the
+// tile data of each layer (counted as 4 bytes per tile, whether or not it has
+// been decoded yet), any cached TileDefs, objects, properties, and raw data
+// such as embedded images. It is intended for making eviction decisions in
+// caches of decoded maps.
+func (m *Map) EstimatedSize() int {
+	n := int(unsafe.Sizeof(*m))
+	n += propertiesSize(m.Properties)
+
+	for i := range m.TileSets {
+		n += tileSetSize(&m.TileSets[i])
+	}
+
+	m.walk(func(e interface{}) {
+		switch e := e.(type) {
+		case *Layer:
+			n += layerSize(e)
+		case *ObjectGroup:
+			n += objectGroupSize(e)
+		case *ImageLayer:
+			n += int(unsafe.Sizeof(*e))
+			n += propertiesSize(e.Properties)
+			n += imageSize(&e.Image)
+		case *Group:
+			n += int(unsafe.Sizeof(*e))
+			n += propertiesSize(e.Properties)
+		}
+	})
+
+	for _, t := range m.RawExtra {
+		n += int(unsafe.Sizeof(t)) + len(t.Content)
+	}
+
+	return n
+}
+
+func propertiesSize(pl Properties) int {
+	n := 0
+	for _, p := range pl {
+		n += int(unsafe.Sizeof(p)) + len(p.Name) + len(p.Type) + len(p.Value)
+	}
+
+	return n
+}
+
+func imageSize(i *Image) int {
+	return len(i.Source) + len(i.Data.RawBytes)
+}
+
+func tileSetSize(ts *TileSet) int {
+	n := int(unsafe.Sizeof(*ts))
+	n += propertiesSize(ts.Properties)
+	n += imageSize(&ts.Image)
+
+	for i := range ts.Tiles {
+		t := &ts.Tiles[i]
+		n += int(unsafe.Sizeof(*t))
+		n += propertiesSize(t.Properties)
+		n += imageSize(&t.Image)
+		n += len(t.Animation) * int(unsafe.Sizeof(Frame{}))
+		n += objectGroupSize(&t.ObjectGroup)
+	}
+
+	return n
+}
+
+func layerSize(l *Layer) int {
+	n := int(unsafe.Sizeof(*l))
+	n += propertiesSize(l.Properties)
+	n += len(l.RawData.RawBytes)
+
+	tiles := l.Width * l.Height
+	if c := len(l.RawData.TileGlobalRefs); c > tiles {
+		tiles = c
+	}
+	if c := len(l.cachedTileGlobalRefs()); c > tiles {
+		tiles = c
+	}
+	n += tiles * int(unsafe.Sizeof(TileGlobalRef{}))
+
+	n += len(l.cachedTileDefs()) * int(unsafe.Sizeof(&TileDef{})+unsafe.Sizeof(TileDef{}))
+
+	return n
+}
+
+func objectGroupSize(og *ObjectGroup) int {
+	n := int(unsafe.Sizeof(*og))
+	n += propertiesSize(og.Properties)
+
+	for i := range og.Objects {
+		o := &og.Objects[i]
+		n += int(unsafe.Sizeof(*o))
+		n += len(o.Name) + len(o.Type)
+		n += propertiesSize(o.Properties)
+		n += imageSize(&o.Image)
+
+		for _, p := range o.Polygons {
+			n += int(unsafe.Sizeof(p)) + len(p.RawPoints)
+		}
+		for _, p := range o.Polylines {
+			n += int(unsafe.Sizeof(p)) + len(p.RawPoints)
+		}
+		for _, t := range o.RawExtra {
+			n += int(unsafe.Sizeof(t)) + len(t.Content)
+		}
+	}
+
+	return n
+}
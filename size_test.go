@@ -0,0 +1,24 @@
+package tmx
+
+import "testing"
+
+func TestEstimatedSize(t *testing.T) {
+	m := decodeFixture(t, "test.tmx")
+
+	n := m.EstimatedSize()
+	if min := m.Width * m.Height * 4 * len(m.Layers); n < min {
+		t.Errorf("expected at least %v bytes for tile data, got %v", min, n)
+	}
+
+	if _, err := m.LayerWithName("walls").TileDefs(m.TileSets); err != nil {
+		t.Fatal(err)
+	}
+	if c := m.EstimatedSize(); c <= n {
+		t.Errorf("expected size to grow after caching tile defs, got %v then %v", n, c)
+	}
+
+	small := decodeFixture(t, "collision.tmx")
+	if s := small.EstimatedSize(); s >= n {
+		t.Errorf("expected a small map (%v) to be smaller than a large one (%v)", s, n)
+	}
+}
@@ -0,0 +1,215 @@
+package tmx
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+)
+
+// LayerReader reads the tile layers of a map one at a time; see
+// NewLayerReader.
+type LayerReader struct {
+	m *Map
+	d *xml.Decoder
+
+	// the start of the next tile layer, if any
+	next *xml.StartElement
+	err  error
+
+	// groups enclosing the element being read, innermost last
+	groups []*Group
+	z      int
+}
+
+// NewLayerReader begins decoding a map from r, returning the Map with
+// everything before its first tile layer decoded, such as its attributes,
+// TileSets, and properties. The tile layers are then read one at a time with
+// Next, so that a large map need not be held in memory all at once; they are
+// not added to the Map.
+//
+// Any object groups, image layers, and groups are added to the Map as they are
+// read, while reading the tile layers; groups are added without their tile
+// layers, which are read with Next like any other. Each element is numbered in
+// document order as its Z, as with Decode, and external TileSets and templates
+// are left unresolved.
+func NewLayerReader(r io.Reader) (*Map, *LayerReader, error) {
+	lr := &LayerReader{m: new(Map), d: xml.NewDecoder(r)}
+
+	for {
+		tok, err := lr.d.Token()
+		if err != nil {
+			return nil, nil, decodeError(lr.d, xml.StartElement{Name: xml.Name{Local: "map"}}, err)
+		}
+
+		if start, ok := tok.(xml.StartElement); ok {
+			if err := decodeAttrs(start, lr.m); err != nil {
+				return nil, nil, decodeError(lr.d, start, err)
+			}
+			break
+		}
+	}
+
+	if err := lr.advance(); err != nil && err != io.EOF {
+		return nil, nil, err
+	}
+
+	return lr.m, lr, nil
+}
+
+// Next decodes and returns the next tile layer of the map, including those
+// within groups. Returns io.EOF once there are no more layers.
+func (lr *LayerReader) Next() (*Layer, error) {
+	if lr.next == nil {
+		return nil, lr.err
+	}
+
+	l := new(Layer)
+	if err := lr.d.DecodeElement(l, lr.next); err != nil {
+		lr.next, lr.err = nil, err
+		return nil, err
+	}
+	l.Z = lr.nextZ()
+	l.renderOrder = lr.m.RenderOrder
+	l.chunkWidth, l.chunkHeight = lr.m.ChunkSize()
+	if err := l.applyOptions(Options{}); err != nil {
+		lr.next, lr.err = nil, err
+		return nil, err
+	}
+
+	// any error is returned by the following call
+	lr.advance()
+
+	return l, nil
+}
+
+// advance reads up to the start of the next tile layer, adding everything
+// before it to the map
+func (lr *LayerReader) advance() error {
+	lr.next = nil
+
+	for {
+		tok, err := lr.d.Token()
+		if err != nil {
+			lr.err = decodeError(lr.d, xml.StartElement{Name: xml.Name{Local: "map"}}, err)
+			return lr.err
+		}
+
+		var start xml.StartElement
+		switch t := tok.(type) {
+		case xml.StartElement:
+			start = t
+		case xml.EndElement:
+			if len(lr.groups) == 0 {
+				// the end of the map
+				lr.err = io.EOF
+				return lr.err
+			}
+			lr.groups = lr.groups[:len(lr.groups)-1]
+			continue
+		default:
+			continue
+		}
+
+		if start.Name.Local == "layer" {
+			lr.next = &start
+			return nil
+		}
+
+		if err := lr.decode(start); err != nil {
+			lr.err = err
+			return err
+		}
+	}
+}
+
+// decode decodes an element other than a tile layer into the map, or the
+// innermost group being read
+func (lr *LayerReader) decode(start xml.StartElement) error {
+	ogs, ils, gs, pl := &lr.m.ObjectGroups, &lr.m.ImageLayers, &lr.m.Groups, &lr.m.Properties
+	if n := len(lr.groups); n > 0 {
+		g := lr.groups[n-1]
+		ogs, ils, gs, pl = &g.ObjectGroups, &g.ImageLayers, &g.Groups, &g.Properties
+	}
+
+	switch start.Name.Local {
+	case "objectgroup":
+		var og ObjectGroup
+		if err := lr.d.DecodeElement(&og, &start); err != nil {
+			return err
+		}
+		og.Z = lr.nextZ()
+		*ogs = append(*ogs, og)
+	case "imagelayer":
+		var il ImageLayer
+		if err := lr.d.DecodeElement(&il, &start); err != nil {
+			return err
+		}
+		il.Z = lr.nextZ()
+		*ils = append(*ils, il)
+	case "group":
+		// the children of the group are read as they come
+		var g Group
+		if err := decodeAttrs(start, &g); err != nil {
+			return decodeError(lr.d, start, err)
+		}
+		g.Z = lr.nextZ()
+		*gs = append(*gs, g)
+		lr.groups = append(lr.groups, &(*gs)[len(*gs)-1])
+	case "properties":
+		var p struct {
+			Properties Properties `xml:"property"`
+		}
+		if err := lr.d.DecodeElement(&p, &start); err != nil {
+			return decodeError(lr.d, start, err)
+		}
+		*pl = append(*pl, p.Properties...)
+	case "editorsettings":
+		var s EditorSettings
+		if err := lr.d.DecodeElement(&s, &start); err != nil {
+			return decodeError(lr.d, start, err)
+		}
+		lr.m.EditorSettings = &s
+	case "tileset":
+		var ts TileSet
+		if err := lr.d.DecodeElement(&ts, &start); err != nil {
+			return decodeError(lr.d, start, err)
+		}
+		lr.m.TileSets = append(lr.m.TileSets, ts)
+	default:
+		var t Tag
+		if err := lr.d.DecodeElement(&t, &start); err != nil {
+			return decodeError(lr.d, start, err)
+		}
+		if len(lr.groups) == 0 {
+			lr.m.RawExtra = append(lr.m.RawExtra, t)
+		}
+	}
+
+	return nil
+}
+
+// nextZ returns the Z of the next element of the map
+func (lr *LayerReader) nextZ() int {
+	z := lr.z
+	lr.z++
+
+	return z
+}
+
+// decodeAttrs decodes only the attributes of an element into v, leaving its
+// children to be read separately
+func decodeAttrs(start xml.StartElement, v interface{}) error {
+	var b bytes.Buffer
+	e := xml.NewEncoder(&b)
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	if err := e.EncodeToken(start.End()); err != nil {
+		return err
+	}
+	if err := e.Flush(); err != nil {
+		return err
+	}
+
+	return xml.Unmarshal(b.Bytes(), v)
+}
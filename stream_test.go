@@ -0,0 +1,107 @@
+package tmx
+
+import (
+	"io"
+	"os"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLayerReader(t *testing.T) {
+	for _, name := range []string{"test.tmx", "groups.tmx", "namespaced.tmx", "parallax.tmx"} {
+		file, err := os.Open(path.Join("fixtures", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer file.Close()
+
+		m, lr, err := NewLayerReader(file)
+		if err != nil {
+			t.Fatalf("%v: %v", name, err)
+		}
+
+		exp := decodeFixture(t, name)
+		if m.Width != exp.Width || m.Orientation != exp.Orientation || len(m.TileSets) != len(exp.TileSets) {
+			t.Errorf("%v: unexpected map %+v", name, m)
+		}
+
+		var layers []*Layer
+		for {
+			l, err := lr.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("%v: %v", name, err)
+			}
+			layers = append(layers, l)
+		}
+
+		var expLayers []*Layer
+		exp.WalkLayers(func(l *Layer) {
+			expLayers = append(expLayers, l)
+		})
+
+		if len(layers) != len(expLayers) {
+			t.Fatalf("%v: expected %v layers, got %v", name, len(expLayers), len(layers))
+		}
+		for i, l := range layers {
+			e := expLayers[i]
+			if l.Name != e.Name || l.Z != e.Z || l.renderOrder != e.renderOrder {
+				t.Errorf("%v: expected layer %v at %v, got %v at %v", name, e.Name, e.Z, l.Name, l.Z)
+			}
+
+			trs, err := l.TileGlobalRefs()
+			if err != nil {
+				t.Fatal(err)
+			}
+			expTrs, err := e.TileGlobalRefs()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(trs, expTrs) {
+				t.Errorf("%v: unexpected tiles of layer %v", name, l.Name)
+			}
+		}
+
+		// everything besides the tile layers is added to the map as it is read
+		exp.Layers = nil
+		exp.walk(func(e interface{}) {
+			if g, ok := e.(*Group); ok {
+				g.Layers = nil
+			}
+		})
+		if !reflect.DeepEqual(decodedState(t, m), decodedState(t, exp)) {
+			t.Errorf("%v: expected read map to match decoded map\n%+v\n%+v", name, m, exp)
+		}
+
+		if _, err := lr.Next(); err != io.EOF {
+			t.Errorf("%v: expected EOF again, got %v", name, err)
+		}
+	}
+}
+
+func TestLayerReaderError(t *testing.T) {
+	_, lr, err := NewLayerReader(strings.NewReader(`<map width="1" height="1">
+		<layer name="good" width="1" height="1"><data encoding="csv">1</data></layer>
+		<layer name="bad" width="one"/>
+	</map>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if l, err := lr.Next(); err != nil || l.Name != "good" {
+		t.Fatalf("expected layer `good`, got %+v (%v)", l, err)
+	}
+
+	_, err = lr.Next()
+	if _, ok := err.(*DecodeError); !ok {
+		t.Errorf("expected DecodeError, got %v", err)
+	}
+
+	if _, _, err := NewLayerReader(strings.NewReader(`<map width="wide"/>`)); err == nil {
+		t.Error("expected error for malformed map")
+	}
+}
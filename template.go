@@ -0,0 +1,257 @@
+package tmx
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"reflect"
+	"sync/atomic"
+)
+
+// Template is an object template, as saved by Tiled to .tx files, from which
+// objects in a map may be instanced.
+type Template struct {
+	// TileSet is the tileset of the template's tile object, if it is one. Its
+	// Source is relative to the template file.
+	TileSet *TileSet `xml:"tileset"`
+	Object  Object   `xml:"object"`
+}
+
+// DecodeTemplate takes a reader for a .tx template file, and returns a new
+// Template decoded from it.
+func DecodeTemplate(r io.Reader) (*Template, error) {
+	d := xml.NewDecoder(r)
+	t := new(Template)
+
+	if err := d.Decode(t); err != nil {
+		return nil, decodeError(d, xml.StartElement{Name: xml.Name{Local: "template"}}, err)
+	}
+
+	return t, nil
+}
+
+// UnmarshalXML decodes an Object, defaulting Visible to true when the
+// attribute is absent, as Tiled does. It also takes note of which attributes
+// are set on an instance of a template, as only those override the template.
+func (o *Object) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type object Object
+	o.Visible = true
+	if err := d.DecodeElement((*object)(o), &start); err != nil {
+		return decodeError(d, start, err)
+	}
+
+	if o.Template != "" {
+		for _, a := range start.Attr {
+			o.overrides = append(o.overrides, a.Name.Local)
+		}
+	}
+
+	return nil
+}
+
+// overridden returns true if the named attribute was set on the object itself
+func (o *Object) overridden(name string) bool {
+	for _, n := range o.overrides {
+		if n == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ResolveTemplates loads the templates of the map's objects from the given
+// filesystem, and fills in each templated object from its template. The
+// position and ID of an object always come from the object itself, as do any
+// attributes set on it in the map; everything else comes from the template.
+// Properties are merged, with those of the object taking precedence.
+//
+// The GlobalID of a tile object is relative to the template's TileSet, so it
+// is remapped onto the map's reference to the same TileSet. If the map has
+// none, the TileSet is added to the end of the map's TileSets; one embedded
+// in the template, rather than referred to by its Source, is added once for
+// all instances of the template. As this needs the size of each TileSet,
+// external TileSets are resolved first, as with ResolveTileSets; fsys should
+// likewise be rooted at the map's directory.
+func (m *Map) ResolveTemplates(fsys fs.FS) error {
+	open := func(source string) (io.ReadCloser, error) {
+		return fsys.Open(path.Clean(source))
+	}
+
+	if err := m.resolveTileSets(open); err != nil {
+		return err
+	}
+
+	return m.resolveTemplates(open)
+}
+
+func (m *Map) resolveTemplates(open func(source string) (io.ReadCloser, error)) error {
+	if m.frozen {
+		panic(errFrozen)
+	}
+
+	templates := make(map[string]*Template)
+
+	// the index within m.TileSets of the TileSet added for each template, as
+	// TileSets embedded in a template cannot be found by their Source
+	added := make(map[string]int)
+
+	var err error
+	m.walk(func(e interface{}) {
+		og, ok := e.(*ObjectGroup)
+		if !ok || err != nil {
+			return
+		}
+
+		for i := range og.Objects {
+			o := &og.Objects[i]
+			if o.Template == "" {
+				continue
+			}
+
+			source := path.Clean(o.Template)
+			t, ok := templates[source]
+			if !ok {
+				if t, err = decodeTemplateSource(open, source); err != nil {
+					err = fmt.Errorf("could not resolve template %v: %w", o.Template, err)
+					return
+				}
+				templates[source] = t
+			}
+
+			gid := o.GlobalID
+			o.apply(&t.Object)
+
+			if !o.overridden("gid") {
+				gid, err = m.templateGlobalID(open, source, t, added)
+				if err != nil {
+					err = fmt.Errorf("could not resolve template %v: %w", o.Template, err)
+					return
+				}
+			}
+			o.GlobalID = gid
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	// TileSets may have been added, moving any TileSets referred to by the
+	// cached TileDefs
+	m.walk(func(e interface{}) {
+		if l, ok := e.(*Layer); ok {
+			l.tileDefs = atomic.Value{}
+		}
+	})
+
+	return nil
+}
+
+func decodeTemplateSource(open func(source string) (io.ReadCloser, error), source string) (*Template, error) {
+	r, err := open(source)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	return DecodeTemplate(r)
+}
+
+// templateGlobalID returns the GlobalID of the template's object within the
+// map, adding the template's TileSet to the map if it is not already there,
+// and noting its index in added
+func (m *Map) templateGlobalID(open func(source string) (io.ReadCloser, error), source string, t *Template, added map[string]int) (GlobalID, error) {
+	gid := t.Object.GlobalID
+	if gid == 0 || t.TileSet == nil {
+		return gid, nil
+	}
+
+	var ts *TileSet
+	tsSource := path.Clean(rebase(path.Dir(source), t.TileSet.Source))
+	if i, ok := added[source]; ok {
+		ts = &m.TileSets[i]
+	} else if t.TileSet.Source != "" {
+		for i := range m.TileSets {
+			if path.Clean(m.TileSets[i].Source) == tsSource {
+				ts = &m.TileSets[i]
+				break
+			}
+		}
+	}
+
+	if ts == nil {
+		next := GlobalID(1)
+		for i := range m.TileSets {
+			if n := m.TileSets[i].FirstGlobalID + GlobalID(m.TileSets[i].tileCount()); n > next {
+				next = n
+			}
+		}
+
+		add := *t.TileSet
+		if add.Source != "" {
+			ext, err := decodeTileSetSource(open, tsSource)
+			if err != nil {
+				return 0, fmt.Errorf("could not resolve tileset %v: %w", tsSource, err)
+			}
+
+			add.Source = tsSource
+			add.merge(ext)
+		}
+		add.FirstGlobalID = next
+
+		m.TileSets = append(m.TileSets, add)
+		added[source] = len(m.TileSets) - 1
+		ts = &m.TileSets[len(m.TileSets)-1]
+	}
+
+	id := gid.BareID() - uint32(t.TileSet.FirstGlobalID)
+
+	return GlobalID(uint32(ts.FirstGlobalID)+id) | gid&TileFlipped, nil
+}
+
+// apply fills in the object from its template, leaving the position, ID, and
+// any attributes set on the object itself
+func (o *Object) apply(t *Object) {
+	if !o.overridden("name") {
+		o.Name = t.Name
+	}
+	if !o.overridden("type") && !o.overridden("class") {
+		o.Type = t.Type
+	}
+	if !o.overridden("width") {
+		o.Width = t.Width
+	}
+	if !o.overridden("height") {
+		o.Height = t.Height
+	}
+	if !o.overridden("rotation") {
+		o.Rotation = t.Rotation
+	}
+	if !o.overridden("visible") {
+		o.Visible = t.Visible
+	}
+
+	o.Properties = o.Properties.MergedWith(t.Properties)
+
+	if len(o.Polygons) == 0 && len(o.Polylines) == 0 {
+		o.Polygons = append([]Poly(nil), t.Polygons...)
+		o.Polylines = append([]Poly(nil), t.Polylines...)
+	}
+
+	if o.Text == nil && t.Text != nil {
+		text := *t.Text
+		o.Text = &text
+	}
+
+	if reflect.ValueOf(o.Image).IsZero() {
+		o.Image = t.Image
+	}
+
+	for _, e := range t.RawExtra {
+		if !o.hasExtra(e.XMLName.Local) {
+			o.RawExtra = append(o.RawExtra, e)
+		}
+	}
+}
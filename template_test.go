@@ -0,0 +1,216 @@
+package tmx
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestDecodeTemplate(t *testing.T) {
+	file, err := os.Open(path.Join("fixtures", "torch.tx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	tmpl, err := DecodeTemplate(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if tmpl.TileSet == nil || tmpl.TileSet.Source != "animated.tsx" || tmpl.TileSet.FirstGlobalID != 1 {
+		t.Errorf("unexpected template tileset %+v", tmpl.TileSet)
+	}
+
+	o := tmpl.Object
+	if o.Name != "torch" || o.Type != "light" || o.GlobalID != 1 || o.Width != 16 {
+		t.Errorf("unexpected template object %+v", o)
+	}
+}
+
+func TestResolveTemplates(t *testing.T) {
+	m := decodeFixture(t, "templated.tmx")
+	if err := m.ResolveTemplates(os.DirFS("fixtures")); err != nil {
+		t.Fatal(err)
+	}
+
+	// the template's tileset is added after the map's own
+	if l := len(m.TileSets); l != 2 {
+		t.Fatalf("expected 2 tilesets, got %v", l)
+	}
+	if ts := m.TileSets[1]; ts.Source != "animated.tsx" || ts.FirstGlobalID != 5 || ts.TileCount != 8 {
+		t.Errorf("unexpected tileset %+v", ts)
+	}
+
+	lights := m.ObjectGroupWithName("lights")
+
+	torch := &lights.Objects[0]
+	if torch.Name != "torch" || torch.Type != "light" || torch.X != 32 || torch.Y != 48 {
+		t.Errorf("unexpected object %+v", torch)
+	}
+	if torch.Width != 16 || torch.Height != 16 || torch.GlobalID != 5 {
+		t.Errorf("expected size and tile of template, got %+v", torch)
+	}
+	if lit, err := torch.Properties.Bool("lit"); err != nil || !lit {
+		t.Errorf("expected property `lit` of template, got %v (%v)", lit, err)
+	}
+
+	dim := &lights.Objects[1]
+	if dim.Name != "dim torch" || dim.X != 0 || dim.Y != 16 || dim.GlobalID != 5 {
+		t.Errorf("unexpected object %+v", dim)
+	}
+	if lit, err := dim.Properties.Bool("lit"); err != nil || lit {
+		t.Errorf("expected property `lit` of object, got %v (%v)", lit, err)
+	}
+	if r, err := dim.Properties.Float("radius"); err != nil || r != 48 {
+		t.Errorf("expected property `radius` of template, got %v (%v)", r, err)
+	}
+
+	td, err := TileDefForGID(m.TileSets, torch.GlobalID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if td.TileSet.Name != "animated" || td.ID != 0 {
+		t.Errorf("unexpected tile of object %+v", td)
+	}
+}
+
+func TestTemplatedObjectTileDef(t *testing.T) {
+	m := decodeFixture(t, "templated.tmx")
+	fsys := os.DirFS("fixtures")
+	if err := m.ResolveTemplates(fsys); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.ResolveTileSets(fsys); err != nil {
+		t.Fatal(err)
+	}
+
+	// the tileset of the objects is only referenced by their template
+	for _, o := range m.ObjectGroupWithName("lights").Objects {
+		td, err := o.TileDef(m.SortedTileSets())
+		if err != nil {
+			t.Fatalf("object %v: %v", o.ObjectID, err)
+		}
+		if td.TileSet.Name != "animated" || td.ID != 0 {
+			t.Errorf("object %v: unexpected tile %+v", o.ObjectID, td)
+		}
+		if water, err := td.Tile.Properties.Bool("water"); err != nil || !water {
+			t.Errorf("object %v: expected tile of tileset, got %+v", o.ObjectID, td.Tile)
+		}
+		if l := len(td.Tile.Animation); l != 3 {
+			t.Errorf("object %v: expected 3 frames, got %v", o.ObjectID, l)
+		}
+	}
+}
+
+func TestResolveTemplatesExistingTileSet(t *testing.T) {
+	tx, err := os.ReadFile("fixtures/torch.tx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tsx, err := os.ReadFile("fixtures/animated.tsx")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fsys := fstest.MapFS{
+		"templates/torch.tx":     &fstest.MapFile{Data: tx},
+		"templates/animated.tsx": &fstest.MapFile{Data: tsx},
+	}
+
+	m := &Map{
+		TileSets: []TileSet{{FirstGlobalID: 10, Source: "templates/animated.tsx"}},
+		ObjectGroups: []ObjectGroup{{Objects: []Object{
+			{ObjectID: 1, Template: "templates/torch.tx"},
+			{ObjectID: 2, Template: "./templates/torch.tx", GlobalID: 12 | TileFlippedHorizontally, overrides: []string{"gid"}},
+		}}},
+	}
+	if err := m.ResolveTemplates(fsys); err != nil {
+		t.Fatal(err)
+	}
+
+	if l := len(m.TileSets); l != 1 {
+		t.Errorf("expected tileset of template to be found, got %v tilesets", l)
+	}
+
+	objects := m.ObjectGroups[0].Objects
+	if gid := objects[0].GlobalID; gid != 10 {
+		t.Errorf("expected gid 10, got %v", gid)
+	}
+	if gid := objects[1].GlobalID; gid != 12|TileFlippedHorizontally {
+		t.Errorf("expected gid of object to be kept, got %v", gid)
+	}
+}
+
+func TestResolveTemplatesInlineTileSet(t *testing.T) {
+	fsys := fstest.MapFS{"crate.tx": &fstest.MapFile{Data: []byte(`<template>
+		<tileset firstgid="1" name="crates" tilewidth="16" tileheight="16" tilecount="4" columns="2">
+			<image source="crates.png" width="32" height="32"/>
+		</tileset>
+		<object name="crate" gid="2" width="16" height="16"/>
+	</template>`)}}
+
+	m := &Map{
+		TileSets: []TileSet{{FirstGlobalID: 1, Name: "blocks", TileCount: 4}},
+		ObjectGroups: []ObjectGroup{{Objects: []Object{
+			{ObjectID: 1, Template: "crate.tx"},
+			{ObjectID: 2, Template: "crate.tx"},
+		}}},
+	}
+	if err := m.ResolveTemplates(fsys); err != nil {
+		t.Fatal(err)
+	}
+
+	if l := len(m.TileSets); l != 2 {
+		t.Fatalf("expected the tileset of the template to be added once, got %v tilesets", l)
+	}
+	if ts := m.TileSets[1]; ts.Name != "crates" || ts.FirstGlobalID != 5 {
+		t.Errorf("unexpected tileset %+v", ts)
+	}
+	for _, o := range m.ObjectGroups[0].Objects {
+		if o.GlobalID != 6 {
+			t.Errorf("object %v: expected gid 6, got %v", o.ObjectID, o.GlobalID)
+		}
+	}
+}
+
+func TestDecodeFileTemplates(t *testing.T) {
+	m, err := DecodeFile(filepath.Join("fixtures", "templated.tmx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if o := m.ObjectGroupWithName("lights").Objects[0]; o.Name != "torch" || o.GlobalID != 5 {
+		t.Errorf("expected template to be resolved, got %+v", o)
+	}
+
+	m = decodeFixture(t, "templated.tmx")
+	if err := m.ResolveTemplates(fstest.MapFS{}); err == nil {
+		t.Error("expected error for missing template")
+	}
+}
+
+func TestDecodeJSONTemplates(t *testing.T) {
+	m, err := DecodeJSON(strings.NewReader(`{"layers": [{"type": "objectgroup", "name": "lights", "objects": [
+		{"id": 1, "template": "torch.tx", "name": "bright torch", "x": 8, "y": 8,
+			"properties": [{"name": "radius", "type": "float", "value": 96}]}
+	]}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.ResolveTemplates(os.DirFS("fixtures")); err != nil {
+		t.Fatal(err)
+	}
+
+	o := m.ObjectGroups[0].Objects[0]
+	if o.Name != "bright torch" || o.Type != "light" || o.X != 8 || o.GlobalID != 1 {
+		t.Errorf("unexpected object %+v", o)
+	}
+	if r, err := o.Properties.Float("radius"); err != nil || r != 96 {
+		t.Errorf("expected property `radius` of object, got %v (%v)", r, err)
+	}
+}
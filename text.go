@@ -0,0 +1,99 @@
+package tmx
+
+import (
+	"encoding/xml"
+	"image/color"
+	"strconv"
+)
+
+// Text is the text displayed by a text object, along with how it is drawn
+type Text struct {
+	Text       string `xml:",chardata"`
+	FontFamily string `xml:"fontfamily,attr"`
+	PixelSize  int    `xml:"pixelsize,attr"`
+	Wrap       bool   `xml:"wrap,attr"`
+	Color      string `xml:"color,attr"`
+	Bold       bool   `xml:"bold,attr"`
+	Italic     bool   `xml:"italic,attr"`
+	Underline  bool   `xml:"underline,attr"`
+	Strikeout  bool   `xml:"strikeout,attr"`
+	Kerning    bool   `xml:"kerning,attr"`
+	HAlign     string `xml:"halign,attr"`
+	VAlign     string `xml:"valign,attr"`
+}
+
+// defaultText returns a Text with each attribute set to the default used by
+// Tiled when the attribute is absent
+func defaultText() Text {
+	return Text{
+		FontFamily: "sans-serif",
+		PixelSize:  16,
+		Color:      "#000000",
+		Kerning:    true,
+		HAlign:     "left",
+		VAlign:     "top",
+	}
+}
+
+// UnmarshalXML decodes a Text, defaulting each absent attribute as Tiled does;
+// notably, a PixelSize of 16 and a black Color.
+func (t *Text) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type text Text
+	*t = defaultText()
+
+	return d.DecodeElement((*text)(t), &start)
+}
+
+// MarshalXML encodes a Text as Tiled does, omitting the attributes which have
+// their default value, and writing flags as 1 or 0.
+func (t *Text) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	def := defaultText()
+	start.Attr = nil
+
+	for _, a := range []struct {
+		name      string
+		value     string
+		isDefault bool
+	}{
+		{"fontfamily", t.FontFamily, t.FontFamily == def.FontFamily},
+		{"pixelsize", strconv.Itoa(t.PixelSize), t.PixelSize == def.PixelSize},
+		{"wrap", formatFlag(t.Wrap), t.Wrap == def.Wrap},
+		{"color", t.Color, t.Color == def.Color},
+		{"bold", formatFlag(t.Bold), t.Bold == def.Bold},
+		{"italic", formatFlag(t.Italic), t.Italic == def.Italic},
+		{"underline", formatFlag(t.Underline), t.Underline == def.Underline},
+		{"strikeout", formatFlag(t.Strikeout), t.Strikeout == def.Strikeout},
+		{"kerning", formatFlag(t.Kerning), t.Kerning == def.Kerning},
+		{"halign", t.HAlign, t.HAlign == def.HAlign},
+		{"valign", t.VAlign, t.VAlign == def.VAlign},
+	} {
+		if !a.isDefault {
+			start.Attr = append(start.Attr, attr(a.name, a.value))
+		}
+	}
+
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+
+	if err := e.EncodeToken(xml.CharData(t.Text)); err != nil {
+		return err
+	}
+
+	return e.EncodeToken(start.End())
+}
+
+// ColorRGBA returns the parsed color of the text; ok is false if the color is
+// not valid.
+func (t *Text) ColorRGBA() (color.RGBA, bool) {
+	return optionalColor(t.Color)
+}
+
+// formatFlag formats a boolean attribute as Tiled writes it
+func formatFlag(b bool) string {
+	if b {
+		return "1"
+	}
+
+	return "0"
+}
@@ -1,13 +1,17 @@
 package tmx
 
 import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/base64"
 	"encoding/binary"
 	"fmt"
+	"io"
 	"strconv"
-	"strings"
 )
 
-func decodeB64LayerData(b []byte) ([]uint32, error) {
+func decodeB64LayerData(dst []GlobalID, b []byte) ([]GlobalID, error) {
 	if len(b)%4 != 0 {
 		return nil, fmt.Errorf(
 			"expected byte array to be divisible by 4, length was %v",
@@ -15,27 +19,108 @@ func decodeB64LayerData(b []byte) ([]uint32, error) {
 		)
 	}
 
-	var uis []uint32
 	for i := 0; i < len(b); i = i + 4 {
 		ui := binary.LittleEndian.Uint32(b[i : i+4])
-		uis = append(uis, ui)
+		dst = append(dst, GlobalID(ui))
 	}
 
-	return uis, nil
+	return dst, nil
 }
 
-func decodeCSVLayerData(b []byte) ([]uint32, error) {
-	strs := strings.Split(string(b), ",")
+func decodeCSVLayerData(dst []GlobalID, b []byte) ([]GlobalID, error) {
+	for {
+		var field []byte
+		if i := bytes.IndexByte(b, ','); i >= 0 {
+			field, b = b[:i], b[i+1:]
+		} else {
+			field, b = b, nil
+		}
 
-	var uis []uint32
-	for _, s := range strs {
-		ui, err := strconv.ParseUint(strings.TrimSpace(s), 10, 32)
+		ui, err := parseUint32(bytes.TrimSpace(field))
 		if err != nil {
 			return nil, err
 		}
 
-		uis = append(uis, uint32(ui))
+		dst = append(dst, GlobalID(ui))
+
+		if b == nil {
+			return dst, nil
+		}
+	}
+}
+
+// parseUint32 parses a base 10 unsigned integer without the string conversion
+// strconv would require.
+func parseUint32(b []byte) (uint32, error) {
+	if len(b) == 0 {
+		return 0, &strconv.NumError{Func: "ParseUint", Num: "", Err: strconv.ErrSyntax}
+	}
+
+	var n uint64
+	for _, c := range b {
+		if c < '0' || c > '9' {
+			return 0, &strconv.NumError{Func: "ParseUint", Num: string(b), Err: strconv.ErrSyntax}
+		}
+
+		n = n*10 + uint64(c-'0')
+		if n > 1<<32-1 {
+			return 0, &strconv.NumError{Func: "ParseUint", Num: string(b), Err: strconv.ErrRange}
+		}
+	}
+
+	return uint32(n), nil
+}
+
+func encodeB64LayerData(gids []GlobalID, compression string) ([]byte, error) {
+	raw := make([]byte, 4*len(gids))
+	for i, gid := range gids {
+		binary.LittleEndian.PutUint32(raw[i*4:], uint32(gid))
+	}
+
+	var buf bytes.Buffer
+	var w io.WriteCloser
+
+	switch compression {
+	case "zlib":
+		w = zlib.NewWriter(&buf)
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "zstd":
+		buf.Write(storeZstd(raw))
+	case "":
+		buf.Write(raw)
+	default:
+		return nil, ErrUnsupportedCompression
+	}
+
+	if w != nil {
+		if _, err := w.Write(raw); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+	}
+
+	out := make([]byte, base64.StdEncoding.EncodedLen(buf.Len()))
+	base64.StdEncoding.Encode(out, buf.Bytes())
+
+	return out, nil
+}
+
+// encodeCSVLayerData writes the GlobalIDs as comma-separated values, with a
+// row of the given width on each line as Tiled does.
+func encodeCSVLayerData(gids []GlobalID, width int) []byte {
+	var b []byte
+	for i, gid := range gids {
+		if i > 0 {
+			b = append(b, ',')
+			if width > 0 && i%width == 0 {
+				b = append(b, '\n')
+			}
+		}
+		b = strconv.AppendUint(b, uint64(gid), 10)
 	}
 
-	return uis, nil
+	return b
 }
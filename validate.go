@@ -0,0 +1,226 @@
+package tmx
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ValidationError is returned by Map.Validate, holding each of the problems
+// found with the map.
+type ValidationError struct {
+	Errs []error
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Errs))
+	for i, err := range e.Errs {
+		msgs[i] = err.Error()
+	}
+
+	return "invalid map: " + strings.Join(msgs, "; ")
+}
+
+// Is reports whether any of the problems found with the map matches target,
+// so that errors.Is may match any of them.
+func (e *ValidationError) Is(target error) bool {
+	for _, err := range e.Errs {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// As finds the first of the problems found with the map which matches target,
+// so that errors.As may match any of them.
+func (e *ValidationError) As(target interface{}) bool {
+	for _, err := range e.Errs {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Unwrap returns the problems found with the map, for versions of Go which
+// match errors holding several others; Is and As match them on any version.
+func (e *ValidationError) Unwrap() []error {
+	return e.Errs
+}
+
+// Validate checks the structure of the map up front, rather than leaving
+// problems to be found when its tiles are used. It checks that:
+//
+//   - the tile data of each layer, or each of its chunks, decodes to exactly
+//     one tile per cell
+//   - every non-zero GlobalID of the layers and tile objects falls within a
+//     TileSet
+//   - the ranges of GlobalIDs of the TileSets do not overlap
+//   - the IDs of objects are unique within the map, and below its
+//     NextObjectID, if it has one
+//   - external TileSets have been resolved, as by ResolveTileSets
+//   - the stagger axis and index of a staggered or hexagonal map are valid
+//
+// All problems found are returned together as a *ValidationError; nil is
+// returned if there are none.
+func (m *Map) Validate() error {
+	var errs []error
+	fail := func(format string, a ...interface{}) {
+		errs = append(errs, fmt.Errorf(format, a...))
+	}
+
+	if m.Orientation == "staggered" || m.Orientation == "hexagonal" {
+		if _, err := m.StaggerAxisAxis(); err != nil {
+			errs = append(errs, err)
+		}
+		if _, err := m.StaggerIndexValue(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	// sorted separately, so the map is left untouched
+	tss := make([]*TileSet, len(m.TileSets))
+	for i := range m.TileSets {
+		tss[i] = &m.TileSets[i]
+	}
+	sort.SliceStable(tss, func(i, j int) bool {
+		return tss[i].FirstGlobalID < tss[j].FirstGlobalID
+	})
+
+	for i, ts := range tss {
+		if ts.Source != "" && !ts.resolved {
+			fail("tileset %v: %w", ts.Source, ErrUnresolvedSource)
+		}
+
+		if i == 0 {
+			continue
+		}
+
+		prev := tss[i-1]
+		n := prev.tileIDLimit()
+		if n < 1 {
+			n = 1
+		}
+		if ts.FirstGlobalID < prev.FirstGlobalID+GlobalID(n) {
+			fail("tileset %v: first global ID %v overlaps tileset %v", ts.label(), ts.FirstGlobalID, prev.label())
+		}
+	}
+
+	checkGID := func(gid GlobalID) error {
+		bid := gid.BareID()
+		if bid == 0 {
+			return nil
+		}
+
+		var ts *TileSet
+		for _, t := range tss {
+			if bid < uint32(t.FirstGlobalID) {
+				break
+			}
+			ts = t
+		}
+
+		if ts == nil {
+			return fmt.Errorf("global ID %v: %w", bid, ErrNoSuitableTileSet)
+		}
+		if (ts.Source == "" || ts.resolved) && int64(bid-uint32(ts.FirstGlobalID)) >= int64(ts.tileIDLimit()) {
+			return fmt.Errorf("global ID %v in tileset %v: %w", bid, ts.label(), ErrTileIDOutOfRange)
+		}
+
+		return nil
+	}
+
+	// only the first bad tile of each layer or chunk is reported
+	checkTiles := func(name string, x, y, width, height int, trs []TileGlobalRef) {
+		if len(trs) != width*height {
+			fail("layer %q: expected %v tiles at (%v,%v), found %v", name, width*height, x, y, len(trs))
+		}
+
+		for i, tr := range trs {
+			if err := checkGID(tr.GlobalID); err != nil {
+				fail("layer %q: tile at (%v,%v): %w", name, x+i%width, y+i/width, err)
+				break
+			}
+		}
+	}
+
+	ids := make(map[ObjectID]bool)
+	m.walk(func(e interface{}) {
+		switch e := e.(type) {
+		case *Layer:
+			chunks, err := e.Chunks()
+			if err != nil {
+				fail("layer %q: %w", e.Name, err)
+				return
+			}
+			if chunks != nil {
+				for _, c := range chunks {
+					checkTiles(e.Name, c.X, c.Y, c.Width, c.Height, c.TileGlobalRefs)
+				}
+				return
+			}
+
+			trs, err := e.TileGlobalRefs()
+			if err != nil {
+				fail("layer %q: %w", e.Name, err)
+				return
+			}
+
+			checkTiles(e.Name, 0, 0, e.Width, e.Height, trs)
+		case *ObjectGroup:
+			for i := range e.Objects {
+				o := &e.Objects[i]
+				if o.ObjectID != 0 {
+					if ids[o.ObjectID] {
+						fail("object group %q: duplicate object ID %v", e.Name, o.ObjectID)
+					}
+					ids[o.ObjectID] = true
+
+					if m.NextObjectID != 0 && o.ObjectID >= m.NextObjectID {
+						fail("object group %q: object ID %v is not below the next object ID %v", e.Name, o.ObjectID, m.NextObjectID)
+					}
+				}
+
+				if err := checkGID(o.GlobalID); err != nil {
+					fail("object group %q: object %v: %w", e.Name, o.ObjectID, err)
+				}
+			}
+		}
+	})
+
+	if len(errs) > 0 {
+		return &ValidationError{Errs: errs}
+	}
+
+	return nil
+}
+
+// tileIDLimit returns one past the largest TileID of the TileSet; in a
+// collection of images, tiles may have been removed, leaving IDs beyond its
+// TileCount
+func (t *TileSet) tileIDLimit() int {
+	n := t.tileCount()
+	for i := range t.Tiles {
+		if id := int(t.Tiles[i].TileID); id >= n {
+			n = id + 1
+		}
+	}
+
+	return n
+}
+
+// label identifies the TileSet in errors, by name or else by source
+func (t *TileSet) label() string {
+	if t.Name != "" {
+		return t.Name
+	}
+	if t.Source != "" {
+		return t.Source
+	}
+
+	return fmt.Sprint(t.FirstGlobalID)
+}
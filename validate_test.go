@@ -0,0 +1,91 @@
+package tmx
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	for _, name := range []string{"test.tmx", "encodings.tmx", "groups.tmx", "external.tmx", "objects.tmx"} {
+		m, err := DecodeFile(filepath.Join("fixtures", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := m.Validate(); err != nil {
+			t.Errorf("%v: expected map to be valid, got %v", name, err)
+		}
+	}
+
+	m := decodeFixture(t, "external.tmx")
+	err := m.Validate()
+	if !errors.Is(err, ErrUnresolvedSource) {
+		t.Errorf("expected %v, got %v", ErrUnresolvedSource, err)
+	}
+	if err := m.ResolveTileSets(os.DirFS("fixtures")); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Validate(); err != nil {
+		t.Errorf("expected map to be valid once resolved, got %v", err)
+	}
+}
+
+func TestValidateProblems(t *testing.T) {
+	m := &Map{
+		TileSets: []TileSet{
+			{FirstGlobalID: 5, Name: "b", TileCount: 4},
+			{FirstGlobalID: 1, Name: "a", TileCount: 5},
+		},
+		Layers: []Layer{
+			{Name: "short", Width: 2, Height: 2, RawData: Data{TileGlobalRefs: []TileGlobalRef{{GlobalID: 1}, {GlobalID: 2}, {GlobalID: 3}}}},
+			{Name: "beyond", Width: 2, Height: 1, RawData: Data{TileGlobalRefs: []TileGlobalRef{{GlobalID: 0}, {GlobalID: 9 | TileFlippedHorizontally}}}},
+		},
+		ObjectGroups: []ObjectGroup{{Name: "things", Objects: []Object{
+			{ObjectID: 1},
+			{ObjectID: 2},
+			{ObjectID: 1},
+		}}},
+	}
+	tss := append([]TileSet(nil), m.TileSets...)
+
+	err := m.Validate()
+
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("expected a ValidationError, got %v", err)
+	}
+
+	// overlap, tile count, out of range gid, duplicate object
+	if l := len(verr.Errs); l != 4 {
+		t.Errorf("expected 4 problems, got %v: %v", l, err)
+	}
+	if !errors.Is(err, ErrTileIDOutOfRange) {
+		t.Errorf("expected %v, got %v", ErrTileIDOutOfRange, err)
+	}
+
+	// matched without the Unwrap of several errors, which needs Go 1.20
+	if !verr.Is(ErrTileIDOutOfRange) || verr.Is(ErrLayerNotFound) {
+		t.Errorf("expected only the problems found to match, got %v", err)
+	}
+	var wrapped interface{ Unwrap() error }
+	if !verr.As(&wrapped) || wrapped.Unwrap() == nil {
+		t.Errorf("expected a wrapped problem to be found in %v", err)
+	}
+
+	if m.TileSets[0].FirstGlobalID != tss[0].FirstGlobalID {
+		t.Error("expected tilesets of map not to be sorted")
+	}
+
+	m.TileSets = m.TileSets[:1]
+	if err := m.Validate(); !errors.Is(err, ErrNoSuitableTileSet) {
+		t.Errorf("expected %v, got %v", ErrNoSuitableTileSet, err)
+	}
+
+	m.NextObjectID = 2
+	if err := m.Validate(); !errors.As(err, &verr) || !strings.Contains(err.Error(), "object ID 2 is not below") {
+		t.Errorf("expected object ID beyond the next object ID to be found, got %v", err)
+	}
+}
@@ -0,0 +1,167 @@
+package tmx
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Positions within a WangID, starting at the top edge and going clockwise
+// around the tile, alternating between edges and corners.
+const (
+	WangTop = iota
+	WangTopRight
+	WangRight
+	WangBottomRight
+	WangBottom
+	WangBottomLeft
+	WangLeft
+	WangTopLeft
+)
+
+// WangSet is a set of Wang colors, and the tiles of a TileSet which are
+// labelled with them, used by Tiled for automatic tiling.
+type WangSet struct {
+	Name       string      `xml:"name,attr"`
+	Type       string      `xml:"type,attr,omitempty"`
+	Class      string      `xml:"class,attr,omitempty"`
+	Tile       int         `xml:"tile,attr"`
+	Properties Properties  `xml:"properties>property"`
+	Colors     []WangColor `xml:"wangcolor"`
+	WangTiles  []WangTile  `xml:"wangtile"`
+}
+
+// WangColor is a color of a WangSet, which a WangID refers to by its position
+// in Colors, starting from 1.
+type WangColor struct {
+	Name        string     `xml:"name,attr"`
+	Class       string     `xml:"class,attr,omitempty"`
+	Color       string     `xml:"color,attr"`
+	Tile        int        `xml:"tile,attr"`
+	Probability float32    `xml:"probability,attr"`
+	Properties  Properties `xml:"properties>property"`
+}
+
+// WangTile labels a tile of the TileSet with the Wang colors of its edges and
+// corners.
+type WangTile struct {
+	TileID TileID `xml:"tileid,attr"`
+
+	// WangID holds the color of each edge and corner of the tile, indexed by
+	// WangTop and the other positions; 0 where there is no color.
+	WangID [8]uint8 `xml:"-"`
+
+	// Raw WangID loaded from XML, either comma-separated or, as written by
+	// versions of Tiled before 1.5, hex-packed. Not intended to be used
+	// directly; use WangID.
+	RawWangID string `xml:"wangid,attr"`
+}
+
+// UnmarshalXML decodes a WangSet, defaulting Tile to -1, meaning no tile,
+// when the attribute is absent.
+func (ws *WangSet) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type wangSet WangSet
+	ws.Tile = -1
+
+	return d.DecodeElement((*wangSet)(ws), &start)
+}
+
+// UnmarshalXML decodes a WangColor, defaulting Tile to -1 and Probability to
+// 1 when the attributes are absent, as Tiled does.
+func (wc *WangColor) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type wangColor WangColor
+	wc.Tile = -1
+	wc.Probability = 1
+
+	return d.DecodeElement((*wangColor)(wc), &start)
+}
+
+// UnmarshalXML decodes a WangTile, parsing its WangID
+func (wt *WangTile) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type wangTile WangTile
+	if err := d.DecodeElement((*wangTile)(wt), &start); err != nil {
+		return err
+	}
+
+	id, err := parseWangID(wt.RawWangID)
+	if err != nil {
+		return err
+	}
+	wt.WangID = id
+
+	return nil
+}
+
+// parseWangID parses a WangID in either of the forms written by Tiled: eight
+// comma-separated colors, or a hex number holding a color in each of its
+// eight nibbles, the lowest for WangTop.
+func parseWangID(s string) (id [8]uint8, err error) {
+	if strings.HasPrefix(s, "0x") {
+		n, err := strconv.ParseUint(s[2:], 16, 32)
+		if err != nil {
+			return id, fmt.Errorf("invalid wangid %v: %w", s, err)
+		}
+
+		for i := range id {
+			id[i] = uint8(n >> (4 * uint(i)) & 0xf)
+		}
+
+		return id, nil
+	}
+
+	strs := strings.Split(s, ",")
+	if l := len(strs); l != len(id) {
+		return id, fmt.Errorf("invalid wangid %v; expected 8 values, got %v", s, l)
+	}
+
+	for i, str := range strs {
+		n, err := strconv.ParseUint(strings.TrimSpace(str), 10, 8)
+		if err != nil {
+			return id, fmt.Errorf("invalid wangid %v: %w", s, err)
+		}
+		id[i] = uint8(n)
+	}
+
+	return id, nil
+}
+
+// WangTile returns the WangTile for the tile with the given TileID; nil if the
+// tile is not in the set.
+func (ws *WangSet) WangTile(id TileID) *WangTile {
+	for i := range ws.WangTiles {
+		if ws.WangTiles[i].TileID == id {
+			return &ws.WangTiles[i]
+		}
+	}
+
+	return nil
+}
+
+// Color returns the WangColor referred to by the given index of a WangID; nil
+// for 0, which is no color, or an index outside of the set.
+func (ws *WangSet) Color(index uint8) *WangColor {
+	if index == 0 || int(index) > len(ws.Colors) {
+		return nil
+	}
+
+	return &ws.Colors[index-1]
+}
+
+// Tiles returns the tiles labelled by the set, in the order of WangTiles,
+// resolved against ts, the TileSet holding the set. Tiled only writes the tiles
+// of a TileSet with something to note about them, so a tile without one is
+// returned as a Tile holding only its TileID and the default Probability. The
+// result is empty, not nil, for a set without tiles.
+func (ws *WangSet) Tiles(ts *TileSet) []*Tile {
+	tiles := make([]*Tile, 0, len(ws.WangTiles))
+	for _, wt := range ws.WangTiles {
+		t := ts.TileWithID(wt.TileID)
+		if t == nil {
+			t = &Tile{TileID: wt.TileID, Probability: 1}
+		}
+		tiles = append(tiles, t)
+	}
+
+	return tiles
+}
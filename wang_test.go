@@ -0,0 +1,210 @@
+package tmx
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestWangSets(t *testing.T) {
+	file, err := os.Open(path.Join("fixtures", "wang.tsx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	ts, err := DecodeTileset(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if l := len(ts.WangSets); l != 1 {
+		t.Fatalf("expected 1 wang set, got %v", l)
+	}
+
+	ws := &ts.WangSets[0]
+	if ws.Name != "paths" || ws.Type != "corner" || ws.Tile != 0 {
+		t.Errorf("unexpected wang set %+v", ws)
+	}
+	if auto, err := ws.Properties.Bool("auto"); err != nil || !auto {
+		t.Errorf("expected property `auto` to be true, got %v (%v)", auto, err)
+	}
+
+	if l := len(ws.Colors); l != 2 {
+		t.Fatalf("expected 2 wang colors, got %v", l)
+	}
+	if c := ws.Color(1); c.Name != "grass" || c.Probability != 0.5 || c.Tile != 0 {
+		t.Errorf("unexpected wang color %+v", c)
+	}
+	if c := ws.Color(2); c.Name != "dirt" || c.Probability != 1 || c.Tile != -1 {
+		t.Errorf("unexpected wang color %+v", c)
+	}
+	if c := ws.Color(0); c != nil {
+		t.Errorf("expected no color for index 0, got %+v", c)
+	}
+
+	wt := ws.WangTile(2)
+	if wt == nil {
+		t.Fatal("expected wang tile for tile 2, found none")
+	}
+	if e := [8]uint8{0, 1, 0, 2, 0, 2, 0, 1}; wt.WangID != e {
+		t.Errorf("expected wangid %v, got %v", e, wt.WangID)
+	}
+	if wt.WangID[WangTopRight] != 1 || wt.WangID[WangBottomRight] != 2 {
+		t.Errorf("unexpected corners in wangid %v", wt.WangID)
+	}
+
+	if wt := ws.WangTile(3); wt != nil {
+		t.Errorf("expected no wang tile for tile 3, got %+v", wt)
+	}
+}
+
+func TestWangSetTiles(t *testing.T) {
+	file, err := os.Open(path.Join("fixtures", "wang.tsx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	ts, err := DecodeTileset(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tiles := ts.WangSets[0].Tiles(ts)
+	if l := len(tiles); l != 3 {
+		t.Fatalf("expected 3 tiles, got %v", l)
+	}
+	for i, tile := range tiles {
+		if tile.TileID != TileID(i) {
+			t.Errorf("expected tile %v at %v, got %v", i, i, tile.TileID)
+		}
+	}
+
+	if tiles[1] != ts.TileWithID(1) {
+		t.Error("expected tile 1 to be the tile of the tileset")
+	}
+	if s, err := tiles[1].Properties.String("surface"); err != nil || s != "dirt" || tiles[1].Probability != 0.25 {
+		t.Errorf("unexpected tile %+v", tiles[1])
+	}
+	if tiles[0].Probability != 1 || len(tiles[0].Properties) != 0 {
+		t.Errorf("expected tile 0 to have no more than its id, got %+v", tiles[0])
+	}
+
+	if tiles := (&WangSet{}).Tiles(ts); tiles == nil || len(tiles) != 0 {
+		t.Errorf("expected empty tiles for empty set, got %#v", tiles)
+	}
+}
+
+func TestWangSetTilesMap(t *testing.T) {
+	var b strings.Builder
+	b.WriteString(`<map width="1" height="1" tilewidth="16" tileheight="16">`)
+	for i := 0; i < 3; i++ {
+		fmt.Fprintf(&b, `<tileset firstgid="%v" name="ts%v" tilewidth="16" tileheight="16" tilecount="4" columns="2">
+			<tile id="1" probability="0.%v"/>
+			<wangsets><wangset name="paths" type="corner" tile="-1">
+				<wangcolor name="grass" color="#00ff00" tile="-1" probability="1"/>
+				<wangtile tileid="1" wangid="0,1,0,1,0,1,0,1"/>
+			</wangset></wangsets>
+		</tileset>`, 1+4*i, i, i+1)
+	}
+	b.WriteString(`</map>`)
+
+	m, err := Decode(strings.NewReader(b.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := range m.TileSets {
+		ts := &m.TileSets[i]
+		ts.Tiles[0].Properties = Properties{{Name: "edited", Value: ts.Name}}
+
+		tiles := ts.WangSets[0].Tiles(ts)
+		if len(tiles) != 1 || tiles[0] != &ts.Tiles[0] {
+			t.Fatalf("%v: expected the tile of the tileset in the map, got %+v", ts.Name, tiles)
+		}
+		if s, _ := tiles[0].Properties.String("edited"); s != ts.Name {
+			t.Errorf("%v: expected edits to the tileset to be seen, got %+v", ts.Name, tiles[0])
+		}
+	}
+}
+
+func TestParseWangID(t *testing.T) {
+	for _, c := range []struct {
+		in  string
+		exp [8]uint8
+		err bool
+	}{
+		{"0,1,0,1,0,1,0,1", [8]uint8{0, 1, 0, 1, 0, 1, 0, 1}, false},
+		{"1, 2, 3, 4, 5, 6, 7, 8", [8]uint8{1, 2, 3, 4, 5, 6, 7, 8}, false},
+		{"0x10101010", [8]uint8{0, 1, 0, 1, 0, 1, 0, 1}, false},
+		{"0x87654321", [8]uint8{1, 2, 3, 4, 5, 6, 7, 8}, false},
+		{"0,1,0,1", [8]uint8{}, true},
+		{"0,1,0,1,0,1,0,x", [8]uint8{}, true},
+		{"0xnope", [8]uint8{}, true},
+	} {
+		id, err := parseWangID(c.in)
+		if (err != nil) != c.err {
+			t.Errorf("%v: expected error %v, got %v", c.in, c.err, err)
+		}
+		if !c.err && id != c.exp {
+			t.Errorf("%v: expected %v, got %v", c.in, c.exp, id)
+		}
+	}
+
+	_, err := DecodeTileset(strings.NewReader(`<tileset><wangsets><wangset><wangtile tileid="0" wangid="1,2"/></wangset></wangsets></tileset>`))
+	if err == nil {
+		t.Error("expected error for malformed wangid")
+	}
+}
+
+func TestDecodeJSONWangSets(t *testing.T) {
+	m, err := DecodeJSON(strings.NewReader(`{"tilesets": [{"firstgid": 1, "name": "wang",
+		"wangsets": [{"name": "paths", "type": "edge", "tile": -1,
+			"colors": [{"name": "water", "color": "#0000ff", "tile": 3, "probability": 1}],
+			"wangtiles": [{"tileid": 3, "wangid": [1, 0, 1, 0, 1, 0, 1, 0]}]}]}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ws := &m.TileSets[0].WangSets[0]
+	if ws.Tile != -1 || ws.Color(1).Name != "water" {
+		t.Errorf("unexpected wang set %+v", ws)
+	}
+	if wt := ws.WangTile(3); wt == nil || wt.WangID != [8]uint8{1, 0, 1, 0, 1, 0, 1, 0} || wt.RawWangID != "1,0,1,0,1,0,1,0" {
+		t.Errorf("unexpected wang tile %+v", wt)
+	}
+}
+
+func TestTransformations(t *testing.T) {
+	file, err := os.Open(path.Join("fixtures", "wang.tsx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	ts, err := DecodeTileset(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e := Transformations{HFlip: true, Rotate: true, PreferUntransformed: true}
+	if tr := ts.Transformations; tr == nil || *tr != e {
+		t.Errorf("expected transformations %+v, got %+v", e, tr)
+	}
+
+	var b strings.Builder
+	if err := xml.NewEncoder(&b).Encode(ts); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), `<transformations hflip="1" vflip="0" rotate="1" preferuntransformed="1"></transformations>`) {
+		t.Errorf("expected transformations to be encoded as Tiled does, got %v", b.String())
+	}
+
+	if tr := decodeFixture(t, "test.tmx").TileSets[0].Transformations; tr != nil {
+		t.Errorf("expected no transformations, got %+v", tr)
+	}
+}
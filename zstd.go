@@ -0,0 +1,935 @@
+package tmx
+
+import (
+	"encoding/binary"
+	"errors"
+	"math/bits"
+)
+
+// This file implements a decoder for Zstandard frames, as described in
+// RFC 8878, sufficient for the tile data written by Tiled. Dictionaries are
+// not supported, as Tiled does not use them.
+
+var errZstdCorrupt = errors.New("zstd: corrupt input")
+
+const (
+	zstdMagic          = 0xfd2fb528
+	zstdSkippableMagic = 0x184d2a50
+	zstdSkippableMask  = 0xfffffff0
+	zstdMaxBlockSize   = 128 << 10
+)
+
+// decompressZstd decompresses every frame in src, returning the concatenated
+// output of all of them.
+func decompressZstd(src []byte) ([]byte, error) {
+	var out []byte
+
+	for len(src) > 0 {
+		if len(src) < 4 {
+			return nil, errZstdCorrupt
+		}
+
+		magic := binary.LittleEndian.Uint32(src)
+		if magic&zstdSkippableMask == zstdSkippableMagic {
+			if len(src) < 8 {
+				return nil, errZstdCorrupt
+			}
+			n := uint64(binary.LittleEndian.Uint32(src[4:]))
+			if n > uint64(len(src)-8) {
+				return nil, errZstdCorrupt
+			}
+			src = src[8+n:]
+			continue
+		}
+
+		if magic != zstdMagic {
+			return nil, errors.New("zstd: invalid magic number")
+		}
+
+		var f zstdFrame
+		var err error
+		if out, src, err = f.decode(out, src[4:]); err != nil {
+			return nil, err
+		}
+	}
+
+	return out, nil
+}
+
+// storeZstd returns a Zstandard frame holding src uncompressed, in raw
+// blocks, for writing data which must be in the zstd format.
+func storeZstd(src []byte) []byte {
+	// no content size and a window descriptor of the maximum block size
+	out := []byte{0x28, 0xb5, 0x2f, 0xfd, 0x00, (17 - 10) << 3}
+
+	for {
+		n := len(src)
+		if n > zstdMaxBlockSize {
+			n = zstdMaxBlockSize
+		}
+
+		h := uint32(n) << 3
+		if n == len(src) {
+			h |= 1
+		}
+		out = append(out, byte(h), byte(h>>8), byte(h>>16))
+		out = append(out, src[:n]...)
+
+		if src = src[n:]; len(src) == 0 {
+			return out
+		}
+	}
+}
+
+// zstdFrame holds the state carried between the blocks of a frame
+type zstdFrame struct {
+	start int // start of the frame's output
+	rep   [3]int
+
+	huffman            *zstdHuffmanTable
+	litLen, off, match *zstdFSETable
+}
+
+// decode appends the content of the frame at the start of src to out, and
+// returns the remaining input.
+func (f *zstdFrame) decode(out, src []byte) ([]byte, []byte, error) {
+	if len(src) < 1 {
+		return nil, nil, errZstdCorrupt
+	}
+
+	fhd := src[0]
+	src = src[1:]
+
+	fcsFlag := fhd >> 6
+	singleSegment := fhd&0x20 != 0
+	checksum := fhd&0x04 != 0
+	dictFlag := fhd & 0x03
+
+	if fhd&0x08 != 0 {
+		return nil, nil, errZstdCorrupt
+	}
+
+	if !singleSegment {
+		if len(src) < 1 {
+			return nil, nil, errZstdCorrupt
+		}
+		src = src[1:] // window descriptor; the whole output is kept
+	}
+
+	dictSize := [4]int{0, 1, 2, 4}[dictFlag]
+	if len(src) < dictSize {
+		return nil, nil, errZstdCorrupt
+	}
+	for _, b := range src[:dictSize] {
+		if b != 0 {
+			return nil, nil, errors.New("zstd: dictionaries are not supported")
+		}
+	}
+	src = src[dictSize:]
+
+	fcsSize := [4]int{0, 2, 4, 8}[fcsFlag]
+	if fcsFlag == 0 && singleSegment {
+		fcsSize = 1
+	}
+	if len(src) < fcsSize {
+		return nil, nil, errZstdCorrupt
+	}
+	src = src[fcsSize:]
+
+	f.start = len(out)
+	f.rep = [3]int{1, 4, 8}
+
+	for {
+		if len(src) < 3 {
+			return nil, nil, errZstdCorrupt
+		}
+
+		h := uint32(src[0]) | uint32(src[1])<<8 | uint32(src[2])<<16
+		src = src[3:]
+
+		last := h&1 != 0
+		size := int(h >> 3)
+
+		switch (h >> 1) & 3 {
+		case 0: // raw
+			if len(src) < size {
+				return nil, nil, errZstdCorrupt
+			}
+			out = append(out, src[:size]...)
+			src = src[size:]
+		case 1: // run length
+			if len(src) < 1 || size > zstdMaxBlockSize {
+				return nil, nil, errZstdCorrupt
+			}
+			for i := 0; i < size; i++ {
+				out = append(out, src[0])
+			}
+			src = src[1:]
+		case 2: // compressed
+			if len(src) < size || size > zstdMaxBlockSize {
+				return nil, nil, errZstdCorrupt
+			}
+			var err error
+			if out, err = f.decodeBlock(out, src[:size]); err != nil {
+				return nil, nil, err
+			}
+			src = src[size:]
+		default:
+			return nil, nil, errZstdCorrupt
+		}
+
+		if last {
+			break
+		}
+	}
+
+	if checksum {
+		if len(src) < 4 {
+			return nil, nil, errZstdCorrupt
+		}
+		if uint32(xxhash64(out[f.start:])) != binary.LittleEndian.Uint32(src) {
+			return nil, nil, errors.New("zstd: checksum mismatch")
+		}
+		src = src[4:]
+	}
+
+	return out, src, nil
+}
+
+// decodeBlock appends the content of a compressed block to out
+func (f *zstdFrame) decodeBlock(out, block []byte) ([]byte, error) {
+	literals, block, err := f.decodeLiterals(block)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(block) < 1 {
+		return nil, errZstdCorrupt
+	}
+
+	n := int(block[0])
+	switch {
+	case n == 0:
+		return append(out, literals...), nil
+	case n < 128:
+		block = block[1:]
+	case n < 255:
+		if len(block) < 2 {
+			return nil, errZstdCorrupt
+		}
+		n = (n-128)<<8 + int(block[1])
+		block = block[2:]
+	default:
+		if len(block) < 3 {
+			return nil, errZstdCorrupt
+		}
+		n = int(block[1]) + int(block[2])<<8 + 0x7f00
+		block = block[3:]
+	}
+
+	if len(block) < 1 || block[0]&3 != 0 {
+		return nil, errZstdCorrupt
+	}
+	modes := block[0]
+	block = block[1:]
+
+	if f.litLen, block, err = readZstdTable(f.litLen, modes>>6, block, 9, zstdLitLenDefault); err != nil {
+		return nil, err
+	}
+	if f.off, block, err = readZstdTable(f.off, modes>>4&3, block, 8, zstdOffsetDefault); err != nil {
+		return nil, err
+	}
+	if f.match, block, err = readZstdTable(f.match, modes>>2&3, block, 9, zstdMatchDefault); err != nil {
+		return nil, err
+	}
+
+	br, err := newZstdBackwardReader(block)
+	if err != nil {
+		return nil, err
+	}
+
+	llState := br.read(f.litLen.log)
+	ofState := br.read(f.off.log)
+	mlState := br.read(f.match.log)
+
+	for i := 0; i < n; i++ {
+		llCode := f.litLen.entries[llState].symbol
+		ofCode := f.off.entries[ofState].symbol
+		mlCode := f.match.entries[mlState].symbol
+
+		if int(llCode) >= len(zstdLitLenCodes) || int(mlCode) >= len(zstdMatchCodes) || ofCode > 31 {
+			return nil, errZstdCorrupt
+		}
+
+		offset := 1<<ofCode + int(br.read(uint(ofCode)))
+		mc := zstdMatchCodes[mlCode]
+		matchLen := int(mc.base) + int(br.read(uint(mc.bits)))
+		lc := zstdLitLenCodes[llCode]
+		litLen := int(lc.base) + int(br.read(uint(lc.bits)))
+
+		if i < n-1 {
+			llState = f.litLen.next(llState, br)
+			mlState = f.match.next(mlState, br)
+			ofState = f.off.next(ofState, br)
+		}
+
+		offset = f.repeatOffset(offset, litLen)
+
+		if litLen > len(literals) {
+			return nil, errZstdCorrupt
+		}
+		out = append(out, literals[:litLen]...)
+		literals = literals[litLen:]
+
+		if offset <= 0 || offset > len(out)-f.start {
+			return nil, errZstdCorrupt
+		}
+		from := len(out) - offset
+		for j := 0; j < matchLen; j++ {
+			out = append(out, out[from+j])
+		}
+	}
+
+	if br.pos != 0 {
+		return nil, errZstdCorrupt
+	}
+
+	return append(out, literals...), nil
+}
+
+// repeatOffset resolves the offset value of a sequence to the actual offset,
+// updating the repeated offsets.
+func (f *zstdFrame) repeatOffset(value, litLen int) int {
+	if value > 3 {
+		f.rep = [3]int{value - 3, f.rep[0], f.rep[1]}
+		return f.rep[0]
+	}
+
+	if litLen == 0 {
+		value++
+	}
+
+	switch value {
+	case 1:
+	case 2:
+		f.rep[0], f.rep[1] = f.rep[1], f.rep[0]
+	case 3:
+		f.rep = [3]int{f.rep[2], f.rep[0], f.rep[1]}
+	default:
+		f.rep = [3]int{f.rep[0] - 1, f.rep[0], f.rep[1]}
+	}
+
+	return f.rep[0]
+}
+
+// decodeLiterals decodes the literals section at the start of a block,
+// returning the literals and the remainder of the block.
+func (f *zstdFrame) decodeLiterals(block []byte) ([]byte, []byte, error) {
+	if len(block) < 1 {
+		return nil, nil, errZstdCorrupt
+	}
+
+	b0 := int(block[0])
+	kind := b0 & 3
+	sizeFormat := b0 >> 2 & 3
+
+	if kind < 2 {
+		var size, header int
+		switch sizeFormat {
+		case 0, 2:
+			size, header = b0>>3, 1
+		case 1:
+			if len(block) < 2 {
+				return nil, nil, errZstdCorrupt
+			}
+			size, header = b0>>4+int(block[1])<<4, 2
+		case 3:
+			if len(block) < 3 {
+				return nil, nil, errZstdCorrupt
+			}
+			size, header = b0>>4+int(block[1])<<4+int(block[2])<<12, 3
+		}
+		block = block[header:]
+
+		if size > zstdMaxBlockSize {
+			return nil, nil, errZstdCorrupt
+		}
+
+		if kind == 0 {
+			if len(block) < size {
+				return nil, nil, errZstdCorrupt
+			}
+			return block[:size], block[size:], nil
+		}
+
+		if len(block) < 1 {
+			return nil, nil, errZstdCorrupt
+		}
+		literals := make([]byte, size)
+		for i := range literals {
+			literals[i] = block[0]
+		}
+		return literals, block[1:], nil
+	}
+
+	var size, compressed, header int
+	streams := 4
+	switch sizeFormat {
+	case 0, 1:
+		if len(block) < 3 {
+			return nil, nil, errZstdCorrupt
+		}
+		if sizeFormat == 0 {
+			streams = 1
+		}
+		size = b0>>4 + int(block[1]&0x3f)<<4
+		compressed = int(block[1])>>6 + int(block[2])<<2
+		header = 3
+	case 2:
+		if len(block) < 4 {
+			return nil, nil, errZstdCorrupt
+		}
+		size = b0>>4 + int(block[1])<<4 + int(block[2]&3)<<12
+		compressed = int(block[2])>>2 + int(block[3])<<6
+		header = 4
+	case 3:
+		if len(block) < 5 {
+			return nil, nil, errZstdCorrupt
+		}
+		size = b0>>4 + int(block[1])<<4 + int(block[2]&0x3f)<<12
+		compressed = int(block[2])>>6 + int(block[3])<<2 + int(block[4])<<10
+		header = 5
+	}
+	block = block[header:]
+
+	if len(block) < compressed || size > zstdMaxBlockSize {
+		return nil, nil, errZstdCorrupt
+	}
+	data, rest := block[:compressed], block[compressed:]
+
+	if kind == 2 {
+		t, n, err := readZstdHuffmanTable(data)
+		if err != nil {
+			return nil, nil, err
+		}
+		f.huffman = t
+		data = data[n:]
+	} else if f.huffman == nil {
+		return nil, nil, errZstdCorrupt
+	}
+
+	literals := make([]byte, size)
+
+	if streams == 1 {
+		if err := f.huffman.decode(literals, data); err != nil {
+			return nil, nil, err
+		}
+		return literals, rest, nil
+	}
+
+	if len(data) < 6 {
+		return nil, nil, errZstdCorrupt
+	}
+	var sizes [4]int
+	total := 6
+	for i := 0; i < 3; i++ {
+		sizes[i] = int(binary.LittleEndian.Uint16(data[2*i:]))
+		total += sizes[i]
+	}
+	if total > len(data) {
+		return nil, nil, errZstdCorrupt
+	}
+	sizes[3] = len(data) - total
+	data = data[6:]
+
+	per := (size + 3) / 4
+	if 3*per > size {
+		return nil, nil, errZstdCorrupt
+	}
+	for i := 0; i < 4; i++ {
+		dst := literals[i*per:]
+		if i < 3 {
+			dst = dst[:per]
+		}
+		if err := f.huffman.decode(dst, data[:sizes[i]]); err != nil {
+			return nil, nil, err
+		}
+		data = data[sizes[i]:]
+	}
+
+	return literals, rest, nil
+}
+
+// zstdBackwardReader reads a bitstream from its end towards its start, as
+// the Huffman and FSE coded streams are written.
+type zstdBackwardReader struct {
+	data []byte
+	pos  int // bits remaining to be read
+}
+
+func newZstdBackwardReader(data []byte) (*zstdBackwardReader, error) {
+	if len(data) == 0 || data[len(data)-1] == 0 {
+		return nil, errZstdCorrupt
+	}
+
+	// the highest set bit of the last byte marks the start of the stream
+	last := data[len(data)-1]
+	pos := (len(data)-1)*8 + bits.Len8(last) - 1
+
+	return &zstdBackwardReader{data: data, pos: pos}, nil
+}
+
+// peek returns the next n bits without consuming them; bits beyond the start
+// of the stream read as zero.
+func (br *zstdBackwardReader) peek(n uint) uint64 {
+	if n == 0 {
+		return 0
+	}
+
+	p := br.pos - int(n)
+	shift := uint(0)
+	if p < 0 {
+		if -p >= int(n) {
+			return 0
+		}
+		shift, n, p = uint(-p), n-uint(-p), 0
+	}
+
+	var word uint64
+	for i := 0; i < 8 && p/8+i < len(br.data); i++ {
+		word |= uint64(br.data[p/8+i]) << (8 * uint(i))
+	}
+
+	return (word >> uint(p%8) & (1<<n - 1)) << shift
+}
+
+// read consumes and returns the next n bits
+func (br *zstdBackwardReader) read(n uint) uint64 {
+	v := br.peek(n)
+	br.pos -= int(n)
+
+	return v
+}
+
+type zstdFSEEntry struct {
+	symbol   uint8
+	bits     uint8
+	baseline uint16
+}
+
+type zstdFSETable struct {
+	log     uint
+	entries []zstdFSEEntry
+}
+
+// next returns the state following the given one
+func (t *zstdFSETable) next(state uint64, br *zstdBackwardReader) uint64 {
+	e := t.entries[state]
+
+	return uint64(e.baseline) + br.read(uint(e.bits))
+}
+
+// newZstdFSETable builds a decoding table from normalized symbol counts,
+// where a count of -1 marks a symbol with a "less than 1" probability.
+func newZstdFSETable(counts []int16, log uint) *zstdFSETable {
+	size := 1 << log
+	t := &zstdFSETable{log: log, entries: make([]zstdFSEEntry, size)}
+	next := make([]int, len(counts))
+
+	high := size - 1
+	for s, c := range counts {
+		if c == -1 {
+			t.entries[high].symbol = uint8(s)
+			high--
+			next[s] = 1
+		} else {
+			next[s] = int(c)
+		}
+	}
+
+	step := size>>1 + size>>3 + 3
+	pos := 0
+	for s, c := range counts {
+		for i := 0; i < int(c); i++ {
+			t.entries[pos].symbol = uint8(s)
+			for pos = (pos + step) & (size - 1); pos > high; pos = (pos + step) & (size - 1) {
+			}
+		}
+	}
+
+	for i := range t.entries {
+		e := &t.entries[i]
+		state := next[e.symbol]
+		next[e.symbol]++
+		e.bits = uint8(log - uint(bits.Len(uint(state))-1))
+		e.baseline = uint16(state<<e.bits - size)
+	}
+
+	return t
+}
+
+// readZstdFSECounts reads an FSE table description from the start of src,
+// returning the table and the number of bytes read.
+func readZstdFSECounts(src []byte, maxLog uint, maxSymbol int) (*zstdFSETable, int, error) {
+	br := zstdForwardReader{data: src}
+
+	log := uint(br.read(4)) + 5
+	if log > maxLog {
+		return nil, 0, errZstdCorrupt
+	}
+
+	var counts []int16
+	remaining := 1<<log + 1
+	threshold := 1 << log
+	nbBits := log + 1
+	previousZero := false
+
+	for remaining > 1 && len(counts) <= maxSymbol {
+		if previousZero {
+			for {
+				repeat := int(br.read(2))
+				for i := 0; i < repeat; i++ {
+					counts = append(counts, 0)
+				}
+				if repeat != 3 {
+					break
+				}
+			}
+			if len(counts) > maxSymbol {
+				break
+			}
+		}
+
+		max := 2*threshold - 1 - remaining
+		v := int(br.peek(nbBits))
+		var count int
+		if v&(threshold-1) < max {
+			count = v & (threshold - 1)
+			br.pos += int(nbBits) - 1
+		} else {
+			count = v & (2*threshold - 1)
+			if count >= threshold {
+				count -= max
+			}
+			br.pos += int(nbBits)
+		}
+		count--
+
+		if count < 0 {
+			remaining += count
+		} else {
+			remaining -= count
+		}
+		counts = append(counts, int16(count))
+		previousZero = count == 0
+
+		for remaining < threshold {
+			nbBits--
+			threshold >>= 1
+		}
+	}
+
+	n := (br.pos + 7) / 8
+	if remaining != 1 || len(counts) > maxSymbol+1 || n > len(src) {
+		return nil, 0, errZstdCorrupt
+	}
+
+	return newZstdFSETable(counts, log), n, nil
+}
+
+// readZstdTable reads the decoding table for a sequence symbol, in the given
+// compression mode, from the start of src.
+func readZstdTable(previous *zstdFSETable, mode byte, src []byte, maxLog uint, predefined *zstdFSETable) (*zstdFSETable, []byte, error) {
+	switch mode {
+	case 0:
+		return predefined, src, nil
+	case 1:
+		if len(src) < 1 {
+			return nil, nil, errZstdCorrupt
+		}
+		t := &zstdFSETable{entries: []zstdFSEEntry{{symbol: src[0]}}}
+		return t, src[1:], nil
+	case 2:
+		t, n, err := readZstdFSECounts(src, maxLog, 52)
+		if err != nil {
+			return nil, nil, err
+		}
+		return t, src[n:], nil
+	default:
+		if previous == nil {
+			return nil, nil, errZstdCorrupt
+		}
+		return previous, src, nil
+	}
+}
+
+// zstdForwardReader reads a bitstream from its start, least significant bit
+// first, as FSE table descriptions are written.
+type zstdForwardReader struct {
+	data []byte
+	pos  int
+}
+
+func (br *zstdForwardReader) peek(n uint) uint64 {
+	var word uint64
+	start := br.pos / 8
+	for i := 0; i < 8 && start+i < len(br.data); i++ {
+		word |= uint64(br.data[start+i]) << (8 * uint(i))
+	}
+
+	return word >> uint(br.pos%8) & (1<<n - 1)
+}
+
+func (br *zstdForwardReader) read(n uint) uint64 {
+	v := br.peek(n)
+	br.pos += int(n)
+
+	return v
+}
+
+type zstdHuffmanEntry struct {
+	symbol uint8
+	bits   uint8
+}
+
+type zstdHuffmanTable struct {
+	maxBits uint
+	entries []zstdHuffmanEntry
+}
+
+// readZstdHuffmanTable reads a Huffman tree description from the start of
+// src, returning the table and the number of bytes read.
+func readZstdHuffmanTable(src []byte) (*zstdHuffmanTable, int, error) {
+	if len(src) < 1 {
+		return nil, 0, errZstdCorrupt
+	}
+
+	var weights []uint8
+	header := int(src[0])
+	n := 1
+
+	if header >= 128 {
+		count := header - 127
+		n += (count + 1) / 2
+		if len(src) < n {
+			return nil, 0, errZstdCorrupt
+		}
+		for i := 0; i < count; i++ {
+			b := src[1+i/2]
+			if i%2 == 0 {
+				weights = append(weights, b>>4)
+			} else {
+				weights = append(weights, b&0xf)
+			}
+		}
+	} else {
+		n += header
+		if len(src) < n {
+			return nil, 0, errZstdCorrupt
+		}
+
+		var err error
+		if weights, err = decodeZstdHuffmanWeights(src[1:n]); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	// the weight of the last symbol is implied by the others, such that the
+	// total is a power of two
+	total := 0
+	for _, w := range weights {
+		if w > 11 {
+			return nil, 0, errZstdCorrupt
+		}
+		if w > 0 {
+			total += 1 << (w - 1)
+		}
+	}
+	if total == 0 || len(weights) > 255 {
+		return nil, 0, errZstdCorrupt
+	}
+
+	maxBits := uint(bits.Len(uint(total)))
+	left := 1<<maxBits - total
+	if left&(left-1) != 0 {
+		return nil, 0, errZstdCorrupt
+	}
+	weights = append(weights, uint8(bits.Len(uint(left))))
+
+	if maxBits > 11 {
+		return nil, 0, errZstdCorrupt
+	}
+
+	t := &zstdHuffmanTable{maxBits: maxBits, entries: make([]zstdHuffmanEntry, 1<<maxBits)}
+
+	// codes are assigned from the lowest weight up, then by symbol
+	pos := 0
+	for w := uint8(1); w <= uint8(maxBits); w++ {
+		for s, sw := range weights {
+			if sw != w {
+				continue
+			}
+			span := 1 << (w - 1)
+			for i := 0; i < span; i++ {
+				t.entries[pos+i] = zstdHuffmanEntry{symbol: uint8(s), bits: uint8(maxBits + 1 - uint(w))}
+			}
+			pos += span
+		}
+	}
+
+	return t, n, nil
+}
+
+// decodeZstdHuffmanWeights decodes FSE compressed Huffman weights, which are
+// interleaved between two states.
+func decodeZstdHuffmanWeights(src []byte) ([]uint8, error) {
+	t, n, err := readZstdFSECounts(src, 6, 255)
+	if err != nil {
+		return nil, err
+	}
+
+	br, err := newZstdBackwardReader(src[n:])
+	if err != nil {
+		return nil, err
+	}
+
+	states := [2]uint64{br.read(t.log), br.read(t.log)}
+
+	var weights []uint8
+	for i := 0; ; i ^= 1 {
+		if len(weights) > 255 {
+			return nil, errZstdCorrupt
+		}
+
+		weights = append(weights, t.entries[states[i]].symbol)
+		states[i] = t.next(states[i], br)
+
+		if br.pos < 0 {
+			weights = append(weights, t.entries[states[i^1]].symbol)
+			return weights, nil
+		}
+	}
+}
+
+// decode fills dst with symbols from a single Huffman coded stream
+func (t *zstdHuffmanTable) decode(dst, src []byte) error {
+	br, err := newZstdBackwardReader(src)
+	if err != nil {
+		return err
+	}
+
+	for i := range dst {
+		e := t.entries[br.peek(t.maxBits)]
+		dst[i] = e.symbol
+		br.pos -= int(e.bits)
+	}
+
+	if br.pos != 0 {
+		return errZstdCorrupt
+	}
+
+	return nil
+}
+
+type zstdLengthCode struct {
+	base uint32
+	bits uint8
+}
+
+var zstdLitLenCodes = [...]zstdLengthCode{
+	{0, 0}, {1, 0}, {2, 0}, {3, 0}, {4, 0}, {5, 0}, {6, 0}, {7, 0},
+	{8, 0}, {9, 0}, {10, 0}, {11, 0}, {12, 0}, {13, 0}, {14, 0}, {15, 0},
+	{16, 1}, {18, 1}, {20, 1}, {22, 1}, {24, 2}, {28, 2}, {32, 3}, {40, 3},
+	{48, 4}, {64, 6}, {128, 7}, {256, 8}, {512, 9}, {1024, 10}, {2048, 11},
+	{4096, 12}, {8192, 13}, {16384, 14}, {32768, 15}, {65536, 16},
+}
+
+var zstdMatchCodes = [...]zstdLengthCode{
+	{3, 0}, {4, 0}, {5, 0}, {6, 0}, {7, 0}, {8, 0}, {9, 0}, {10, 0},
+	{11, 0}, {12, 0}, {13, 0}, {14, 0}, {15, 0}, {16, 0}, {17, 0}, {18, 0},
+	{19, 0}, {20, 0}, {21, 0}, {22, 0}, {23, 0}, {24, 0}, {25, 0}, {26, 0},
+	{27, 0}, {28, 0}, {29, 0}, {30, 0}, {31, 0}, {32, 0}, {33, 0}, {34, 0},
+	{35, 1}, {37, 1}, {39, 1}, {41, 1}, {43, 2}, {47, 2}, {51, 3}, {59, 3},
+	{67, 4}, {83, 4}, {99, 5}, {131, 7}, {259, 8}, {515, 9}, {1027, 10},
+	{2051, 11}, {4099, 12}, {8195, 13}, {16387, 14}, {32771, 15}, {65539, 16},
+}
+
+var (
+	zstdLitLenDefault = newZstdFSETable([]int16{
+		4, 3, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 1, 1, 1,
+		2, 2, 2, 2, 2, 2, 2, 2, 2, 3, 2, 1, 1, 1, 1, 1,
+		-1, -1, -1, -1,
+	}, 6)
+	zstdMatchDefault = newZstdFSETable([]int16{
+		1, 4, 3, 2, 2, 2, 2, 2, 2, 1, 1, 1, 1, 1, 1, 1,
+		1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1,
+		1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, -1, -1,
+		-1, -1, -1, -1, -1,
+	}, 6)
+	zstdOffsetDefault = newZstdFSETable([]int16{
+		1, 1, 1, 1, 1, 1, 2, 2, 2, 1, 1, 1, 1, 1, 1, 1,
+		1, 1, 1, 1, 1, 1, 1, 1, -1, -1, -1, -1, -1,
+	}, 5)
+)
+
+// xxhash64 returns the XXH64 hash of b with a seed of 0, used for the frame
+// checksum.
+func xxhash64(b []byte) uint64 {
+	// variables rather than constants, as the arithmetic wraps
+	var (
+		p1 uint64 = 11400714785074694791
+		p2 uint64 = 14029467366897019727
+		p3 uint64 = 1609587929392839161
+		p4 uint64 = 9650029242287828579
+		p5 uint64 = 2870177450012600261
+	)
+
+	round := func(acc, v uint64) uint64 {
+		return bits.RotateLeft64(acc+v*p2, 31) * p1
+	}
+
+	n := uint64(len(b))
+	var h uint64
+
+	if len(b) >= 32 {
+		v1, v2, v3, v4 := p1+p2, p2, uint64(0), -p1
+		for ; len(b) >= 32; b = b[32:] {
+			v1 = round(v1, binary.LittleEndian.Uint64(b))
+			v2 = round(v2, binary.LittleEndian.Uint64(b[8:]))
+			v3 = round(v3, binary.LittleEndian.Uint64(b[16:]))
+			v4 = round(v4, binary.LittleEndian.Uint64(b[24:]))
+		}
+
+		h = bits.RotateLeft64(v1, 1) + bits.RotateLeft64(v2, 7) +
+			bits.RotateLeft64(v3, 12) + bits.RotateLeft64(v4, 18)
+		for _, v := range [4]uint64{v1, v2, v3, v4} {
+			h = (h^round(0, v))*p1 + p4
+		}
+	} else {
+		h = p5
+	}
+
+	h += n
+
+	for ; len(b) >= 8; b = b[8:] {
+		h ^= round(0, binary.LittleEndian.Uint64(b))
+		h = bits.RotateLeft64(h, 27)*p1 + p4
+	}
+	if len(b) >= 4 {
+		h ^= uint64(binary.LittleEndian.Uint32(b)) * p1
+		h = bits.RotateLeft64(h, 23)*p2 + p3
+		b = b[4:]
+	}
+	for _, c := range b {
+		h ^= uint64(c) * p5
+		h = bits.RotateLeft64(h, 11) * p1
+	}
+
+	h ^= h >> 33
+	h *= p2
+	h ^= h >> 29
+	h *= p3
+	h ^= h >> 32
+
+	return h
+}
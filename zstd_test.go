@@ -0,0 +1,41 @@
+package tmx
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDecompressZstdFrames(t *testing.T) {
+	// "abc", "defgh", and an empty frame, each with a checksum
+	src := []byte{
+		0x28, 0xb5, 0x2f, 0xfd, 0x04, 0x58, 0x19, 0x00, 0x00, 0x61, 0x62, 0x63,
+		0x99, 0x09, 0x77, 0xad, 0x28, 0xb5, 0x2f, 0xfd, 0x04, 0x58, 0x29, 0x00,
+		0x00, 0x64, 0x65, 0x66, 0x67, 0x68, 0x59, 0x2d, 0xbf, 0x54, 0x28, 0xb5,
+		0x2f, 0xfd, 0x24, 0x00, 0x01, 0x00, 0x00, 0x99, 0xe9, 0xd8, 0x51,
+	}
+
+	out, err := decompressZstd(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != "abcdefgh" {
+		t.Errorf("expected `abcdefgh`, got %q", out)
+	}
+
+	src[12] ^= 0xff
+	if _, err := decompressZstd(src); err == nil {
+		t.Error("expected checksum mismatch, got none")
+	}
+}
+
+func TestStoreZstd(t *testing.T) {
+	src := bytes.Repeat([]byte("tile"), 100000)
+
+	out, err := decompressZstd(storeZstd(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out, src) {
+		t.Errorf("expected stored frame to decompress to its input")
+	}
+}